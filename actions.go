@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// Action is a single page interaction performed after form handling and before --js
+type Action struct {
+	Type     string
+	Selector string
+	Target   string
+	// From and To are viewport coordinates for the raw mouse actions
+	From Point
+	To   Point
+	// Keys is the chord for --keys, as key codes to hold down in order
+	Keys []string
+}
+
+// Point is a position in CSS pixels relative to the top-left of the viewport
+type Point struct {
+	X, Y int
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%d,%d", p.X, p.Y)
+}
+
+// parsePoint parses "x,y"
+func parsePoint(value string) (Point, error) {
+	xs, ys, ok := strings.Cut(strings.TrimSpace(value), ",")
+	x, errX := strconv.Atoi(strings.TrimSpace(xs))
+	y, errY := strconv.Atoi(strings.TrimSpace(ys))
+	if !ok || errX != nil || errY != nil || x < 0 || y < 0 {
+		return Point{}, fmt.Errorf("expected x,y coordinates, got %q", value)
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+// runActions performs each action in order, waiting for navigation or LiveView patches in between
+func runActions(wd selenium.WebDriver, config Config, isLiveView bool) error {
+	for _, action := range config.Actions {
+		currentURL, _ := wd.CurrentURL()
+		slowDown(config)
+
+		switch action.Type {
+		case "drag":
+			if err := dragAndDrop(wd, config, action.Selector, action.Target); err != nil {
+				return err
+			}
+			logf("Dragged %s onto %s\n", action.Selector, action.Target)
+		case "dblclick":
+			if err := pointerClick(wd, config, action.Selector, 0, 2); err != nil {
+				return err
+			}
+			logf("Double-clicked %s\n", action.Selector)
+		case "right-click":
+			if err := pointerClick(wd, config, action.Selector, 2, 1); err != nil {
+				return err
+			}
+			logf("Right-clicked %s\n", action.Selector)
+		case "keys":
+			if err := pressKeyChord(wd, action.Keys); err != nil {
+				return fmt.Errorf("could not press %s: %v", action.Selector, err)
+			}
+			logf("Pressed %s\n", action.Selector)
+		case "mouse-click":
+			err := performPointerActions(wd, []map[string]interface{}{
+				pointerMove(action.From.X, action.From.Y, 0),
+				{"type": "pointerDown", "button": 0},
+				{"type": "pointerUp", "button": 0},
+			})
+			if err != nil {
+				return fmt.Errorf("could not click at %s: %v", action.From, err)
+			}
+			logf("Clicked at %s\n", action.From)
+		case "mouse-move":
+			if err := performPointerActions(wd, []map[string]interface{}{pointerMove(action.From.X, action.From.Y, 100)}); err != nil {
+				return fmt.Errorf("could not move mouse to %s: %v", action.From, err)
+			}
+			logf("Moved mouse to %s\n", action.From)
+		case "pause":
+			pauseRun(config, action.Selector)
+			continue
+		case "mouse-drag":
+			err := performPointerActions(wd, []map[string]interface{}{
+				pointerMove(action.From.X, action.From.Y, 0),
+				{"type": "pointerDown", "button": 0},
+				pointerMove(action.To.X, action.To.Y, 300),
+				{"type": "pointerUp", "button": 0},
+			})
+			if err != nil {
+				return fmt.Errorf("could not drag from %s to %s: %v", action.From, action.To, err)
+			}
+			logf("Dragged mouse from %s to %s\n", action.From, action.To)
+		default:
+			return fmt.Errorf("unknown action: %s", action.Type)
+		}
+
+		waitForPageUpdate(wd, isLiveView, currentURL, config.NavTimeout)
+		settlePage(wd, config)
+	}
+	return nil
+}
+
+// clickAndWait clicks the element matching selector and waits for the resulting
+// navigation or LiveView patch, re-preparing the page if a new document loaded
+func clickAndWait(wd selenium.WebDriver, config Config, selector string, isLiveView *bool) error {
+	elem, err := waitForElement(wd, config, selector)
+	if err != nil {
+		return fmt.Errorf("could not find %s: %v", selector, err)
+	}
+	currentURL, _ := wd.CurrentURL()
+	if err := elem.Click(); err != nil {
+		return fmt.Errorf("could not click %s: %v", selector, err)
+	}
+	waitForPageUpdate(wd, *isLiveView, currentURL, config.NavTimeout)
+
+	// A click may have loaded a new document, which needs console capture again
+	refreshPageState(wd, config, isLiveView)
+	settlePage(wd, config)
+	return nil
+}
+
+// fillField replaces the value of the element matching selector
+func fillField(wd selenium.WebDriver, config Config, selector, value string) error {
+	elem, err := waitForElement(wd, config, selector)
+	if err != nil {
+		return fmt.Errorf("could not find %s: %v", selector, err)
+	}
+	if err := fillFormField(wd, elem, value, false); err != nil {
+		return fmt.Errorf("could not fill %s: %v", selector, err)
+	}
+	return nil
+}
+
+// dragAndDrop drags the source element onto the target element. Native HTML5
+// draggables get a synthesized drag event sequence since Firefox does not start
+// a drag session from WebDriver pointer input; everything else (pointer-based
+// sortable libraries, LiveView hooks) is driven with real pointer actions.
+func dragAndDrop(wd selenium.WebDriver, config Config, sourceSelector, targetSelector string) error {
+	source, err := waitForElement(wd, config, sourceSelector)
+	if err != nil {
+		return fmt.Errorf("could not find drag source %s: %v", sourceSelector, err)
+	}
+	target, err := waitForElement(wd, config, targetSelector)
+	if err != nil {
+		return fmt.Errorf("could not find drop target %s: %v", targetSelector, err)
+	}
+
+	draggable, err := wd.ExecuteScript("return arguments[0].draggable === true", []interface{}{source})
+	if err == nil && draggable == true {
+		_, err = wd.ExecuteScript(html5DragAndDropJS, []interface{}{source, target})
+		if err != nil {
+			return fmt.Errorf("could not drag %s to %s: %v", sourceSelector, targetSelector, err)
+		}
+		return nil
+	}
+
+	sourceX, sourceY, err := elementCenter(wd, source)
+	if err != nil {
+		return fmt.Errorf("could not locate drag source %s: %v", sourceSelector, err)
+	}
+	targetX, targetY, err := elementCenter(wd, target)
+	if err != nil {
+		return fmt.Errorf("could not locate drop target %s: %v", targetSelector, err)
+	}
+
+	err = performPointerActions(wd, []map[string]interface{}{
+		pointerMove(sourceX, sourceY, 0),
+		{"type": "pointerDown", "button": 0},
+		{"type": "pause", "duration": 100},
+		// Nudge past the drag threshold most sortable libraries use before moving to the target
+		pointerMove(sourceX+5, sourceY+5, 50),
+		pointerMove(targetX, targetY, 300),
+		{"type": "pause", "duration": 100},
+		{"type": "pointerUp", "button": 0},
+	})
+	if err != nil {
+		return fmt.Errorf("could not drag %s to %s: %v", sourceSelector, targetSelector, err)
+	}
+	return nil
+}
+
+// pointerClick clicks the element matching selector count times with the given
+// mouse button (0 left, 2 right) using real pointer input, so the browser fires
+// dblclick and contextmenu events exactly as it would for a user
+func pointerClick(wd selenium.WebDriver, config Config, selector string, button, count int) error {
+	elem, err := waitForElement(wd, config, selector)
+	if err != nil {
+		return fmt.Errorf("could not find %s: %v", selector, err)
+	}
+	x, y, err := elementCenter(wd, elem)
+	if err != nil {
+		return fmt.Errorf("could not locate %s: %v", selector, err)
+	}
+
+	actions := []map[string]interface{}{pointerMove(x, y, 0)}
+	for i := 0; i < count; i++ {
+		actions = append(actions,
+			map[string]interface{}{"type": "pointerDown", "button": button},
+			map[string]interface{}{"type": "pointerUp", "button": button},
+		)
+	}
+	if err := performPointerActions(wd, actions); err != nil {
+		return fmt.Errorf("could not click %s: %v", selector, err)
+	}
+	return nil
+}
+
+// elementCenter scrolls the element into view and returns its center in viewport coordinates
+func elementCenter(wd selenium.WebDriver, elem selenium.WebElement) (int, int, error) {
+	result, err := wd.ExecuteScript(`
+		var el = arguments[0];
+		el.scrollIntoView({block: 'center', inline: 'center'});
+		var rect = el.getBoundingClientRect();
+		return [Math.round(rect.left + rect.width / 2), Math.round(rect.top + rect.height / 2)];
+	`, []interface{}{elem})
+	if err != nil {
+		return 0, 0, err
+	}
+	coords, ok := result.([]interface{})
+	if !ok || len(coords) != 2 {
+		return 0, 0, fmt.Errorf("unexpected element position: %v", result)
+	}
+	x, _ := coords[0].(float64)
+	y, _ := coords[1].(float64)
+	return int(x), int(y), nil
+}
+
+func pointerMove(x, y, duration int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "pointerMove",
+		"x":        x,
+		"y":        y,
+		"duration": duration,
+		"origin":   "viewport",
+	}
+}
+
+// performPointerActions runs a W3C mouse action sequence and releases any pressed buttons afterwards
+func performPointerActions(wd selenium.WebDriver, actions []map[string]interface{}) error {
+	err := webDriverCommand(wd, http.MethodPost, "/actions", map[string]interface{}{
+		"actions": []interface{}{
+			map[string]interface{}{
+				"type":       "pointer",
+				"id":         "mouse",
+				"parameters": map[string]string{"pointerType": "mouse"},
+				"actions":    actions,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return webDriverCommand(wd, http.MethodDelete, "/actions", nil)
+}
+
+// webDriverCommand sends a raw command to the current WebDriver session for
+// endpoints the selenium client doesn't wrap (W3C actions, Firefox extensions)
+func webDriverCommand(wd selenium.WebDriver, method, path string, params interface{}) error {
+	_, err := webDriverRequest(wd, method, path, params)
+	return err
+}
+
+// webDriverRequest is webDriverCommand returning the decoded "value" of the response
+func webDriverRequest(wd selenium.WebDriver, method, path string, params interface{}) (json.RawMessage, error) {
+	var body io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	url := fmt.Sprintf("http://localhost:%d/session/%s%s", driverPort(wd), wd.SessionID(), path)
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		Value json.RawMessage `json:"value"`
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("bad webdriver response: %s", data)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(reply.Value, &failure)
+		return nil, fmt.Errorf("%s: %s", failure.Error, failure.Message)
+	}
+
+	return reply.Value, nil
+}
+
+const html5DragAndDropJS = `
+	var source = arguments[0], target = arguments[1];
+	var dataTransfer = new DataTransfer();
+	function fire(el, type, Ctor) {
+		var rect = el.getBoundingClientRect();
+		var init = {
+			bubbles: true, cancelable: true, view: window,
+			clientX: rect.left + rect.width / 2, clientY: rect.top + rect.height / 2
+		};
+		if (Ctor === DragEvent) { init.dataTransfer = dataTransfer; }
+		el.dispatchEvent(new Ctor(type, init));
+	}
+	fire(source, 'pointerdown', PointerEvent);
+	fire(source, 'mousedown', MouseEvent);
+	fire(source, 'dragstart', DragEvent);
+	fire(source, 'drag', DragEvent);
+	fire(target, 'dragenter', DragEvent);
+	fire(target, 'dragover', DragEvent);
+	fire(target, 'drop', DragEvent);
+	fire(source, 'dragend', DragEvent);
+	fire(target, 'pointerup', PointerEvent);
+	fire(target, 'mouseup', MouseEvent);
+`
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// apiBodyLimit caps each captured request and response body, so a large
+// payload doesn't crowd out the page
+const apiBodyLimit = 32 << 10
+
+// APIResponse is a fetch or XHR request the page made and its response,
+// for --capture-api
+type APIResponse struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	RequestBody string `json:"request_body,omitempty"`
+	Body        string `json:"body,omitempty"`
+	// Size is the length of the decoded response body, before the cap
+	Size      int  `json:"size"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// isAPIRequest reports whether the browser made a request from a script,
+// with fetch() or XMLHttpRequest, rather than for a document or asset
+func isAPIRequest(header http.Header) bool {
+	return header.Get("Sec-Fetch-Dest") == "empty"
+}
+
+// capturedAPIResponses picks the fetch and XHR exchanges out of the recorded
+// traffic, those whose URL matches pattern when one is given
+func capturedAPIResponses(exchanges []*Exchange, pattern *regexp.Regexp) []APIResponse {
+	var captured []APIResponse
+	for _, exchange := range exchanges {
+		if !isAPIRequest(exchange.RequestHeader) || pattern != nil && !pattern.MatchString(exchange.URL) {
+			continue
+		}
+		response := APIResponse{
+			Method: exchange.Method,
+			URL:    exchange.URL,
+			Status: exchange.StatusCode,
+		}
+		if exchange.Err != nil {
+			response.Error = exchange.Err.Error()
+			captured = append(captured, response)
+			continue
+		}
+		response.ContentType = exchange.ResponseHeader.Get("Content-Type")
+		response.RequestBody, _, _ = apiBody(exchange.RequestHeader.Get("Content-Type"), exchange.RequestBody)
+
+		body := exchange.ResponseBody
+		decoded, err := decodeBody(exchange.ResponseHeader.Get("Content-Encoding"), body)
+		if err != nil {
+			response.Body = fmt.Sprintf("(%v)", err)
+		} else {
+			response.Body, response.Size, response.Truncated = apiBody(response.ContentType, decoded)
+		}
+		captured = append(captured, response)
+	}
+	return captured
+}
+
+// decodeBody undoes the Content-Encoding of a body as the server sent it
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("bad gzip body: %v", err)
+		}
+		reader = gz
+	case "deflate":
+		// Servers send deflate both with and without the zlib wrapper
+		if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			reader = zr
+		} else {
+			reader = flate.NewReader(bytes.NewReader(body))
+		}
+	default:
+		return nil, fmt.Errorf("%s-encoded body not shown", encoding)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s body: %v", encoding, err)
+	}
+	return decoded, nil
+}
+
+// apiBody renders a body for output: JSON pretty-printed, other text as is
+// and binary content left out, capped at apiBodyLimit. It also returns the
+// body's length and whether it was cut.
+func apiBody(contentType string, body []byte) (string, int, bool) {
+	size := len(body)
+	if size == 0 {
+		return "", 0, false
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			body = indented.Bytes()
+		}
+	} else if !utf8.Valid(body) {
+		return fmt.Sprintf("(%s of binary content)", formatBytes(int64(size))), size, false
+	}
+	if len(body) > apiBodyLimit {
+		// Cut on a character boundary
+		cut := apiBodyLimit
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		return string(body[:cut]), size, true
+	}
+	return string(body), size, false
+}
+
+func formatAPIResponses(responses []APIResponse) string {
+	if len(responses) == 0 {
+		return "No API requests\n"
+	}
+	var b strings.Builder
+	for i, response := range responses {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		outcome := response.Error
+		if response.Error == "" {
+			outcome = fmt.Sprintf("%d %s", response.Status, http.StatusText(response.Status))
+		}
+		fmt.Fprintf(&b, "%s %s %s", response.Method, response.URL, outcome)
+		if response.ContentType != "" {
+			fmt.Fprintf(&b, " (%s, %s)", response.ContentType, formatBytes(int64(response.Size)))
+		}
+		b.WriteString("\n")
+		if response.RequestBody != "" {
+			fmt.Fprintf(&b, "Request: %s\n", response.RequestBody)
+		}
+		if response.Body != "" {
+			b.WriteString(response.Body)
+			if !strings.HasSuffix(response.Body, "\n") {
+				b.WriteString("\n")
+			}
+			if response.Truncated {
+				fmt.Fprintf(&b, "... (truncated at %s)\n", formatBytes(apiBodyLimit))
+			}
+		}
+	}
+	return b.String()
+}
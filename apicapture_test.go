@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCapturedAPIResponses(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(`{"users":[{"id":1}]}`))
+	gz.Close()
+
+	exchanges := []*Exchange{
+		{
+			Method: "GET", URL: "https://example.com/dashboard", StatusCode: 200,
+			RequestHeader:  http.Header{"Sec-Fetch-Dest": {"document"}},
+			ResponseHeader: http.Header{"Content-Type": {"text/html"}},
+			ResponseBody:   []byte("<html></html>"),
+		},
+		{
+			Method: "GET", URL: "https://example.com/api/users", StatusCode: 200,
+			RequestHeader:  http.Header{"Sec-Fetch-Dest": {"empty"}},
+			ResponseHeader: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+			ResponseBody:   gzipped.Bytes(),
+		},
+		{
+			Method: "POST", URL: "https://example.com/api/search", StatusCode: 200,
+			RequestHeader:  http.Header{"Sec-Fetch-Dest": {"empty"}, "Content-Type": {"application/json"}},
+			RequestBody:    []byte(`{"q":"go"}`),
+			ResponseHeader: http.Header{"Content-Type": {"text/plain"}},
+			ResponseBody:   []byte(strings.Repeat("x", apiBodyLimit+10)),
+		},
+		{
+			Method: "GET", URL: "https://analytics.example.net/collect", StatusCode: 204,
+			RequestHeader:  http.Header{"Sec-Fetch-Dest": {"empty"}},
+			ResponseHeader: http.Header{},
+		},
+	}
+
+	captured := capturedAPIResponses(exchanges, regexp.MustCompile(`^https://example\.com/api/`))
+	if len(captured) != 2 {
+		t.Fatalf("Expected the two API requests, got %+v", captured)
+	}
+	if captured[0].Body != "{\n  \"users\": [\n    {\n      \"id\": 1\n    }\n  ]\n}" || captured[0].Size != 20 {
+		t.Errorf("Expected the gzipped JSON decoded and pretty-printed, got %q (%d bytes)", captured[0].Body, captured[0].Size)
+	}
+	if search := captured[1]; !search.Truncated || len(search.Body) != apiBodyLimit || search.Size != apiBodyLimit+10 || search.RequestBody != "{\n  \"q\": \"go\"\n}" {
+		t.Errorf("Expected the request body and a capped response, got truncated=%v, %d of %d bytes, request %q", search.Truncated, len(search.Body), search.Size, search.RequestBody)
+	}
+
+	if all := capturedAPIResponses(exchanges, nil); len(all) != 3 {
+		t.Errorf("Expected every fetch and XHR request without a pattern, got %d", len(all))
+	}
+
+	output := formatAPIResponses(captured)
+	for _, want := range []string{"GET https://example.com/api/users 200 OK (application/json, 20 B)", "Request: {", "... (truncated at 32.0 KB)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in:\n%s", want, output)
+		}
+	}
+
+	if body, _, _ := apiBody("application/octet-stream", []byte{0xff, 0xfe, 0x00}); body != "(3 B of binary content)" {
+		t.Errorf("Expected binary content left out, got %q", body)
+	}
+}
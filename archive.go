@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// cssReference matches url() references and @import with a bare string
+var cssReference = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')|url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+// snapshotJS serializes a copy of the rendered document for archiving: form
+// state is written back into attributes, canvases become images, responsive
+// images are pinned to the source the browser chose, scripts are dropped so
+// the copy doesn't re-render itself, and links are made absolute.
+const snapshotJS = `
+	var clone = document.documentElement.cloneNode(true);
+	var live = document.documentElement.querySelectorAll('*');
+	var copies = clone.querySelectorAll('*');
+	for (var i = 0; i < live.length && i < copies.length; i++) {
+		var el = live[i], copy = copies[i];
+		switch (el.tagName) {
+		case 'INPUT':
+			if (el.type === 'checkbox' || el.type === 'radio') {
+				el.checked ? copy.setAttribute('checked', '') : copy.removeAttribute('checked');
+			} else if (el.type !== 'password' && el.type !== 'file') {
+				copy.setAttribute('value', el.value);
+			}
+			break;
+		case 'TEXTAREA':
+			copy.textContent = el.value;
+			break;
+		case 'OPTION':
+			el.selected ? copy.setAttribute('selected', '') : copy.removeAttribute('selected');
+			break;
+		case 'IMG':
+			if (el.currentSrc) {
+				copy.setAttribute('src', el.currentSrc);
+			}
+			copy.removeAttribute('srcset');
+			copy.removeAttribute('loading');
+			break;
+		case 'CANVAS':
+			try {
+				var img = document.createElement('img');
+				img.src = el.toDataURL();
+				img.width = el.width;
+				img.height = el.height;
+				copy.replaceWith(img);
+			} catch (e) {}
+			break;
+		case 'STYLE':
+			// Rules inserted through the CSSOM never show up in the markup
+			try {
+				var rules = Array.from(el.sheet.cssRules).map(function(rule) { return rule.cssText; }).join('\n');
+				if (rules && !el.textContent.trim()) {
+					copy.textContent = rules;
+				}
+			} catch (e) {}
+			break;
+		case 'A':
+		case 'AREA':
+			if (el.getAttribute('href') && el.href) {
+				copy.setAttribute('href', el.href);
+			}
+			break;
+		case 'LINK':
+			if (el.href) {
+				copy.setAttribute('href', el.href);
+			}
+			break;
+		case 'FORM':
+			if (el.getAttribute('action')) {
+				copy.setAttribute('action', el.action);
+			}
+			break;
+		}
+	}
+	clone.querySelectorAll('script, noscript, base, picture > source, link[rel~="preload" i], link[rel~="modulepreload" i]').forEach(function(el) {
+		el.remove();
+	});
+	clone.querySelectorAll('*').forEach(function(el) {
+		Array.from(el.attributes).forEach(function(attr) {
+			if (/^on/i.test(attr.name)) {
+				el.removeAttribute(attr.name);
+			}
+		});
+	});
+	return {html: '<!DOCTYPE html>\n' + clone.outerHTML, url: document.URL, title: document.title, userAgent: navigator.userAgent};
+`
+
+// pageSnapshot is the serialized DOM from snapshotJS
+type pageSnapshot struct {
+	HTML      string
+	URL       *url.URL
+	Title     string
+	UserAgent string
+}
+
+func takeSnapshot(wd selenium.WebDriver) (pageSnapshot, error) {
+	raw, err := wd.ExecuteScript(snapshotJS, nil)
+	if err != nil {
+		return pageSnapshot{}, fmt.Errorf("could not serialize page: %v", err)
+	}
+	entry, _ := raw.(map[string]interface{})
+	snapshot := pageSnapshot{}
+	snapshot.HTML, _ = entry["html"].(string)
+	snapshot.Title, _ = entry["title"].(string)
+	snapshot.UserAgent, _ = entry["userAgent"].(string)
+	pageURL, _ := entry["url"].(string)
+	if snapshot.URL, err = url.Parse(pageURL); err != nil {
+		return pageSnapshot{}, fmt.Errorf("could not parse page URL: %v", err)
+	}
+	return snapshot, nil
+}
+
+// archiver downloads the assets a page references and rewrites the
+// references to wherever store put them (a data: URI, a MIME part...)
+type archiver struct {
+	client *http.Client
+	header http.Header
+	host   string
+	store  func(u *url.URL, contentType string, data []byte) string
+	refs   map[string]string
+
+	// fromStylesheet, if set, adjusts a reference from the page for use in a
+	// downloaded stylesheet, for stores that save assets in a subdirectory
+	fromStylesheet func(ref string) string
+}
+
+// newArchiver fetches assets as the browser would, with its user agent and,
+// for the page's own host, the cookies it holds
+func newArchiver(wd selenium.WebDriver, snapshot pageSnapshot, store func(*url.URL, string, []byte) string) *archiver {
+	header := http.Header{}
+	if snapshot.UserAgent != "" {
+		header.Set("User-Agent", snapshot.UserAgent)
+	}
+	header.Set("Referer", snapshot.URL.String())
+	if cookies, err := wd.GetCookies(); err == nil {
+		var pairs []string
+		for _, cookie := range cookies {
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+		if len(pairs) > 0 {
+			header.Set("Cookie", strings.Join(pairs, "; "))
+		}
+	}
+
+	return &archiver{
+		client: &http.Client{Timeout: 30 * time.Second},
+		header: header,
+		host:   snapshot.URL.Host,
+		store:  store,
+		refs:   map[string]string{},
+	}
+}
+
+// asset returns the reference to use in place of ref, found relative to
+// base. Assets that can't be fetched keep their absolute URL.
+func (a *archiver) asset(ref string, base *url.URL) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ref
+	}
+	u.Fragment = ""
+	key := u.String()
+	if stored, ok := a.refs[key]; ok {
+		return stored
+	}
+	// Stylesheets that import each other fall back to the URL instead of looping
+	a.refs[key] = key
+
+	data, contentType, err := a.fetch(u)
+	if err != nil {
+		logf("Warning: Could not save %s: %v\n", key, err)
+		return key
+	}
+	if strings.HasPrefix(contentType, "text/css") {
+		data = []byte(a.rewriteCSS(string(data), u, a.fromStylesheet))
+	}
+	a.refs[key] = a.store(u, contentType, data)
+	return a.refs[key]
+}
+
+func (a *archiver) fetch(u *url.URL) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header = a.header.Clone()
+	if u.Host != a.host {
+		req.Header.Del("Cookie")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExtension := mime.TypeByExtension(path.Ext(u.Path)); byExtension != "" {
+			contentType, _, _ = mime.ParseMediaType(byExtension)
+		} else {
+			contentType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+		}
+	}
+	return data, contentType, nil
+}
+
+// rewriteCSS replaces the url() and @import references in css, passing
+// each new reference through adjust when it is set
+func (a *archiver) rewriteCSS(css string, base *url.URL, adjust func(string) string) string {
+	return cssReference.ReplaceAllStringFunc(css, func(match string) string {
+		groups := cssReference.FindStringSubmatch(match)
+		ref := strings.Join(groups[1:], "")
+		if strings.HasPrefix(ref, "data:") {
+			return match
+		}
+		ref = a.asset(ref, base)
+		if adjust != nil {
+			ref = adjust(ref)
+		}
+		if strings.HasPrefix(match, "@import") {
+			return fmt.Sprintf("@import url(%q)", ref)
+		}
+		return fmt.Sprintf("url(%q)", ref)
+	})
+}
+
+// rewriteDocument replaces every stylesheet, icon, image and CSS reference in
+// the snapshot and returns the resulting HTML
+func (a *archiver) rewriteDocument(snapshot pageSnapshot) (string, error) {
+	doc, err := html.Parse(strings.NewReader(snapshot.HTML))
+	if err != nil {
+		return "", fmt.Errorf("could not parse page: %v", err)
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			a.rewriteElement(n, snapshot.URL)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	var b bytes.Buffer
+	if err := html.Render(&b, doc); err != nil {
+		return "", fmt.Errorf("could not render page: %v", err)
+	}
+	return b.String(), nil
+}
+
+func (a *archiver) rewriteElement(n *html.Node, base *url.URL) {
+	for i, attr := range n.Attr {
+		switch {
+		case attr.Key == "style":
+			n.Attr[i].Val = a.rewriteCSS(attr.Val, base, nil)
+		case attr.Key == "href" && n.DataAtom == atom.Link && savedLink(getAttr(n, "rel")):
+			n.Attr[i].Val = a.asset(attr.Val, base)
+		case attr.Key == "src" && (n.DataAtom == atom.Img || n.DataAtom == atom.Input):
+			n.Attr[i].Val = a.asset(attr.Val, base)
+		case attr.Key == "poster" && n.DataAtom == atom.Video:
+			n.Attr[i].Val = a.asset(attr.Val, base)
+		case (attr.Key == "href" || attr.Key == "xlink:href") && n.DataAtom == atom.Image:
+			n.Attr[i].Val = a.asset(attr.Val, base)
+		}
+	}
+	if n.DataAtom == atom.Style && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+		n.FirstChild.Data = a.rewriteCSS(n.FirstChild.Data, base, nil)
+	}
+}
+
+// savedLink reports whether a <link> with rel is needed to render the page
+func savedLink(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		switch value {
+		case "stylesheet", "icon", "apple-touch-icon", "mask-icon":
+			return true
+		}
+	}
+	return false
+}
+
+// savePage archives the rendered page at path: as MHTML when path ends in
+// .mhtml or .mht, otherwise as a single HTML file with every asset inlined
+// as a data: URI
+func savePage(wd selenium.WebDriver, filePath string) error {
+	snapshot, err := takeSnapshot(wd)
+	if err != nil {
+		return err
+	}
+
+	var output []byte
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".mhtml", ".mht":
+		output, err = buildMHTML(wd, snapshot)
+	default:
+		a := newArchiver(wd, snapshot, func(u *url.URL, contentType string, data []byte) string {
+			return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		})
+		var page string
+		page, err = a.rewriteDocument(snapshot)
+		output = []byte(fmt.Sprintf("<!-- saved from url=%s on %s -->\n%s", snapshot.URL, time.Now().UTC().Format(time.RFC3339), page))
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filePath, output, 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", filePath, err)
+	}
+	return nil
+}
+
+// archivePage saves the rendered page as dir/index.html with the assets it
+// references downloaded to dir/assets, so the copy can be browsed offline.
+// It returns the number of assets saved.
+func archivePage(wd selenium.WebDriver, dir string) (int, error) {
+	snapshot, err := takeSnapshot(wd)
+	if err != nil {
+		return 0, err
+	}
+	assetDir := filepath.Join(dir, "assets")
+	if err := os.MkdirAll(assetDir, 0755); err != nil {
+		return 0, fmt.Errorf("could not create %s: %v", assetDir, err)
+	}
+
+	saved := map[string]bool{}
+	var storeErr error
+	a := newArchiver(wd, snapshot, func(u *url.URL, contentType string, data []byte) string {
+		name := assetFileName(u, contentType, saved)
+		saved[name] = true
+		if err := os.WriteFile(filepath.Join(assetDir, name), data, 0644); err != nil && storeErr == nil {
+			storeErr = fmt.Errorf("could not save %s: %v", name, err)
+		}
+		return "assets/" + name
+	})
+	// Stylesheets are saved next to the assets they reference
+	a.fromStylesheet = func(ref string) string {
+		return strings.TrimPrefix(ref, "assets/")
+	}
+
+	page, err := a.rewriteDocument(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	if storeErr != nil {
+		return 0, storeErr
+	}
+	index := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(index, []byte(page), 0644); err != nil {
+		return 0, fmt.Errorf("could not write %s: %v", index, err)
+	}
+	return len(saved), nil
+}
+
+// assetFileName names a downloaded asset after the last segment of its URL
+// path, with an extension for its content type if it has none and a number
+// added if the name is already taken
+func assetFileName(u *url.URL, contentType string, taken map[string]bool) string {
+	name := unsafeFileChars.ReplaceAllString(path.Base(u.Path), "_")
+	if name == "_" || name == "." || name == "" {
+		name = "asset"
+	}
+	ext := path.Ext(name)
+	if ext == "" {
+		if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+			ext = extensions[0]
+			name += ext
+		}
+	}
+
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	return name
+}
+
+// mhtmlPart is one resource of an MHTML archive
+type mhtmlPart struct {
+	location    string
+	contentType string
+	data        []byte
+}
+
+// buildMHTML packs the page and its assets as multipart/related parts that
+// reference each other by URL, the layout browsers save with "Web Page,
+// Single File"
+func buildMHTML(wd selenium.WebDriver, snapshot pageSnapshot) ([]byte, error) {
+	var parts []mhtmlPart
+	a := newArchiver(wd, snapshot, func(u *url.URL, contentType string, data []byte) string {
+		parts = append(parts, mhtmlPart{location: u.String(), contentType: contentType, data: data})
+		return u.String()
+	})
+	page, err := a.rewriteDocument(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	parts = append([]mhtmlPart{{location: snapshot.URL.String(), contentType: "text/html", data: []byte(page)}}, parts...)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Location", part.location)
+		contentType := part.contentType
+		textual := strings.HasPrefix(contentType, "text/") || strings.HasSuffix(contentType, "+xml")
+		if textual {
+			contentType += "; charset=utf-8"
+			header.Set("Content-Transfer-Encoding", "quoted-printable")
+		} else {
+			header.Set("Content-Transfer-Encoding", "base64")
+		}
+		header.Set("Content-Type", contentType)
+
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if textual {
+			qp := quotedprintable.NewWriter(w)
+			qp.Write(part.data)
+			qp.Close()
+		} else {
+			encoded := base64.StdEncoding.EncodeToString(part.data)
+			for len(encoded) > 76 {
+				fmt.Fprintf(w, "%s\r\n", encoded[:76])
+				encoded = encoded[76:]
+			}
+			fmt.Fprintf(w, "%s\r\n", encoded)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: <Saved by web>\r\n")
+	fmt.Fprintf(&b, "Snapshot-Content-Location: %s\r\n", snapshot.URL)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", snapshot.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/related;\r\n\ttype=\"text/html\";\r\n\tboundary=\"%s\"\r\n\r\n", writer.Boundary())
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
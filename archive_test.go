@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestArchiverRewriteDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/css/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`@import "theme.css"; body { background: url(../img/bg.png) }`))
+		case "/css/theme.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`h1 { color: red }`))
+		case "/img/bg.png", "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("PNG"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	pageURL, _ := url.Parse(server.URL + "/page")
+	snapshot := pageSnapshot{
+		URL: pageURL,
+		HTML: `<!DOCTYPE html><html><head><link rel="stylesheet" href="/css/site.css"></head>
+<body><img src="/logo.png"><img src="/missing.png"><div style="background: url('/logo.png')"></div></body></html>`,
+	}
+
+	a := &archiver{
+		client: server.Client(),
+		header: http.Header{},
+		host:   pageURL.Host,
+		refs:   map[string]string{},
+		store: func(u *url.URL, contentType string, data []byte) string {
+			return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		},
+	}
+	page, err := a.rewriteDocument(snapshot)
+	if err != nil {
+		t.Fatalf("rewriteDocument returned error: %v", err)
+	}
+
+	png := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("PNG"))
+	theme := "data:text/css;base64," + base64.StdEncoding.EncodeToString([]byte(`h1 { color: red }`))
+	site := "data:text/css;base64," + base64.StdEncoding.EncodeToString([]byte(`@import url("`+theme+`"); body { background: url("`+png+`") }`))
+
+	for _, expected := range []string{
+		`<link rel="stylesheet" href="` + site + `"/>`,
+		`<img src="` + png + `"/>`,
+		`<img src="` + server.URL + `/missing.png"/>`,
+		`style="background: url(&#34;` + png + `&#34;)"`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected %s in archived page. Got:\n%s", expected, page)
+		}
+	}
+}
+
+func TestAssetFileName(t *testing.T) {
+	taken := map[string]bool{"logo.png": true}
+	tests := []struct {
+		rawURL      string
+		contentType string
+		expected    string
+	}{
+		{"https://example.com/img/logo.png?v=3", "image/png", "logo-2.png"},
+		{"https://example.com/css/site.css", "text/css", "site.css"},
+		{"https://example.com/", "text/css", "asset.css"},
+		{"https://fonts.example.com/s/inter%20v12.woff2", "font/woff2", "inter_v12.woff2"},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.rawURL)
+		if name := assetFileName(u, test.contentType, taken); name != test.expected {
+			t.Errorf("assetFileName(%s) = %q, expected %q", test.rawURL, name, test.expected)
+		}
+	}
+}
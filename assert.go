@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// Assertion is a check on the final page from --assert-text, --assert-selector or --assert-title
+type Assertion struct {
+	Kind     string
+	Expected string
+}
+
+func (assertion Assertion) String() string {
+	if assertion.Kind == "selector" {
+		return "selector " + assertion.Expected
+	}
+	return fmt.Sprintf("%s %q", assertion.Kind, assertion.Expected)
+}
+
+// AssertionResult is the outcome of an assertion; Err explains a failure
+type AssertionResult struct {
+	Assertion Assertion
+	Err       error
+}
+
+// checkAssertions evaluates each assertion against the current page once,
+// without waiting (use the --wait-for flags to hold the capture until the
+// page is ready). It returns an error naming the failed assertions, if any.
+func checkAssertions(wd selenium.WebDriver, config Config, assertions []Assertion) ([]AssertionResult, error) {
+	var results []AssertionResult
+	var failed []string
+	for _, assertion := range assertions {
+		err := checkAssertion(wd, config, assertion)
+		results = append(results, AssertionResult{Assertion: assertion, Err: err})
+		if err != nil {
+			failed = append(failed, assertion.String())
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d assertion(s) failed: %s", len(failed), len(assertions), strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+func checkAssertion(wd selenium.WebDriver, config Config, assertion Assertion) error {
+	switch assertion.Kind {
+	case "text":
+		body, err := findElement(wd, config, "body")
+		if err != nil {
+			return fmt.Errorf("page has no body")
+		}
+		text, err := body.Text()
+		if err != nil {
+			return fmt.Errorf("could not read page text: %v", err)
+		}
+		if !strings.Contains(text, assertion.Expected) {
+			return fmt.Errorf("text not found on page")
+		}
+	case "selector":
+		elems, err := findElements(wd, config, assertion.Expected)
+		if err != nil || len(elems) == 0 {
+			return fmt.Errorf("no elements match")
+		}
+	case "title":
+		title, err := wd.Title()
+		if err != nil {
+			return fmt.Errorf("could not read title: %v", err)
+		}
+		if !strings.Contains(title, assertion.Expected) {
+			return fmt.Errorf("title is %q", title)
+		}
+	default:
+		return fmt.Errorf("unknown assertion: %s", assertion.Kind)
+	}
+	return nil
+}
+
+func formatAssertionResults(results []AssertionResult) string {
+	var b strings.Builder
+	passed := 0
+	for _, result := range results {
+		if result.Err == nil {
+			passed++
+			fmt.Fprintf(&b, "PASS %s\n", result.Assertion)
+		} else {
+			fmt.Fprintf(&b, "FAIL %s (%v)\n", result.Assertion, result.Err)
+		}
+	}
+
+	verdict := "PASS"
+	if passed < len(results) {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(&b, "\n%s: %d of %d assertions passed\n", verdict, passed, len(results))
+	return b.String()
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatAssertionResults(t *testing.T) {
+	results := []AssertionResult{
+		{Assertion: Assertion{Kind: "title", Expected: "Dashboard"}},
+		{Assertion: Assertion{Kind: "selector", Expected: "#chart"}, Err: errors.New("no elements match")},
+	}
+
+	expected := "PASS title \"Dashboard\"\nFAIL selector #chart (no elements match)\n\nFAIL: 1 of 2 assertions passed\n"
+	if got := formatAssertionResults(results); got != expected {
+		t.Errorf("formatAssertionResults() = %q, expected %q", got, expected)
+	}
+}
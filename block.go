@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// blockTypes are the kinds of request --block can turn away
+var blockTypes = []string{"images", "fonts", "media", "stylesheets"}
+
+// fetchDestTypes maps the Sec-Fetch-Dest browsers send to a block type
+var fetchDestTypes = map[string]string{
+	"image": "images",
+	"font":  "fonts",
+	"audio": "media",
+	"video": "media",
+	"track": "media",
+	"style": "stylesheets",
+}
+
+// extensionTypes maps file extensions to a block type, for requests that
+// come without a Sec-Fetch-Dest
+var extensionTypes = map[string]string{
+	".png": "images", ".jpg": "images", ".jpeg": "images", ".gif": "images", ".webp": "images",
+	".avif": "images", ".svg": "images", ".ico": "images", ".bmp": "images",
+	".woff": "fonts", ".woff2": "fonts", ".ttf": "fonts", ".otf": "fonts", ".eot": "fonts",
+	".mp4": "media", ".webm": "media", ".ogg": "media", ".mp3": "media", ".wav": "media",
+	".m4a": "media", ".m3u8": "media", ".vtt": "media",
+	".css": "stylesheets",
+}
+
+// parseBlockTypes parses a comma-separated --block list
+func parseBlockTypes(value string) ([]string, error) {
+	var types []string
+	for _, kind := range strings.Split(value, ",") {
+		kind = strings.TrimSpace(kind)
+		if !slices.Contains(blockTypes, kind) {
+			return nil, fmt.Errorf("--block takes a comma-separated list of %s, got %q", strings.Join(blockTypes, ", "), kind)
+		}
+		types = append(types, kind)
+	}
+	return types, nil
+}
+
+// resourceType is the block type of a request, or "" for documents,
+// scripts, fetches and anything else the page's text may depend on
+func resourceType(req *http.Request) string {
+	if dest := req.Header.Get("Sec-Fetch-Dest"); dest != "" {
+		return fetchDestTypes[dest]
+	}
+	return extensionTypes[strings.ToLower(path.Ext(req.URL.Path))]
+}
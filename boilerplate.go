@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tebeka/selenium"
+)
+
+// boilerplateSelectors match page chrome that repeats across a site rather
+// than being part of the page's content
+var boilerplateSelectors = []string{
+	"nav", "header", "footer", "aside",
+	"[role=navigation]", "[role=banner]", "[role=contentinfo]", "[role=complementary]", "[role=search]",
+}
+
+// detachJS takes the elements matching the --remove selectors and, with
+// --strip-boilerplate, the page's chrome out of the document, leaving an
+// empty text node in each one's place so restoreJS can put it back. Headers
+// and footers of articles are content and stay; so does anything wrapping the
+// page's main content.
+const detachJS = `
+	var selectors = arguments[0], boilerplate = arguments[1], strip = arguments[2];
+	var targets = [];
+	for (var i = 0; i < selectors.length; i++) {
+		try {
+			targets.push.apply(targets, document.querySelectorAll(selectors[i]));
+		} catch (e) {
+			return { error: 'invalid selector ' + selectors[i] };
+		}
+	}
+
+	if (strip) {
+		var wrapsContent = function(el) {
+			return !!el.querySelector('main, article, [role=main]');
+		};
+		document.querySelectorAll(boilerplate.join(', ')).forEach(function(el) {
+			var tag = el.tagName.toLowerCase();
+			if ((tag === 'header' || tag === 'footer') && el.parentElement && el.parentElement.closest('article, main, [role=main], section')) {
+				return;
+			}
+			if (!wrapsContent(el)) { targets.push(el); }
+		});
+		// Cookie and consent banners: overlays named for what they ask
+		document.querySelectorAll('[id*=cookie i], [class*=cookie i], [id*=consent i], [class*=consent i], [aria-label*=cookie i], [aria-label*=consent i]').forEach(function(el) {
+			var style = getComputedStyle(el);
+			var overlay = style.position === 'fixed' || style.position === 'sticky' || el.getAttribute('role') === 'dialog' || el.getAttribute('aria-modal') === 'true';
+			if (overlay && !wrapsContent(el)) { targets.push(el); }
+		});
+		// Skip links only make sense for keyboard navigation of the live page
+		document.querySelectorAll('a[href^="#"]').forEach(function(a) {
+			if (/^skip\b/i.test((a.textContent || '').trim())) { targets.push(a); }
+		});
+	}
+
+	window.__webDetached = [];
+	var removed = 0;
+	targets.forEach(function(el) {
+		// Already gone with an ancestor
+		if (!el.isConnected || el === document.body || el === document.documentElement) { return; }
+		var placeholder = document.createTextNode('');
+		el.replaceWith(placeholder);
+		window.__webDetached.push([placeholder, el]);
+		removed++;
+	});
+	return { removed: removed };
+`
+
+// restoreJS puts detached elements back, innermost first
+const restoreJS = `
+	var detached = window.__webDetached || [];
+	for (var i = detached.length - 1; i >= 0; i--) {
+		detached[i][0].replaceWith(detached[i][1]);
+	}
+	delete window.__webDetached;
+`
+
+// detachRemoved takes the elements --remove and --strip-boilerplate leave out
+// of the capture out of the page, returning a function that restores them, so
+// that everything after the capture (links, metadata, saved pages) still sees
+// the whole page
+func detachRemoved(wd selenium.WebDriver, config Config) (func(), error) {
+	raw, err := wd.ExecuteScript(detachJS, []interface{}{append([]string{}, config.Remove...), boilerplateSelectors, config.StripBoilerplate})
+	if err != nil {
+		return nil, fmt.Errorf("could not remove elements: %v", err)
+	}
+	result, _ := raw.(map[string]interface{})
+	if message, ok := result["error"].(string); ok {
+		return nil, fmt.Errorf("--remove: %s", message)
+	}
+	return func() {
+		if _, err := wd.ExecuteScript(restoreJS, nil); err != nil {
+			logf("Warning: Could not restore removed elements: %v\n", err)
+		}
+	}, nil
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runBrowser implements `web browser <status|update|clean>`, managing the
+// downloaded browsers and the profiles kept next to them. It returns the
+// process exit code.
+func runBrowser(args []string) int {
+	engine := ""
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printBrowserHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--engine", kind: flagString, apply: func(value string) error {
+			if !slices.Contains(engines, value) {
+				return fmt.Errorf("--engine must be one of %s, got %q", strings.Join(engines, ", "), value)
+			}
+			engine = value
+			return nil
+		}},
+		dataDirFlag,
+	}
+	var command string
+	err := parseFlags(args, defs, func(arg string) error {
+		if command != "" {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		if arg != "status" && arg != "update" && arg != "clean" {
+			return fmt.Errorf("unknown command %q, expected status, update or clean", arg)
+		}
+		command = arg
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web browser --help' for usage.\n", err)
+		return 1
+	}
+	if command == "" {
+		printBrowserHelp()
+		return 1
+	}
+
+	// Without --engine, work on every engine that has a directory
+	selected := []string{engine}
+	if engine == "" {
+		selected = nil
+		for _, name := range engines {
+			if dir, err := engineDir(name); err == nil {
+				if _, err := os.Stat(dir); err == nil {
+					selected = append(selected, name)
+				}
+			}
+		}
+		if len(selected) == 0 && command == "update" {
+			selected = []string{"firefox"}
+		}
+	}
+
+	code := 0
+	for _, name := range selected {
+		var err error
+		switch command {
+		case "status":
+			err = browserStatus(name)
+		case "update":
+			err = browserUpdate(name)
+		case "clean":
+			err = browserClean(name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+			code = 1
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("No browsers installed yet")
+	}
+	return code
+}
+
+// browserUpdate brings an engine's managed browser to the pinned version,
+// replacing whatever build is installed
+func browserUpdate(engine string) error {
+	switch engine {
+	case "chromium":
+		dir, err := engineDir(engine)
+		if err != nil {
+			return err
+		}
+		// Installs without a version marker can't be told apart from stale ones
+		for _, sub := range []string{"chrome", "chromedriver"} {
+			if chromiumInstalled(filepath.Join(dir, sub)) != CHROMIUM_VERSION {
+				os.RemoveAll(filepath.Join(dir, sub))
+			}
+		}
+		if err := ensureChromium(Config{Engine: engine}); err != nil {
+			return err
+		}
+		fmt.Printf("Chromium %s is installed\n", CHROMIUM_VERSION)
+	case "webkit":
+		fmt.Println("WebKit comes with the system, update it with the system's package manager")
+	default:
+		// Pinning the build replaces installs from before the build marker
+		build, checksum := os.Getenv("WEB_FIREFOX_BUILD"), os.Getenv("WEB_FIREFOX_SHA256")
+		if build == "" {
+			build = FIREFOX_BUILD
+		}
+		if err := ensureFirefox(build, checksum, ""); err != nil {
+			return err
+		}
+		if err := ensureGeckodriver(); err != nil {
+			return err
+		}
+		fmt.Printf("Firefox build %s is installed\n", build)
+	}
+	return nil
+}
+
+// browserStatus reports what is installed for an engine and the disk space
+// its builds and profiles take
+func browserStatus(engine string) error {
+	dir, err := engineDir(engine)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type item struct {
+		label string
+		size  int64
+	}
+	var items []item
+	var total int64
+	add := func(label, path string) {
+		size := diskUsage(path)
+		items = append(items, item{label, size})
+		total += size
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case engine == "firefox" && entry.Name() == "firefox":
+			add("Firefox build "+orUnknown(firefoxInstalled(dir)), path)
+		case engine == "firefox" && entry.Name() == "geckodriver":
+			add("geckodriver", path)
+		case engine == "chromium" && entry.Name() == "chrome":
+			add("Chromium "+orUnknown(chromiumInstalled(path)), path)
+		case engine == "chromium" && entry.Name() == "chromedriver":
+			add("chromedriver "+orUnknown(chromiumInstalled(path)), path)
+		case entry.Name() == "profiles":
+			profiles, _ := os.ReadDir(path)
+			for _, profile := range profiles {
+				if strings.HasSuffix(profile.Name(), ".lock") {
+					continue
+				}
+				label := "profile " + profile.Name()
+				if name, ok := strings.CutSuffix(profile.Name(), ".enc"); ok && !profile.IsDir() {
+					label = "profile " + name + " (encrypted)"
+				} else if name, ok := strings.CutSuffix(profile.Name(), ".cookies.json"); ok && !profile.IsDir() {
+					label = "profile " + name + " (cookie jar)"
+				}
+				add(label, filepath.Join(path, profile.Name()))
+			}
+		case entry.Type()&fs.ModeSocket != 0:
+			// Daemon sockets take no space
+		default:
+			add(entry.Name(), path)
+		}
+	}
+
+	fmt.Printf("%s (%s)\n", dir, formatBytes(total))
+	for _, item := range items {
+		fmt.Printf("  %-40s %10s\n", item.label, formatBytes(item.size))
+	}
+	return nil
+}
+
+// browserClean removes what an engine's directory no longer needs: builds
+// other than the pinned one, partial installs, profile copies and sockets
+// left by daemons that didn't shut down cleanly, and interrupted downloads
+func browserClean(engine string) error {
+	dir, err := engineDir(engine)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	switch engine {
+	case "firefox":
+		build := os.Getenv("WEB_FIREFOX_BUILD")
+		if build == "" {
+			build = FIREFOX_BUILD
+		}
+		firefoxDir := filepath.Join(dir, "firefox")
+		if _, err := os.Stat(firefoxDir); err == nil {
+			installed := firefoxInstalled(dir)
+			_, execErr := os.Stat(firefoxExecutable(firefoxDir))
+			if execErr != nil || installed != "" && installed != build {
+				stale = append(stale, firefoxDir)
+			}
+		}
+	case "chromium":
+		chromeExec, driverExec, err := chromiumPaths(dir)
+		if err != nil {
+			return err
+		}
+		for _, install := range []struct{ dest, exec string }{
+			{filepath.Join(dir, "chrome"), chromeExec},
+			{filepath.Join(dir, "chromedriver"), driverExec},
+		} {
+			if _, err := os.Stat(install.dest); err != nil {
+				continue
+			}
+			installed := chromiumInstalled(install.dest)
+			_, execErr := os.Stat(install.exec)
+			if execErr != nil || installed != "" && installed != CHROMIUM_VERSION {
+				stale = append(stale, install.dest)
+			}
+		}
+	}
+
+	// Daemons that are still running answer on their socket
+	running := func(socket string) bool {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}
+	sockets, _ := filepath.Glob(filepath.Join(dir, "daemon-*.sock*"))
+	for _, socket := range sockets {
+		if !running(socket) {
+			stale = append(stale, socket)
+		}
+	}
+	copies, _ := filepath.Glob(filepath.Join(dir, "profiles", "*.worker-*"))
+	for _, path := range copies {
+		profile := workerCopy.ReplaceAllString(filepath.Base(path), "")
+		if profile == filepath.Base(path) {
+			continue
+		}
+		if socket, err := daemonSocket(engine, profile); err == nil && !running(socket) {
+			stale = append(stale, path)
+		}
+	}
+
+	// Downloads in progress are recent, older ones were interrupted
+	downloads, _ := filepath.Glob(filepath.Join(os.TempDir(), "web-download-*"))
+	for _, path := range downloads {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > time.Hour {
+			stale = append(stale, path)
+		}
+	}
+
+	var freed int64
+	for _, path := range stale {
+		size := diskUsage(path)
+		if err := os.RemoveAll(path); err != nil {
+			logf("Warning: Could not remove %s: %v\n", path, err)
+			continue
+		}
+		freed += size
+		fmt.Printf("Removed %s (%s)\n", path, formatBytes(size))
+	}
+	fmt.Printf("%s: freed %s\n", dir, formatBytes(freed))
+	return nil
+}
+
+// workerCopy matches the suffix of a profile copied for a daemon's browser
+var workerCopy = regexp.MustCompile(`\.worker-\d+$`)
+
+// firefoxInstalled returns the build recorded in the Firefox directory under
+// dir, or "" when there is no marker
+func firefoxInstalled(dir string) string {
+	marker, err := os.ReadFile(filepath.Join(dir, "firefox", firefoxBuildMarker))
+	if err != nil {
+		return ""
+	}
+	if fields := strings.Fields(string(marker)); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func orUnknown(version string) string {
+	if version == "" {
+		return "(unknown)"
+	}
+	return version
+}
+
+// diskUsage adds up the size of the files under path
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// formatBytes renders a size in B, KB, MB or GB
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+func printBrowserHelp() {
+	fmt.Printf(`web browser - manage the downloaded browsers and their profiles
+
+Usage: web browser status [options]
+       web browser update [options]
+       web browser clean [options]
+
+Commands:
+  status                     Show the installed browser builds and the disk space they and each profile take
+  update                     Install the pinned browser version, replacing any other build: Firefox build %s
+                             (or WEB_FIREFOX_BUILD and WEB_FIREFOX_SHA256) and Chromium %s
+  clean                      Remove other builds, partial installs, interrupted downloads, and profile copies
+                             and sockets left by daemons that didn't shut down cleanly
+
+Options:
+  --help                     Show this help message
+  --engine <name>            Only this browser: firefox, chromium or webkit (default: every installed one)
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+`, FIREFOX_BUILD, CHROMIUM_VERSION)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBrowserClean(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".web-chromium")
+
+	write := func(path, content string) {
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "chrome", chromiumVersionMarker), "120.0.6099.109\n")
+	write(filepath.Join(dir, "profiles", "default", "cookies"), "kept")
+	write(filepath.Join(dir, "profiles", "default.worker-1", "cookies"), "copy")
+	write(filepath.Join(dir, "notes.txt"), "kept")
+
+	if err := browserClean("chromium"); err != nil {
+		t.Fatal(err)
+	}
+	for _, removed := range []string{"chrome", "profiles/default.worker-1"} {
+		if _, err := os.Stat(filepath.Join(dir, removed)); err == nil {
+			t.Errorf("Expected %s to be removed", removed)
+		}
+	}
+	for _, kept := range []string{"profiles/default/cookies", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", kept, err)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		2048:            "2.0 KB",
+		5 * 1 << 20:     "5.0 MB",
+		3 * 1 << 30 / 2: "1.5 GB",
+	}
+	for size, want := range tests {
+		if got := formatBytes(size); got != want {
+			t.Errorf("formatBytes(%d) = %q, expected %q", size, got, want)
+		}
+	}
+}
+
+func TestEngineDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("WEB_DATA_DIR", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	check := func(engine, want string) {
+		t.Helper()
+		got, err := engineDir(engine)
+		if err != nil {
+			t.Fatalf("engineDir(%q): %v", engine, err)
+		}
+		if got != want {
+			t.Errorf("engineDir(%q) = %s, expected %s", engine, got, want)
+		}
+	}
+
+	check("", filepath.Join(home, ".web-firefox"))
+
+	xdg := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", xdg)
+	check("chromium", filepath.Join(xdg, "web", "chromium"))
+	// An existing install stays where it is
+	os.MkdirAll(filepath.Join(home, ".web-chromium"), 0700)
+	check("chromium", filepath.Join(home, ".web-chromium"))
+
+	data := t.TempDir()
+	t.Setenv("WEB_DATA_DIR", data)
+	t.Setenv("HOME", "")
+	check("firefox", filepath.Join(data, "firefox"))
+
+	t.Setenv("WEB_DATA_DIR", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	if _, err := engineDir("firefox"); err == nil {
+		t.Errorf("Expected an error without HOME or a data directory")
+	}
+}
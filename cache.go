@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DEFAULT_CACHE_TTL = time.Hour
+
+// cacheDir is where --cache keeps the output of runs
+func cacheDir() (string, error) {
+	dir, err := engineDir("firefox")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache"), nil
+}
+
+// cacheKey identifies a run's output by its arguments, with their variables
+// expanded and without the cache flags themselves, and by what else the
+// output depends on: the profile, the engine, the data dir and the
+// credentials and proxy taken from the environment
+func cacheKey(config Config, args []string) string {
+	dataDir, _ := engineDir("firefox")
+	proxy := ""
+	if config.ProxyURL != nil {
+		proxy = config.ProxyURL.String()
+	}
+	parts := []string{
+		config.Profile,
+		config.Engine,
+		dataDir,
+		config.Bearer,
+		os.Getenv("WEB_PROFILE_PASSPHRASE"),
+		proxy,
+		strings.Join(config.ProxyBypass, ","),
+	}
+	for _, arg := range args {
+		if arg == "--cache" || arg == "--no-cache" || strings.HasPrefix(arg, "--cache=") {
+			continue
+		}
+		parts = append(parts, arg)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// uncacheableFlags are the flags set whose runs --cache can't stand in for,
+// as they act on the site or write files besides the output
+func uncacheableFlags(config Config) []string {
+	var flags []string
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"--form", len(config.Forms) > 0},
+		{"--click, --keys and the other actions", len(config.Actions) > 0},
+		{"--js", config.JSCode != ""},
+		{"--script", config.ScriptPath != ""},
+		{"--session", config.Session != ""},
+		{"--save-state", config.SaveState != ""},
+		{"--dump-cookies", config.DumpCookies},
+		{"--screenshot", config.ScreenshotPath != ""},
+		{"--save-page", config.SavePage != ""},
+		{"--archive-dir", config.ArchiveDir != ""},
+		{"--warc", config.WARCPath != ""},
+		{"--har", config.HARPath != ""},
+		{"--record-session", config.RecordSession != ""},
+		{"--diff-prev", config.DiffPrev},
+		{"--if-changed", config.IfChanged},
+		{"--manifest", config.ManifestPath != ""},
+		{"--poll-until-text", config.PollText != ""},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+	return flags
+}
+
+// readCache returns the output cached under key when it is younger than
+// ttl, along with its age
+func readCache(key string, ttl time.Duration) (string, time.Duration, bool) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", 0, false
+	}
+	path := filepath.Join(dir, key+".txt")
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) >= ttl {
+		return "", 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, false
+	}
+	return string(data), time.Since(info.ModTime()), true
+}
+
+// writeCache keeps a run's output under key
+func writeCache(key, result string) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create cache: %v", err)
+	}
+	// Written aside and renamed, so a concurrent run never reads half of it
+	path := filepath.Join(dir, key+".txt")
+	temp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(temp, []byte(result), 0644); err != nil {
+		return fmt.Errorf("could not write cache: %v", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("could not write cache: %v", err)
+	}
+	return nil
+}
+
+// clearCache removes every cached output, returning how many there were
+func clearCache() (int, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("could not read cache: %v", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("could not clear cache: %v", err)
+		}
+		if strings.HasSuffix(entry.Name(), ".txt") {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// runCache implements `web cache clear` and returns the process exit code
+func runCache(args []string) int {
+	var command string
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printCacheHelp()
+			os.Exit(0)
+			return nil
+		}},
+		dataDirFlag,
+	}
+	err := parseFlags(args, defs, func(arg string) error {
+		if command != "" {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		if arg != "clear" {
+			return fmt.Errorf("unknown command %q, expected clear", arg)
+		}
+		command = arg
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web cache --help' for usage.\n", err)
+		return 1
+	}
+	if command == "" {
+		printCacheHelp()
+		return 1
+	}
+
+	removed, err := clearCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %d cached page(s)\n", removed)
+	return 0
+}
+
+func printCacheHelp() {
+	fmt.Print(`web cache - manage the output --cache keeps
+
+Usage: web cache clear [options]
+
+Removes every page --cache kept, so the next runs load them again.
+
+Options:
+  --help                     Show this help message
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+
+Examples:
+  web cache clear
+`)
+}
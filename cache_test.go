@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	config := newConfig()
+	key := cacheKey(config, []string{"example.com", "--extract", "main", "--cache"})
+	if key != cacheKey(config, []string{"example.com", "--extract", "main", "--cache=2h"}) {
+		t.Error("Expected the cache flags to be left out of the key")
+	}
+	work := newConfig()
+	work.Profile = "work"
+	bearer := newConfig()
+	bearer.Bearer = "other-token"
+	proxied := newConfig()
+	proxied.ProxyURL, _ = url.Parse("http://proxy:3128")
+	for _, other := range []string{
+		cacheKey(work, []string{"example.com", "--extract", "main"}),
+		cacheKey(bearer, []string{"example.com", "--extract", "main"}),
+		cacheKey(proxied, []string{"example.com", "--extract", "main"}),
+		cacheKey(config, []string{"example.com", "--extract", "article"}),
+		cacheKey(config, []string{"example.org", "--extract", "main"}),
+	} {
+		if other == key {
+			t.Error("Expected another profile, credential, proxy, option or URL to get another key")
+		}
+	}
+
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	if cacheKey(config, []string{"example.com", "--extract", "main"}) == key {
+		t.Error("Expected another data dir to get another key")
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	if _, _, ok := readCache("abc", time.Hour); ok {
+		t.Fatal("Expected nothing cached yet")
+	}
+	if err := writeCache("abc", "# Page"); err != nil {
+		t.Fatal(err)
+	}
+	if result, _, ok := readCache("abc", time.Hour); !ok || result != "# Page" {
+		t.Errorf("Expected the cached page, got %q, %v", result, ok)
+	}
+
+	// Outputs older than the TTL are stale
+	dir, _ := cacheDir()
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "abc.txt"), old, old)
+	if _, _, ok := readCache("abc", time.Hour); ok {
+		t.Error("Expected a stale page to be missed")
+	}
+	if _, _, ok := readCache("abc", 3*time.Hour); !ok {
+		t.Error("Expected a longer TTL to serve it")
+	}
+
+	if removed, err := clearCache(); err != nil || removed != 1 {
+		t.Errorf("Expected 1 page removed, got %d, %v", removed, err)
+	}
+	if _, _, ok := readCache("abc", 3*time.Hour); ok {
+		t.Error("Expected the cache to be empty")
+	}
+}
+
+func TestRunFetchCache(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<h1>Docs " + version + "</h1>"))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "page.md")
+	fetch := func(extra ...string) string {
+		t.Helper()
+		args := append([]string{server.URL, "--http-only", "--quiet", "--output", output}, extra...)
+		if code := runFetch(args, nil); code != 0 {
+			t.Fatalf("runFetch(%q) exited with %d", args, code)
+		}
+		data, _ := os.ReadFile(output)
+		return string(data)
+	}
+
+	if got := fetch("--cache"); !strings.Contains(got, "Docs v1") {
+		t.Fatalf("Expected the page, got %q", got)
+	}
+	version = "v2"
+	if got := fetch("--cache"); !strings.Contains(got, "Docs v1") {
+		t.Errorf("Expected the cached page, got %q", got)
+	}
+	if got := fetch(); !strings.Contains(got, "Docs v2") {
+		t.Errorf("Expected a run without --cache to load the page, got %q", got)
+	}
+
+	t.Setenv("WEB_CACHE", "1h")
+	if got := fetch(); !strings.Contains(got, "Docs v1") {
+		t.Errorf("Expected WEB_CACHE to serve the cached page, got %q", got)
+	}
+	if got := fetch("--no-cache"); !strings.Contains(got, "Docs v2") {
+		t.Errorf("Expected --no-cache to load the page, got %q", got)
+	}
+
+	// Runs expanding a variable to another value, or sending another token,
+	// don't get each other's pages
+	version = "v3"
+	t.Setenv("SECTION", "Docs")
+	if got := fetch("--grep", "${SECTION}"); !strings.Contains(got, "Docs v3") {
+		t.Fatalf("Expected the page, got %q", got)
+	}
+	version = "v4"
+	if got := fetch("--grep", "${SECTION}"); !strings.Contains(got, "Docs v3") {
+		t.Errorf("Expected the cached page, got %q", got)
+	}
+	t.Setenv("SECTION", "Doc")
+	if got := fetch("--grep", "${SECTION}"); !strings.Contains(got, "Docs v4") {
+		t.Errorf("Expected another variable value to miss the cache, got %q", got)
+	}
+	t.Setenv("WEB_BEARER_TOKEN", "other-token")
+	if got := fetch(); !strings.Contains(got, "Docs v4") {
+		t.Errorf("Expected another token to miss the cache, got %q", got)
+	}
+}
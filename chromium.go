@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// CHROMIUM_VERSION is the Chrome for Testing release --engine chromium
+// downloads. Its builds come with a chromedriver of the same version.
+const CHROMIUM_VERSION = "131.0.6778.85"
+
+// chromiumVersionMarker records which version is installed in the Chromium
+// and chromedriver directories
+const chromiumVersionMarker = ".version"
+
+// chromiumPlatform names the Chrome for Testing build for this machine
+func chromiumPlatform() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if runtime.GOARCH == "arm64" {
+			return "mac-arm64", nil
+		}
+		return "mac-x64", nil
+	case "linux":
+		return "linux64", nil
+	default:
+		return "", fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// chromiumPaths returns where the Chromium executable and chromedriver live
+// once downloaded into dir
+func chromiumPaths(dir string) (string, string, error) {
+	platform, err := chromiumPlatform()
+	if err != nil {
+		return "", "", err
+	}
+	chromeExec := filepath.Join(dir, "chrome", "chrome-"+platform, "chrome")
+	if runtime.GOOS == "darwin" {
+		chromeExec = filepath.Join(dir, "chrome", "chrome-"+platform, "Google Chrome for Testing.app", "Contents", "MacOS", "Google Chrome for Testing")
+	}
+	driverExec := filepath.Join(dir, "chromedriver", "chromedriver-"+platform, "chromedriver")
+	return chromeExec, driverExec, nil
+}
+
+// ensureChromium downloads Chromium and a matching chromedriver to
+// ~/.web-chromium if they aren't there yet. A --browser-path Chromium needs
+// only a chromedriver, from the PATH if there is one.
+func ensureChromium(config Config) error {
+	browserPath := config.BrowserPath
+	dir, err := engineDir("chromium")
+	if err != nil {
+		return err
+	}
+	platform, err := chromiumPlatform()
+	if err != nil {
+		return err
+	}
+	chromeExec, driverExec, err := chromiumPaths(dir)
+	if err != nil {
+		return err
+	}
+
+	downloads := []struct {
+		name, archive, exec, dest string
+	}{
+		{"Chromium", "chrome", chromeExec, filepath.Join(dir, "chrome")},
+		{"chromedriver", "chromedriver", driverExec, filepath.Join(dir, "chromedriver")},
+	}
+	if browserPath != "" {
+		if systemDriver(config, "chromedriver") != "" {
+			return nil
+		}
+		// chromedriver only drives the Chrome version it was built for
+		logf("Warning: chromedriver not found on the PATH, using chromedriver %s, which needs a matching %s\n", CHROMIUM_VERSION, browserPath)
+		downloads = downloads[1:]
+	}
+	for _, download := range downloads {
+		// Installs from before the marker existed are taken as they are
+		if _, err := os.Stat(download.exec); err == nil {
+			installed := chromiumInstalled(download.dest)
+			if installed == "" || installed == CHROMIUM_VERSION {
+				continue
+			}
+			logf("%s %s installed, replacing it with %s...\n", download.name, installed, CHROMIUM_VERSION)
+		} else {
+			logf("%s not found, downloading...\n", download.name)
+		}
+		os.RemoveAll(download.dest)
+
+		url := fmt.Sprintf("https://storage.googleapis.com/chrome-for-testing-public/%s/%s/%s-%s.zip", CHROMIUM_VERSION, platform, download.archive, platform)
+		if _, err := downloadZip(download.name, url, download.dest, ""); err != nil {
+			return fmt.Errorf("failed to download %s: %v", download.name, err)
+		}
+		if _, err := os.Stat(download.exec); err != nil {
+			return fmt.Errorf("%s executable not found after download: %s", download.name, download.exec)
+		}
+		if err := os.WriteFile(filepath.Join(download.dest, chromiumVersionMarker), []byte(CHROMIUM_VERSION+"\n"), 0644); err != nil {
+			return fmt.Errorf("could not record %s version: %v", download.name, err)
+		}
+		logf("%s downloaded to: %s\n", download.name, download.dest)
+	}
+	return nil
+}
+
+// chromiumInstalled returns the version recorded in a Chromium or chromedriver
+// directory, or "" when there is no marker
+func chromiumInstalled(dest string) string {
+	marker, err := os.ReadFile(filepath.Join(dest, chromiumVersionMarker))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(marker))
+}
+
+// chromiumSession starts chromedriver and returns the capabilities for a
+// Chromium session using profileDir as its user data directory
+func chromiumSession(config Config, profileDir string, port int) (webDriverService, selenium.Capabilities, error) {
+	dir, err := engineDir("chromium")
+	if err != nil {
+		return nil, nil, err
+	}
+	chromeExec, driverExec, err := chromiumPaths(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.BrowserPath != "" {
+		chromeExec = config.BrowserPath
+	}
+	if path := systemDriver(config, "chromedriver"); path != "" {
+		driverExec = path
+	}
+
+	service, err := startDriver(config, port, driverExec, fmt.Sprintf("--port=%d", port))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start chromedriver service: %v", err)
+	}
+
+	args := []string{
+		"--user-data-dir=" + profileDir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		// Firefox's headless window size, so both engines lay pages out alike
+		"--window-size=1366,768",
+	}
+	if !config.Headed {
+		args = append(args, "--headless=new")
+	}
+	if config.Devtools {
+		args = append(args, "--auto-open-devtools-for-tabs")
+	}
+	// Chromium refuses to start its sandbox as root (e.g. in containers)
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+	if config.ScreenshotScale > 0 {
+		args = append(args, "--force-device-scale-factor="+strconv.FormatFloat(config.ScreenshotScale, 'f', -1, 64))
+	}
+	// Send all traffic through the proxy, localhost included
+	if config.Proxy != nil {
+		args = append(args,
+			fmt.Sprintf("--proxy-server=http://127.0.0.1:%d", config.Proxy.Port()),
+			"--proxy-bypass-list=<-loopback>",
+		)
+	}
+	if config.NoJS {
+		args = append(args, "--blink-settings=scriptEnabled=false")
+	}
+	if config.UserAgent != "" {
+		args = append(args, "--user-agent="+config.UserAgent)
+	}
+	// Headless Chromium leaves out the GPU, and WebGL with it unless it may
+	// fall back to rendering in software
+	if config.EnableGPU {
+		args = append(args, "--enable-gpu", "--ignore-gpu-blocklist")
+	}
+	if config.WebGL || config.EnableGPU {
+		args = append(args, "--enable-webgl", "--use-angle=swiftshader", "--enable-unsafe-swiftshader")
+	}
+
+	caps := selenium.Capabilities{
+		"browserName": "chrome",
+		"goog:chromeOptions": map[string]interface{}{
+			"binary": chromeExec,
+			"args":   args,
+		},
+	}
+	return service, caps, nil
+}
+
+// chromiumFullScreenshot captures the whole page through the DevTools
+// protocol, which chromedriver exposes in place of Firefox's full page endpoint
+func chromiumFullScreenshot(wd selenium.WebDriver) ([]byte, error) {
+	raw, err := wd.ExecuteScript(`return [document.documentElement.scrollWidth, document.documentElement.scrollHeight]`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not measure page: %v", err)
+	}
+	size, _ := raw.([]interface{})
+	if len(size) != 2 {
+		return nil, fmt.Errorf("could not measure page")
+	}
+	width, _ := size[0].(float64)
+	height, _ := size[1].(float64)
+
+	reply, err := webDriverRequest(wd, "POST", "/goog/cdp/execute", map[string]interface{}{
+		"cmd": "Page.captureScreenshot",
+		"params": map[string]interface{}{
+			"format":                "png",
+			"captureBeyondViewport": true,
+			"clip": map[string]interface{}{
+				"x": 0, "y": 0, "width": math.Ceil(width), "height": math.Ceil(height), "scale": 1,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return nil, fmt.Errorf("bad screenshot response: %v", err)
+	}
+	return base64.StdEncoding.DecodeString(result.Data)
+}
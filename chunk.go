@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkSize is the maximum size of a --chunk, in characters or tokens
+type ChunkSize struct {
+	Max    int
+	Tokens bool
+
+	// counter measures tokens; without one they are estimated
+	counter *tokenCounter
+}
+
+// parseChunkSize reads a --chunk value: a number of characters, or of
+// tokens with a "t" suffix (e.g. 800t)
+func parseChunkSize(value string) (ChunkSize, error) {
+	size := ChunkSize{}
+	number := value
+	if trimmed, ok := strings.CutSuffix(value, "t"); ok {
+		number = trimmed
+		size.Tokens = true
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return ChunkSize{}, fmt.Errorf("--chunk expects a positive number of characters or tokens (e.g. 4000 or 1000t), got %q", value)
+	}
+	size.Max = n
+	return size, nil
+}
+
+// measure is the size of text in the chunk's unit
+func (size ChunkSize) measure(text string) int {
+	if size.Tokens && size.counter != nil {
+		return size.counter.count(text)
+	}
+	if size.Tokens {
+		return estimateTokens(text)
+	}
+	return utf8.RuneCountInString(text)
+}
+
+// chunkMarkdown splits markdown into chunks no larger than size, breaking
+// between blocks (paragraphs, lists, tables, code blocks) and preferring to
+// start a chunk at a heading. Blocks that don't fit in a chunk on their own
+// are split at line breaks, then at spaces.
+func chunkMarkdown(markdown string, size ChunkSize) []string {
+	var chunks []string
+	var current []string
+	currentSize := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+			current, currentSize = nil, 0
+		}
+	}
+
+	for _, block := range markdownBlocks(markdown) {
+		blockSize := size.measure(block)
+		separator := 0
+		if len(current) > 0 {
+			separator = size.measure("\n\n")
+		}
+
+		// A heading starts a new chunk once the current one is half full
+		heading := strings.HasPrefix(block, "#")
+		if currentSize+separator+blockSize > size.Max || (heading && currentSize >= size.Max/2) {
+			flush()
+			separator = 0
+		}
+
+		if blockSize > size.Max {
+			pieces := splitBlock(block, size)
+			chunks = append(chunks, pieces[:len(pieces)-1]...)
+			block = pieces[len(pieces)-1]
+			blockSize = size.measure(block)
+		}
+		current = append(current, block)
+		currentSize += separator + blockSize
+	}
+	flush()
+
+	return chunks
+}
+
+// markdownBlocks splits markdown at blank lines, keeping fenced code blocks whole
+func markdownBlocks(markdown string) []string {
+	var blocks []string
+	var lines []string
+	fence := ""
+
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && strings.HasPrefix(trimmed, "```") {
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, "`"))]
+		} else if fence != "" && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, "`") == "" {
+			fence = ""
+		}
+
+		if trimmed == "" && fence == "" {
+			if len(lines) > 0 {
+				blocks = append(blocks, strings.Join(lines, "\n"))
+				lines = nil
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return blocks
+}
+
+// splitBlock breaks a block larger than size into pieces that fit
+func splitBlock(block string, size ChunkSize) []string {
+	var pieces []string
+	var current strings.Builder
+
+	add := func(part, separator string) {
+		if current.Len() > 0 && size.measure(current.String()+separator+part) > size.Max {
+			pieces = append(pieces, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(separator)
+		}
+		current.WriteString(part)
+	}
+
+	for _, line := range strings.Split(block, "\n") {
+		if size.measure(line) <= size.Max {
+			add(line, "\n")
+			continue
+		}
+		separator := "\n"
+		for _, word := range strings.Fields(line) {
+			for size.measure(word) > size.Max {
+				// A single word longer than a chunk, e.g. a data URL
+				runes := []rune(word)
+				limit := size.Max
+				if size.Tokens {
+					limit *= 4
+				}
+				limit = min(limit, len(runes)-1)
+				add(string(runes[:limit]), separator)
+				word = string(runes[limit:])
+				separator = " "
+			}
+			add(word, separator)
+			separator = " "
+		}
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+// formatChunks renders chunks for output, each behind a marker giving its
+// position. index selects a single chunk (1-based); 0 renders them all.
+func formatChunks(chunks []string, index int) (string, error) {
+	if index > len(chunks) {
+		return "", fmt.Errorf("--chunk-index %d is out of range, the page has %d chunk(s)", index, len(chunks))
+	}
+
+	var parts []string
+	for i, chunk := range chunks {
+		if index == 0 || index == i+1 {
+			parts = append(parts, fmt.Sprintf("<!-- chunk %d of %d -->\n\n%s", i+1, len(chunks), chunk))
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
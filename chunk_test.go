@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChunkSize(t *testing.T) {
+	if size, err := parseChunkSize("4000"); err != nil || size != (ChunkSize{Max: 4000}) {
+		t.Errorf("parseChunkSize(4000) = %+v, %v", size, err)
+	}
+	if size, err := parseChunkSize("800t"); err != nil || size != (ChunkSize{Max: 800, Tokens: true}) {
+		t.Errorf("parseChunkSize(800t) = %+v, %v", size, err)
+	}
+	for _, value := range []string{"", "t", "0", "-5", "1k"} {
+		if _, err := parseChunkSize(value); err == nil {
+			t.Errorf("parseChunkSize(%q) should fail", value)
+		}
+	}
+}
+
+func TestChunkMarkdown(t *testing.T) {
+	markdown := strings.Join([]string{
+		"# Intro",
+		"First paragraph of the intro.",
+		"Second paragraph.",
+		"## Usage",
+		"```sh\nweb example.com\n\nweb example.org\n```",
+		"Closing words.",
+	}, "\n\n")
+
+	chunks := chunkMarkdown(markdown, ChunkSize{Max: 60})
+	expected := []string{
+		"# Intro\n\nFirst paragraph of the intro.\n\nSecond paragraph.",
+		"## Usage\n\n```sh\nweb example.com\n\nweb example.org\n```",
+		"Closing words.",
+	}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("chunkMarkdown() = %q, expected %q", chunks, expected)
+	}
+
+	// Blocks larger than a chunk are split at lines, then words
+	chunks = chunkMarkdown("one two three four five six", ChunkSize{Max: 10})
+	expected = []string{"one two", "three four", "five six"}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("chunkMarkdown() = %q, expected %q", chunks, expected)
+	}
+	for _, chunk := range chunkMarkdown(strings.Repeat("x", 25), ChunkSize{Max: 2, Tokens: true}) {
+		if estimateTokens(chunk) > 2 {
+			t.Errorf("Chunk %q is larger than 2 tokens", chunk)
+		}
+	}
+}
+
+func TestFormatChunks(t *testing.T) {
+	chunks := []string{"# One", "# Two"}
+
+	all, _ := formatChunks(chunks, 0)
+	if all != "<!-- chunk 1 of 2 -->\n\n# One\n\n<!-- chunk 2 of 2 -->\n\n# Two" {
+		t.Errorf("formatChunks(all) = %q", all)
+	}
+	second, _ := formatChunks(chunks, 2)
+	if second != "<!-- chunk 2 of 2 -->\n\n# Two" {
+		t.Errorf("formatChunks(2) = %q", second)
+	}
+	if _, err := formatChunks(chunks, 3); err == nil || !strings.Contains(err.Error(), "the page has 2 chunk(s)") {
+		t.Errorf("Expected out of range error, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// runCleanup implements `web cleanup`, stopping the browsers and drivers that
+// runs which didn't exit cleanly left behind. Profiles in use by a live run
+// are left alone. It returns the process exit code.
+func runCleanup(args []string) int {
+	engine := ""
+	dryRun := false
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printCleanupHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--engine", kind: flagString, apply: func(value string) error {
+			if !slices.Contains(engines, value) {
+				return fmt.Errorf("--engine must be one of %s, got %q", strings.Join(engines, ", "), value)
+			}
+			engine = value
+			return nil
+		}},
+		dataDirFlag,
+		{name: "--dry-run", kind: flagBool, target: &dryRun},
+	}
+	err := parseFlags(args, defs, func(arg string) error {
+		return fmt.Errorf("unexpected argument %q", arg)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web cleanup --help' for usage.\n", err)
+		return 1
+	}
+
+	selected := engines
+	if engine != "" {
+		selected = []string{engine}
+	}
+
+	found := 0
+	for _, name := range selected {
+		dir, err := engineDir(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		found += cleanupProfiles(filepath.Join(dir, "profiles"), dryRun)
+		found += cleanupDrivers(dir, dryRun)
+	}
+
+	if found == 0 {
+		fmt.Println("Nothing left behind to clean up")
+	}
+	return 0
+}
+
+// cleanupProfiles reclaims every profile no live run holds the lock of
+func cleanupProfiles(profilesDir string, dryRun bool) int {
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		return 0
+	}
+	var profiles []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			locked, isLock := strings.CutSuffix(name, ".lock")
+			encrypted, isEncrypted := strings.CutSuffix(name, ".enc")
+			switch {
+			case isLock:
+				name = locked
+			case isEncrypted:
+				name = encrypted
+			default:
+				continue
+			}
+		}
+		if !slices.Contains(profiles, name) {
+			profiles = append(profiles, name)
+		}
+	}
+
+	found := 0
+	for _, profile := range profiles {
+		path := filepath.Join(profilesDir, profile+".lock")
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			logf("Warning: Could not open the lock of profile %q: %v\n", profile, err)
+			continue
+		}
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := readProfileLock(path)
+			fmt.Printf("Profile %q is in use by a run (pid %d), leaving it\n", profile, pid)
+			file.Close()
+			continue
+		} else if err != nil {
+			logf("Warning: Could not lock profile %q: %v\n", profile, err)
+			file.Close()
+			continue
+		}
+
+		found += reclaimProfile(profilesDir, profile, dryRun)
+		if !dryRun {
+			file.Truncate(0)
+		}
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}
+	return found
+}
+
+// cleanupDrivers stops drivers downloaded into dir whose web process is gone,
+// which leaves them children of init
+func cleanupDrivers(dir string, dryRun bool) int {
+	processes, err := listProcesses()
+	if err != nil {
+		logf("Warning: %v\n", err)
+		return 0
+	}
+	found := 0
+	for _, p := range processes {
+		executable, _, _ := strings.Cut(p.args, " ")
+		name := filepath.Base(executable)
+		if p.ppid != 1 || (name != "geckodriver" && name != "chromedriver") || !strings.HasPrefix(executable, dir+string(filepath.Separator)) {
+			continue
+		}
+		found++
+		if dryRun {
+			fmt.Printf("Would stop %s left running by an earlier run (pid %d)\n", name, p.pid)
+			continue
+		}
+		fmt.Printf("Stopping %s left running by an earlier run (pid %d)\n", name, p.pid)
+		stopProcess(p.pid)
+	}
+	return found
+}
+
+func printCleanupHelp() {
+	fmt.Print(`web cleanup - stop browsers and drivers left running by runs that didn't exit cleanly
+
+Usage: web cleanup [options]
+
+Runs lock their profile while their browser is open. A browser still running
+on a profile nobody holds the lock of was left behind by a crashed or killed
+run, and is stopped (SIGTERM, then SIGKILL after 5s), along with drivers whose
+web process is gone and decrypted copies of encrypted profiles. Profiles in use
+by a live run are left alone.
+
+Options:
+  --help                     Show this help message
+  --engine <name>            Only this browser: firefox, chromium or webkit (default: all of them)
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --dry-run                  Only list what would be stopped or removed
+`)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Validators are what a server said identifies the version of a page it
+// sent, for --if-changed to ask whether it has changed since
+type Validators struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// validatorsPath is where the validators of url are kept for a profile
+func validatorsPath(profile, url string) (string, error) {
+	dir, err := engineDir("firefox")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "validators", profile, hex.EncodeToString(sum[:12])+".json"), nil
+}
+
+// readValidators loads the validators of the previous run, if there was one
+func readValidators(path string) (Validators, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Validators{}, false
+	}
+	var validators Validators
+	if json.Unmarshal(data, &validators) != nil || validators.ETag == "" && validators.LastModified == "" {
+		return Validators{}, false
+	}
+	return validators, true
+}
+
+// saveValidators keeps the validators of a response for the next run, or
+// forgets the previous ones when the response has none
+func saveValidators(path, url string, header http.Header) error {
+	validators := Validators{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}
+	if validators.ETag == "" && validators.LastModified == "" {
+		os.Remove(path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not save validators: %v", err)
+	}
+	data, _ := json.MarshalIndent(validators, "", "  ")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not save validators: %v", err)
+	}
+	return nil
+}
+
+// setConditional asks the server to answer 304 Not Modified for the
+// version the validators identify
+func (v Validators) setConditional(header http.Header) {
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// formatNotModified is the output of a run whose page hadn't changed
+func formatNotModified(validators Validators) string {
+	return fmt.Sprintf("Not modified since the last run (%s)\n", validators.FetchedAt.Local().Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchHTTPIfChanged(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	version := "v1"
+	var conditional []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		etag := `"` + version + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<h1>Changelog " + version + "</h1>"))
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	config.HTTPOnly = true
+	config.IfChanged = true
+	config.URL = server.URL
+
+	result, err := fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "# Changelog v1") {
+		t.Errorf("Expected the page on the first run:\n%s", result)
+	}
+
+	result, err = fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Status: 304 Not Modified") || !strings.Contains(result, "Not modified since the last run") || strings.Contains(result, "Changelog") {
+		t.Errorf("Expected only that the page hadn't changed:\n%s", result)
+	}
+
+	version = "v2"
+	if result, err = fetchHTTP(config); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "# Changelog v2") {
+		t.Errorf("Expected the changed page:\n%s", result)
+	}
+
+	expected := []string{"", `"v1"`, `"v1"`}
+	if strings.Join(conditional, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected If-None-Match %q, got %q", expected, conditional)
+	}
+
+	config.Format = "json"
+	if result, err = fetchHTTP(config); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, `"not_modified": true`) {
+		t.Errorf("Expected not_modified in:\n%s", result)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// consoleLevels are the values --console-level accepts, least severe first.
+// log and info are the same level.
+var consoleLevels = []string{"debug", "log", "info", "warn", "error"}
+
+// ConsoleEntry is a console message or uncaught error logged by the page
+type ConsoleEntry struct {
+	Level  string    `json:"level"`
+	Text   string    `json:"text"`
+	Source string    `json:"source,omitempty"`
+	Line   int       `json:"line,omitempty"`
+	Column int       `json:"column,omitempty"`
+	Time   time.Time `json:"timestamp"`
+}
+
+// consoleSeverity ranks a level for --console-level filtering
+func consoleSeverity(level string) int {
+	switch level {
+	case "debug":
+		return 0
+	case "warn":
+		return 2
+	case "error":
+		return 3
+	default:
+		return 1
+	}
+}
+
+// filterConsole keeps the entries at level or above; an empty level keeps all
+func filterConsole(entries []ConsoleEntry, level string) []ConsoleEntry {
+	if level == "" {
+		return entries
+	}
+	var kept []ConsoleEntry
+	for _, entry := range entries {
+		if consoleSeverity(entry.Level) >= consoleSeverity(level) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// normalizeConsoleLevel maps console methods and WebDriver log levels onto
+// consoleLevels
+func normalizeConsoleLevel(level string) string {
+	level = strings.ToLower(level)
+	switch level {
+	case "warning":
+		return "warn"
+	case "severe":
+		return "error"
+	}
+	if slices.Contains(consoleLevels, level) {
+		return level
+	}
+	return "log"
+}
+
+// String renders the entry as a line of the CONSOLE OUTPUT section
+func (entry ConsoleEntry) String() string {
+	level := strings.ToUpper(entry.Level)
+	if level == "WARN" {
+		level = "WARNING"
+	}
+	line := fmt.Sprintf("[%s] %s", level, entry.Text)
+	if entry.Source != "" && entry.Line > 0 {
+		line += fmt.Sprintf(" (%s:%d)", entry.Source, entry.Line)
+	}
+	return line
+}
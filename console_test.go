@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterConsole(t *testing.T) {
+	entries := []ConsoleEntry{
+		{Level: "debug", Text: "a"},
+		{Level: "log", Text: "b"},
+		{Level: "info", Text: "c"},
+		{Level: "warn", Text: "d"},
+		{Level: "error", Text: "e"},
+	}
+	tests := []struct {
+		level    string
+		expected string
+	}{
+		{"", "abcde"},
+		{"debug", "abcde"},
+		{"info", "bcde"},
+		{"log", "bcde"},
+		{"warn", "de"},
+		{"error", "e"},
+	}
+	for _, test := range tests {
+		got := ""
+		for _, entry := range filterConsole(entries, test.level) {
+			got += entry.Text
+		}
+		if got != test.expected {
+			t.Errorf("filterConsole(%q) kept %q, expected %q", test.level, got, test.expected)
+		}
+	}
+}
+
+func TestNormalizeConsoleLevel(t *testing.T) {
+	for level, expected := range map[string]string{"warn": "warn", "WARNING": "warn", "SEVERE": "error", "info": "info", "trace": "log"} {
+		if got := normalizeConsoleLevel(level); got != expected {
+			t.Errorf("normalizeConsoleLevel(%q) = %q, expected %q", level, got, expected)
+		}
+	}
+}
+
+func TestConsoleEntryString(t *testing.T) {
+	entry := ConsoleEntry{Level: "warn", Text: "deprecated", Source: "https://example.com/app.js", Line: 12, Column: 3, Time: time.Now()}
+	if got := entry.String(); got != "[WARNING] deprecated (https://example.com/app.js:12)" {
+		t.Errorf("String() = %q", got)
+	}
+	entry = ConsoleEntry{Level: "log", Text: "hello"}
+	if got := entry.String(); got != "[LOG] hello" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// cookieJar holds a profile's cookies for --http-only runs. It is kept in
+// profiles/<name>.cookies.json next to the profile, with the cookies browser
+// runs on the profile end up with on their final page added to it, so
+// --http-only runs are logged in wherever the browser was.
+type cookieJar struct {
+	mu      sync.Mutex
+	cookies []Cookie
+}
+
+// cookieJarPath is where the profile's cookie jar is kept
+func cookieJarPath(config Config) (string, error) {
+	dir, err := engineDir(config.Engine)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "profiles", config.Profile+".cookies.json"), nil
+}
+
+// withCookieJar loads the profile's cookie jar, hands it to use and saves it
+// back, holding a lock on the file throughout so runs sharing the profile
+// don't lose each other's cookies
+func withCookieJar(config Config, use func(jar *cookieJar) error) error {
+	path, err := cookieJarPath(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create profiles directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open cookie jar: %v", err)
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("could not lock cookie jar: %v", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	jar := &cookieJar{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read cookie jar: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &jar.cookies); err != nil {
+			logf("Warning: Starting over with an empty cookie jar, %s is unreadable: %v\n", path, err)
+		}
+	}
+	jar.expire()
+
+	useErr := use(jar)
+
+	data, err = json.MarshalIndent(jar.cookies, "", "  ")
+	if err == nil {
+		file.Truncate(0)
+		_, err = file.WriteAt(data, 0)
+	}
+	if err != nil && useErr == nil {
+		useErr = fmt.Errorf("could not save cookie jar: %v", err)
+	}
+	return useErr
+}
+
+// saveBrowserCookies adds the cookies the browser holds for its current page
+// to the profile's cookie jar. Encrypted profiles keep theirs to themselves.
+func saveBrowserCookies(wd selenium.WebDriver, config Config) {
+	if config.Engine == "webkit" || config.EncryptProfile {
+		return
+	}
+	cookies, err := collectCookies(wd)
+	if err != nil || len(cookies) == 0 {
+		return
+	}
+	err = withCookieJar(config, func(jar *cookieJar) error {
+		jar.add(cookies...)
+		return nil
+	})
+	if err != nil {
+		logf("Warning: %v\n", err)
+	}
+}
+
+// add stores cookies, replacing ones with the same name, domain and path
+func (j *cookieJar) add(cookies ...Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, cookie := range cookies {
+		replaced := false
+		for i, kept := range j.cookies {
+			if kept.Name == cookie.Name && kept.Domain == cookie.Domain && kept.Path == cookie.Path {
+				j.cookies[i] = cookie
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			j.cookies = append(j.cookies, cookie)
+		}
+	}
+	j.expire()
+}
+
+// expire drops cookies past their expiry
+func (j *cookieJar) expire() {
+	now := time.Now().Unix()
+	kept := j.cookies[:0]
+	for _, cookie := range j.cookies {
+		if cookie.Expiry == 0 || cookie.Expiry > now {
+			kept = append(kept, cookie)
+		}
+	}
+	j.cookies = kept
+}
+
+// matching returns the cookies a request to u would carry
+func (j *cookieJar) matching(u *url.URL) []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	host := u.Hostname()
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	var matched []Cookie
+	for _, cookie := range j.cookies {
+		// Cookies without a leading dot are for their host alone
+		domain, subdomains := strings.CutPrefix(cookie.Domain, ".")
+		if host != domain && !(subdomains && strings.HasSuffix(host, "."+domain)) {
+			continue
+		}
+		if cookie.Path != "" && cookie.Path != "/" && path != cookie.Path && !strings.HasPrefix(path, strings.TrimSuffix(cookie.Path, "/")+"/") {
+			continue
+		}
+		if cookie.Secure && u.Scheme != "https" {
+			continue
+		}
+		matched = append(matched, cookie)
+	}
+	return matched
+}
+
+// SetCookies implements http.CookieJar, storing cookies set by a response
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	var added []Cookie
+	for _, c := range cookies {
+		cookie := fromHTTPCookie(c)
+		if cookie.Domain == "" {
+			cookie.Domain = u.Hostname()
+		}
+		if cookie.Path == "" || !strings.HasPrefix(cookie.Path, "/") {
+			cookie.Path = "/"
+			if i := strings.LastIndex(u.Path, "/"); i > 0 {
+				cookie.Path = u.Path[:i]
+			}
+		}
+		added = append(added, cookie)
+	}
+	j.add(added...)
+}
+
+// fromHTTPCookie converts a Set-Cookie cookie, leaving Domain empty for a
+// host-only cookie and Path empty when it has none
+func fromHTTPCookie(c *http.Cookie) Cookie {
+	cookie := Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+	}
+	if c.Domain != "" {
+		cookie.Domain = "." + strings.TrimPrefix(c.Domain, ".")
+	}
+	switch c.SameSite {
+	case http.SameSiteLaxMode:
+		cookie.SameSite = "Lax"
+	case http.SameSiteStrictMode:
+		cookie.SameSite = "Strict"
+	case http.SameSiteNoneMode:
+		cookie.SameSite = "None"
+	}
+	switch {
+	case c.MaxAge < 0:
+		// Deleted, expired a second ago
+		cookie.Expiry = time.Now().Unix() - 1
+	case c.MaxAge > 0:
+		cookie.Expiry = time.Now().Unix() + int64(c.MaxAge)
+	case !c.Expires.IsZero():
+		cookie.Expiry = c.Expires.Unix()
+	}
+	return cookie
+}
+
+// Cookies implements http.CookieJar, returning the cookies to send to u
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, cookie := range j.matching(u) {
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return cookies
+}
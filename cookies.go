@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// Cookie is a browser cookie as WebDriver reports it. The selenium client's
+// own type leaves out httpOnly and sameSite.
+type Cookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Expiry   int64  `json:"expiry,omitempty"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"httpOnly"`
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+// collectCookies returns the cookies the browser would send to the current page
+func collectCookies(wd selenium.WebDriver) ([]Cookie, error) {
+	raw, err := webDriverRequest(wd, "GET", "/cookie", nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cookies: %v", err)
+	}
+	cookies := []Cookie{}
+	if err := json.Unmarshal(raw, &cookies); err != nil {
+		return nil, fmt.Errorf("could not decode cookies: %v", err)
+	}
+	return cookies, nil
+}
+
+// writeCookies exports cookies, values included, as JSON. Like the profile
+// they may come from, the file is only readable by the user.
+func writeCookies(path string, cookies []Cookie) error {
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode cookies: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+// withoutValues drops cookie values, which are often credentials, for output
+// that ends up in terminals and logs
+func withoutValues(cookies []Cookie) []Cookie {
+	redacted := make([]Cookie, len(cookies))
+	for i, cookie := range cookies {
+		cookie.Value = ""
+		redacted[i] = cookie
+	}
+	return redacted
+}
+
+func formatCookies(cookies []Cookie) string {
+	if len(cookies) == 0 {
+		return "No cookies\n"
+	}
+	var b strings.Builder
+	for _, cookie := range cookies {
+		expires := "session"
+		if cookie.Expiry > 0 {
+			expires = "expires " + time.Unix(cookie.Expiry, 0).UTC().Format(time.RFC3339)
+		}
+		flags := []string{}
+		if cookie.Secure {
+			flags = append(flags, "Secure")
+		}
+		if cookie.HTTPOnly {
+			flags = append(flags, "HttpOnly")
+		}
+		if cookie.SameSite != "" {
+			flags = append(flags, "SameSite="+cookie.SameSite)
+		}
+		fmt.Fprintf(&b, "%s (%s%s, %s)", cookie.Name, cookie.Domain, cookie.Path, expires)
+		if len(flags) > 0 {
+			fmt.Fprintf(&b, " %s", strings.Join(flags, " "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// parseCookie parses a --cookie value: name=value followed by Set-Cookie
+// attributes such as Domain, Path, Secure, HttpOnly, SameSite and Max-Age.
+// Without a Domain the cookie is for the page's host, without a Path for
+// the whole site.
+func parseCookie(value string) (Cookie, error) {
+	c, err := http.ParseSetCookie(value)
+	if err != nil {
+		return Cookie{}, fmt.Errorf("--cookie expects name=value with optional attributes, e.g. \"session=abc; Domain=example.com; Path=/\", got %q", value)
+	}
+	cookie := fromHTTPCookie(c)
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	return cookie, nil
+}
+
+// setCookies gives the browser the --cookie cookies before it loads pageURL.
+// WebDriver only adds cookies for the site of the page it is on, so it first
+// loads the cookie's site's robots.txt, a small page most sites have, unless
+// it is on that site already. Chromium's DevTools set them from anywhere.
+func setCookies(wd selenium.WebDriver, config Config, pageURL string) error {
+	target, err := url.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("bad URL %q: %v", pageURL, err)
+	}
+	for _, cookie := range config.Cookies {
+		// Cookies for a domain and its subdomains have a leading dot, others
+		// are for one host: the page's when no domain is given
+		host := target.Hostname()
+		domain, subdomains := strings.CutPrefix(cookie.Domain, ".")
+		if domain != "" && host != domain && !(subdomains && strings.HasSuffix(host, "."+domain)) {
+			host = domain
+		}
+
+		if config.Engine == "chromium" {
+			params := map[string]interface{}{
+				"name":     cookie.Name,
+				"value":    cookie.Value,
+				"url":      target.Scheme + "://" + host + cookie.Path,
+				"path":     cookie.Path,
+				"secure":   cookie.Secure,
+				"httpOnly": cookie.HTTPOnly,
+			}
+			if subdomains {
+				params["domain"] = cookie.Domain
+			}
+			if cookie.SameSite != "" {
+				params["sameSite"] = cookie.SameSite
+			}
+			if cookie.Expiry > 0 {
+				params["expires"] = cookie.Expiry
+			}
+			_, err := webDriverRequest(wd, "POST", "/goog/cdp/execute", map[string]interface{}{"cmd": "Network.setCookie", "params": params})
+			if err != nil {
+				return fmt.Errorf("could not set cookie %q: %v", cookie.Name, err)
+			}
+			continue
+		}
+
+		current, _ := wd.CurrentURL()
+		if u, err := url.Parse(current); err != nil || u.Hostname() != host {
+			if err := wd.Get(target.Scheme + "://" + host + "/robots.txt"); err != nil {
+				return fmt.Errorf("could not open %s to set cookie %q: %v", host, cookie.Name, err)
+			}
+		}
+		body := map[string]interface{}{
+			"name":     cookie.Name,
+			"value":    cookie.Value,
+			"path":     cookie.Path,
+			"secure":   cookie.Secure,
+			"httpOnly": cookie.HTTPOnly,
+		}
+		if subdomains {
+			body["domain"] = cookie.Domain
+		}
+		if cookie.SameSite != "" {
+			body["sameSite"] = cookie.SameSite
+		}
+		if cookie.Expiry > 0 {
+			body["expiry"] = cookie.Expiry
+		}
+		if _, err := webDriverRequest(wd, "POST", "/cookie", map[string]interface{}{"cookie": body}); err != nil {
+			return fmt.Errorf("could not set cookie %q: %v", cookie.Name, err)
+		}
+	}
+	return nil
+}
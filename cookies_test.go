@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestFormatCookies(t *testing.T) {
+	cookies := []Cookie{
+		{Name: "_app_key", Value: "SFMyNTY", Domain: "example.com", Path: "/", Secure: true, HTTPOnly: true, SameSite: "Lax"},
+		{Name: "theme", Value: "dark", Domain: ".example.com", Path: "/settings", Expiry: 1893456000},
+	}
+	expected := `_app_key (example.com/, session) Secure HttpOnly SameSite=Lax
+theme (.example.com/settings, expires 2030-01-01T00:00:00Z)
+`
+	if got := formatCookies(cookies); got != expected {
+		t.Errorf("formatCookies =\n%s\nexpected\n%s", got, expected)
+	}
+	for _, cookie := range withoutValues(cookies) {
+		if cookie.Value != "" {
+			t.Errorf("Expected value of %s to be dropped", cookie.Name)
+		}
+	}
+	if cookies[0].Value != "SFMyNTY" {
+		t.Errorf("withoutValues modified the original cookies")
+	}
+}
+
+func TestParseCookie(t *testing.T) {
+	tests := map[string]Cookie{
+		"session=abc":                                     {Name: "session", Value: "abc", Path: "/"},
+		"session=abc; Domain=example.com":                 {Name: "session", Value: "abc", Domain: ".example.com", Path: "/"},
+		"theme=dark; Path=/settings; Secure":              {Name: "theme", Value: "dark", Path: "/settings", Secure: true},
+		"id=1; HttpOnly; SameSite=Strict":                 {Name: "id", Value: "1", Path: "/", HTTPOnly: true, SameSite: "Strict"},
+		"legacy=1; Expires=Fri, 01 Jan 2100 00:00:00 GMT": {Name: "legacy", Value: "1", Path: "/", Expiry: 4102444800},
+	}
+	for value, want := range tests {
+		got, err := parseCookie(value)
+		if err != nil {
+			t.Errorf("parseCookie(%q): %v", value, err)
+		} else if got != want {
+			t.Errorf("parseCookie(%q) = %+v, expected %+v", value, got, want)
+		}
+	}
+	if _, err := parseCookie("no value"); err == nil {
+		t.Errorf("Expected a cookie without = to be rejected")
+	}
+}
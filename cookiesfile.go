@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cookieFormats are the cookie file formats: curl and wget's Netscape
+// cookies.txt, and the JSON --dump-cookies writes
+var cookieFormats = []string{"netscape", "json"}
+
+// cookieFormatForPath infers a cookie file's format from its extension,
+// defaulting to Netscape
+func cookieFormatForPath(path string) string {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return "json"
+	}
+	return "netscape"
+}
+
+// readCookiesFile loads the cookies of a Netscape cookies.txt or JSON cookie
+// file, leaving out expired ones
+func readCookiesFile(path string) ([]Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	var cookies []Cookie
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &cookies); err != nil {
+			return nil, fmt.Errorf("could not decode %s: %v", path, err)
+		}
+	} else if cookies, err = parseNetscapeCookies(string(data)); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	now := time.Now().Unix()
+	return slices.DeleteFunc(cookies, func(cookie Cookie) bool {
+		return cookie.Expiry > 0 && cookie.Expiry <= now
+	}), nil
+}
+
+// parseNetscapeCookies parses cookies.txt lines: domain, whether subdomains
+// get the cookie, path, secure, expiry, name and value, separated by tabs.
+// HttpOnly cookies are prefixed with #HttpOnly_, other # lines are comments.
+func parseNetscapeCookies(data string) ([]Cookie, error) {
+	var cookies []Cookie
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d is not a Netscape cookie (7 tab-separated fields)", i+1)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d has a bad expiry %q", i+1, fields[4])
+		}
+		cookie := Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			Path:     fields[2],
+			Expiry:   expiry,
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = "." + cookie.Domain
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+// formatNetscapeCookies renders cookies as a cookies.txt curl and wget read
+func formatNetscapeCookies(cookies []Cookie) string {
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	upper := func(value bool) string {
+		return strings.ToUpper(strconv.FormatBool(value))
+	}
+	for _, cookie := range cookies {
+		if cookie.HTTPOnly {
+			b.WriteString("#HttpOnly_")
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", cookie.Domain, upper(strings.HasPrefix(cookie.Domain, ".")),
+			cookie.Path, upper(cookie.Secure), cookie.Expiry, cookie.Name, cookie.Value)
+	}
+	return b.String()
+}
+
+// runCookies implements `web cookies export`, writing a profile's cookie jar
+// out for curl, wget and other scrapers. It returns the process exit code.
+func runCookies(args []string) int {
+	config := newConfig()
+	format := ""
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printCookiesHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		{name: "--engine", kind: flagString, apply: func(engine string) error {
+			if !slices.Contains(engines, engine) {
+				return fmt.Errorf("--engine must be one of %s, got %q", strings.Join(engines, ", "), engine)
+			}
+			config.Engine = engine
+			return nil
+		}},
+		dataDirFlag,
+		{name: "--format", kind: flagString, apply: func(value string) error {
+			if !slices.Contains(cookieFormats, value) {
+				return fmt.Errorf("--format must be one of %s, got %q", strings.Join(cookieFormats, ", "), value)
+			}
+			format = value
+			return nil
+		}},
+	}
+	var positional []string
+	err := parseFlags(args, defs, func(arg string) error {
+		if len(positional) == 2 {
+			return fmt.Errorf("unexpected argument %q", arg)
+		}
+		if len(positional) == 0 && arg != "export" {
+			return fmt.Errorf("unknown command %q, expected export", arg)
+		}
+		positional = append(positional, arg)
+		return nil
+	})
+	if err == nil && len(positional) == 2 && positional[1] != "-" {
+		err = setFlag(flagDef{name: "export", kind: flagOutput, target: new(string)}, positional[1])
+	}
+	if err == nil && config.Engine == "webkit" {
+		err = fmt.Errorf("--engine webkit does not keep profiles, so it has no cookies to export")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web cookies --help' for usage.\n", err)
+		return 1
+	}
+	if len(positional) < 2 {
+		printCookiesHelp()
+		return 1
+	}
+	path := positional[1]
+	if format == "" {
+		format = cookieFormatForPath(path)
+	}
+
+	jarPath, err := cookieJarPath(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if _, err := os.Stat(jarPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: profile %q has no cookies yet, they are collected by runs on it\n", config.Profile)
+		return 1
+	}
+	var cookies []Cookie
+	err = withCookieJar(config, func(jar *cookieJar) error {
+		cookies = slices.Clone(jar.cookies)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var data []byte
+	if format == "json" {
+		encoded, err := json.MarshalIndent(cookies, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not encode cookies: %v\n", err)
+			return 1
+		}
+		data = append(encoded, '\n')
+	} else {
+		data = []byte(formatNetscapeCookies(cookies))
+	}
+
+	if path == "-" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	// Cookies are credentials, keep them to the user
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not write %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d cookie(s) of profile %q to %s\n", len(cookies), config.Profile, path)
+	return 0
+}
+
+func printCookiesHelp() {
+	fmt.Print(`web cookies - share a profile's cookies with curl, wget and other scrapers
+
+Usage: web cookies export <file> [options]
+
+Writes the cookies of the profile's cookie jar, which runs on the profile add
+the cookies of their final page to, as a Netscape cookies.txt (curl -b, wget
+--load-cookies) or as JSON. "-" writes to stdout. Load cookie files into a
+run with web <url> --cookies-file <file>.
+
+Options:
+  --help                     Show this help message
+  --profile <name>           Profile to export (default: default)
+  --engine <name>            Browser the profile belongs to: firefox (default) or chromium
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --format <format>          netscape or json (default: json for a .json file, otherwise netscape)
+`)
+}
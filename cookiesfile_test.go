@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNetscapeCookies(t *testing.T) {
+	cookies := []Cookie{
+		{Name: "_app_key", Value: "SFMyNTY", Domain: "example.com", Path: "/", Secure: true, HTTPOnly: true},
+		{Name: "theme", Value: "dark", Domain: ".example.com", Path: "/settings", Expiry: 4102444800},
+	}
+	text := formatNetscapeCookies(cookies)
+	expected := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tTRUE\t0\t_app_key\tSFMyNTY\n" +
+		".example.com\tTRUE\t/settings\tFALSE\t4102444800\ttheme\tdark\n"
+	if text != expected {
+		t.Errorf("formatNetscapeCookies =\n%q\nexpected\n%q", text, expected)
+	}
+
+	parsed, err := parseNetscapeCookies(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, cookies) {
+		t.Errorf("Round trip gave %+v, expected %+v", parsed, cookies)
+	}
+
+	if _, err := parseNetscapeCookies("example.com\tFALSE\t/\n"); err == nil {
+		t.Errorf("Expected a short line to be rejected")
+	}
+}
+
+func TestReadCookiesFile(t *testing.T) {
+	dir := t.TempDir()
+	netscape := filepath.Join(dir, "cookies.txt")
+	os.WriteFile(netscape, []byte("example.com\tFALSE\t/\tFALSE\t0\tsession\tabc\nexample.com\tFALSE\t/\tFALSE\t1\told\tgone\n"), 0600)
+	jsonFile := filepath.Join(dir, "cookies.json")
+	writeCookies(jsonFile, []Cookie{{Name: "session", Value: "abc", Domain: "example.com", Path: "/"}})
+
+	for _, path := range []string{netscape, jsonFile} {
+		cookies, err := readCookiesFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The expired cookie is left out
+		if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc" {
+			t.Errorf("readCookiesFile(%s) = %+v", filepath.Base(path), cookies)
+		}
+	}
+}
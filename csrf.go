@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/tebeka/selenium"
+)
+
+// installCSRFHandler makes requests built by --js or --script code carry the
+// page's CSRF token, the way the app's own JS would. The token is read from a
+// csrf-token meta tag or a hidden token input (Phoenix _csrf_token, Rails
+// authenticity_token, Django csrfmiddlewaretoken). Same-origin fetch and XHR
+// requests that change state get an x-csrf-token header, and forms submitted
+// without a token field get a hidden one. It returns whether a token was found.
+func installCSRFHandler(wd selenium.WebDriver) (bool, error) {
+	found, err := wd.ExecuteScript(`
+		var fieldNames = ['_csrf_token', 'authenticity_token', 'csrfmiddlewaretoken', '_token'];
+		function csrfToken() {
+			var meta = document.querySelector('meta[name="csrf-token"], meta[name="csrf_token"]');
+			if (meta && meta.content) {
+				return { name: '_csrf_token', value: meta.content };
+			}
+			for (var i = 0; i < fieldNames.length; i++) {
+				var input = document.querySelector('input[name="' + fieldNames[i] + '"]');
+				if (input && input.value) {
+					return { name: fieldNames[i], value: input.value };
+				}
+			}
+			return null;
+		}
+		function needsToken(method, url) {
+			if (/^(GET|HEAD|OPTIONS)$/i.test(method || 'GET')) {
+				return false;
+			}
+			try { return new URL(url, location.href).origin === location.origin; }
+			catch (e) { return false; }
+		}
+
+		if (!window.__csrfHandlerInstalled) {
+			window.__csrfHandlerInstalled = true;
+
+			var originalFetch = window.fetch;
+			window.fetch = function(input, init) {
+				var token = csrfToken();
+				var method = (init && init.method) || (input instanceof Request ? input.method : 'GET');
+				var url = input instanceof Request ? input.url : String(input);
+				if (token && needsToken(method, url)) {
+					init = Object.assign({}, init);
+					var headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+					if (!headers.has('x-csrf-token')) {
+						headers.set('x-csrf-token', token.value);
+					}
+					init.headers = headers;
+				}
+				return originalFetch.call(this, input, init);
+			};
+
+			var originalOpen = XMLHttpRequest.prototype.open;
+			var originalSend = XMLHttpRequest.prototype.send;
+			var originalSetHeader = XMLHttpRequest.prototype.setRequestHeader;
+			XMLHttpRequest.prototype.open = function(method, url) {
+				this.__csrfNeeded = needsToken(method, url);
+				this.__csrfSet = false;
+				return originalOpen.apply(this, arguments);
+			};
+			XMLHttpRequest.prototype.setRequestHeader = function(name) {
+				if (String(name).toLowerCase() === 'x-csrf-token') {
+					this.__csrfSet = true;
+				}
+				return originalSetHeader.apply(this, arguments);
+			};
+			XMLHttpRequest.prototype.send = function() {
+				var token = csrfToken();
+				if (token && this.__csrfNeeded && !this.__csrfSet) {
+					originalSetHeader.call(this, 'x-csrf-token', token.value);
+				}
+				return originalSend.apply(this, arguments);
+			};
+
+			function addTokenField(form) {
+				var token = csrfToken();
+				if (!token || !needsToken(form.method, form.action)) {
+					return;
+				}
+				for (var i = 0; i < fieldNames.length; i++) {
+					if (form.querySelector('input[name="' + fieldNames[i] + '"]')) {
+						return;
+					}
+				}
+				var field = document.createElement('input');
+				field.type = 'hidden';
+				field.name = token.name;
+				field.value = token.value;
+				form.appendChild(field);
+			}
+			// form.submit() skips submit events, so cover it as well as regular submissions
+			document.addEventListener('submit', function(event) { addTokenField(event.target); }, true);
+			var originalSubmit = HTMLFormElement.prototype.submit;
+			HTMLFormElement.prototype.submit = function() {
+				addTokenField(this);
+				return originalSubmit.apply(this, arguments);
+			};
+		}
+
+		return csrfToken() !== null;
+	`, nil)
+	return found == true, err
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonRequest is a `web <url>` run sent to the daemon: the client's
+// arguments, and the working directory and environment to run them in
+type daemonRequest struct {
+	Args []string `json:"args,omitempty"`
+	Dir  string   `json:"dir,omitempty"`
+	Env  []string `json:"env,omitempty"`
+	Stop bool     `json:"stop,omitempty"`
+}
+
+// daemonReply is one line of the daemon's answer: output as it is printed,
+// then the exit code
+type daemonReply struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	Exit   *int   `json:"exit,omitempty"`
+}
+
+// daemonSocket is where the daemon for an engine's profile listens
+func daemonSocket(engine, profile string) (string, error) {
+	dir, err := engineDir(engine)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon-"+profile+".sock"), nil
+}
+
+// daemon keeps a browser session open between requests, in a worker process
+// of the daemon's pool. Requests run one at a time, each in the client's
+// directory and environment with its output streamed back.
+type daemon struct {
+	config Config
+	mu     sync.Mutex
+	runner *Runner
+	// recycleAfter restarts the browser after that many runs, served counts them
+	recycleAfter int
+	served       int
+}
+
+// runDaemon implements `web daemon`, serving `web <url>` runs for a profile
+// from browsers that stay open. It returns the process exit code.
+func runDaemon(args []string) int {
+	config := newConfig()
+	stop := false
+	maxConcurrency := 1
+	recycleAfter := 0
+	var idleTimeout time.Duration
+	workerSocket := ""
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printDaemonHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		dataDirFlag,
+		{name: "--encrypt-profile", kind: flagBool, target: &config.EncryptProfile},
+		{name: "--engine", kind: flagString, apply: func(engine string) error {
+			if !slices.Contains(engines, engine) {
+				return fmt.Errorf("--engine must be one of %s, got %q", strings.Join(engines, ", "), engine)
+			}
+			config.Engine = engine
+			return nil
+		}},
+		{name: "--browser-path", kind: flagFile, target: &config.BrowserPath},
+		{name: "--max-concurrency", kind: flagInt, target: &maxConcurrency},
+		{name: "--idle-timeout", kind: flagDuration, target: &idleTimeout},
+		{name: "--recycle-after", kind: flagInt, target: &recycleAfter},
+		{name: "--max-memory", kind: flagString, apply: func(value string) (err error) {
+			config.MaxMemory, err = parseMemorySize(value)
+			return err
+		}},
+		{name: "--max-cpu", kind: flagInt, target: &config.MaxCPU},
+		{name: "--stop", kind: flagBool, target: &stop},
+		// Internal, a pool's browser process serving on this socket
+		{name: "--worker", kind: flagString, target: &workerSocket},
+	}
+	err := parseFlags(args, defs, func(arg string) error {
+		return fmt.Errorf("unexpected argument %q", arg)
+	})
+	if err == nil && maxConcurrency > 1 && config.EncryptProfile {
+		err = fmt.Errorf("--max-concurrency runs extra browsers on copies of the profile, which --encrypt-profile would leave unencrypted")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web daemon --help' for usage.\n", err)
+		return 1
+	}
+
+	if workerSocket != "" {
+		return runWorker(config, workerSocket, recycleAfter)
+	}
+
+	socketPath, err := daemonSocket(config.Engine, config.Profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if stop {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no daemon is running for profile %q\n", config.Profile)
+			return 1
+		}
+		defer conn.Close()
+		json.NewEncoder(conn).Encode(daemonRequest{Stop: true})
+		io.Copy(io.Discard, conn)
+		fmt.Printf("Stopped the daemon for profile %q\n", config.Profile)
+		return 0
+	}
+
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "Error: a daemon is already running for profile %q (%s)\n", config.Profile, socketPath)
+		return 1
+	}
+	// Left behind by a daemon that didn't shut down cleanly
+	os.Remove(socketPath)
+
+	ensureBrowser(config)
+
+	// The first browser starts now, so the first run finds it open
+	pool := newWorkerPool(config, socketPath, maxConcurrency, idleTimeout)
+	pool.recycleAfter = recycleAfter
+	w, err := pool.acquire("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+	pool.release(w)
+
+	shutdown, _, err := listenDaemon(socketPath, pool.serve)
+	if err != nil {
+		pool.close()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if idleTimeout > 0 {
+		go pool.evict(shutdown)
+	}
+
+	fmt.Printf("Daemon ready for profile %q on %s (stop with web daemon --stop)\n", config.Profile, socketPath)
+	<-shutdown
+	pool.close()
+	os.Remove(socketPath)
+	fmt.Println("Daemon stopped")
+	return 0
+}
+
+// runWorker serves runs on socketPath from one browser, for the daemon that
+// started it, restarting the browser every recycleAfter runs when set. It
+// stops when the daemon closes its stdin or exits.
+func runWorker(config Config, socketPath string, recycleAfter int) int {
+	d := &daemon{config: config, runner: newRunner(config), recycleAfter: recycleAfter}
+	if err := d.runner.start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+
+	os.Remove(socketPath)
+	shutdown, closeDaemon, err := listenDaemon(socketPath, d.serve)
+	if err != nil {
+		d.runner.close()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	go func() {
+		io.Copy(io.Discard, os.Stdin)
+		closeDaemon()
+	}()
+
+	<-shutdown
+	d.mu.Lock()
+	d.runner.close()
+	os.Remove(socketPath)
+	return 0
+}
+
+// listenDaemon accepts connections on socketPath, handing each to handle,
+// until the returned close function is called or the process is interrupted.
+// The returned channel is closed at that point.
+func listenDaemon(socketPath string, handle func(conn net.Conn, closeDaemon func())) (chan struct{}, func(), error) {
+	// The socket runs anything the user could, keep it to the user
+	os.MkdirAll(filepath.Dir(socketPath), 0700)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not listen on %s: %v", socketPath, err)
+	}
+	os.Chmod(socketPath, 0600)
+
+	shutdown := make(chan struct{})
+	var once sync.Once
+	closeDaemon := func() {
+		once.Do(func() {
+			listener.Close()
+			close(shutdown)
+		})
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		closeDaemon()
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn, closeDaemon)
+		}
+	}()
+	return shutdown, closeDaemon, nil
+}
+
+// session returns the daemon's runner for a request it can serve. Requests
+// that need the browser launched differently (a proxy, a visible window, other
+// capabilities) get nil and start a browser of their own like a one-shot run.
+// That browser needs the profile, so the runner's is closed first and
+// reopened for the next request.
+func (d *daemon) session(config Config) (*Runner, error) {
+	if config.Hold || config.Devtools || hasPause(config) {
+		return nil, fmt.Errorf("--hold, --pause and --devtools wait for the terminal, which the daemon doesn't have: stop it (web daemon --stop) or use another --profile")
+	}
+
+	launch := config.BrowserPath != d.config.BrowserPath || config.EncryptProfile != d.config.EncryptProfile ||
+		config.MaxMemory != "" && config.MaxMemory != d.config.MaxMemory || config.MaxCPU > 0 && config.MaxCPU != d.config.MaxCPU ||
+		config.Headed || config.NoJS || config.UserAgent != "" || config.EnableGPU || config.WebGL || config.ScreenshotScale > 0 || config.DialogMode != "" || config.WaitUntil == "domcontentloaded" ||
+		needsProxy(config)
+	if launch {
+		if config.Session != "" {
+			return nil, fmt.Errorf("--session pages stay in the daemon's browser, which can't be launched as this run asks (e.g. --headed, --headers, --warc)")
+		}
+		if len(d.runner.pages) > 0 {
+			return nil, fmt.Errorf("this run needs a browser of its own (e.g. for --headed, --headers, --warc), which would close the daemon's --session pages")
+		}
+		d.runner.close()
+		return nil, nil
+	}
+
+	if err := d.runner.start(); err != nil {
+		return nil, fmt.Errorf("could not start browser: %v", err)
+	}
+	if config.Session != "" {
+		if err := d.runner.usePage(config.Session, config.URL == ""); err != nil {
+			return nil, err
+		}
+	}
+	return d.runner, nil
+}
+
+// hasPause reports whether the run stops for Enter at a --pause
+func hasPause(config Config) bool {
+	for _, action := range config.Actions {
+		if action.Type == "pause" {
+			return true
+		}
+	}
+	for _, step := range config.Script {
+		if step.Pause != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// serve runs the request on conn in the client's directory and environment,
+// streaming what it prints back
+func (d *daemon) serve(conn net.Conn, closeDaemon func()) {
+	defer conn.Close()
+
+	var request daemonRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		return
+	}
+
+	var sendMu sync.Mutex
+	encoder := json.NewEncoder(conn)
+	send := func(reply daemonReply) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		encoder.Encode(reply)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if request.Stop {
+		code := 0
+		send(daemonReply{Exit: &code})
+		closeDaemon()
+		return
+	}
+
+	// A request that overran --max-runtime took the browser down with it
+	if !d.runner.alive() {
+		d.runner.close()
+	}
+
+	restore := useClientContext(request)
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	savedStdout, savedStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutW, stderrW
+
+	var forwarding sync.WaitGroup
+	forward := func(r io.Reader, reply func(string) daemonReply) {
+		defer forwarding.Done()
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				send(reply(line))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	forwarding.Add(2)
+	go forward(stdoutR, func(s string) daemonReply { return daemonReply{Stdout: s} })
+	go forward(stderrR, func(s string) daemonReply { return daemonReply{Stderr: s} })
+
+	code := runFetch(request.Args, d)
+
+	os.Stdout, os.Stderr = savedStdout, savedStderr
+	stdoutW.Close()
+	stderrW.Close()
+	forwarding.Wait()
+	restore()
+
+	// A fresh browser keeps long unattended runs from growing without bound,
+	// unless it would take --session pages with it
+	d.served++
+	if d.recycleAfter > 0 && d.served >= d.recycleAfter && d.runner.wd != nil && len(d.runner.pages) == 0 {
+		logf("Restarting the browser after %d runs\n", d.served)
+		d.runner.close()
+		d.served = 0
+	}
+
+	send(daemonReply{Exit: &code})
+}
+
+// useClientContext switches the daemon to the client's working directory and
+// environment, so relative paths and ${NAME} variables resolve as they would
+// in a one-shot run, and returns a function that switches back
+func useClientContext(request daemonRequest) func() {
+	savedDir, _ := os.Getwd()
+	savedEnv := os.Environ()
+
+	os.Chdir(request.Dir)
+	os.Clearenv()
+	for _, entry := range request.Env {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			os.Setenv(name, value)
+		}
+	}
+
+	return func() {
+		os.Chdir(savedDir)
+		os.Clearenv()
+		for _, entry := range savedEnv {
+			if name, value, ok := strings.Cut(entry, "="); ok {
+				os.Setenv(name, value)
+			}
+		}
+	}
+}
+
+// sendToDaemon runs a request on the `web daemon` for its engine and profile,
+// relaying its output. It reports false when no daemon is running, for the
+// caller to run the request itself.
+func sendToDaemon(config Config, args []string) (int, bool) {
+	socketPath, err := daemonSocket(config.Engine, config.Profile)
+	if err != nil {
+		return 0, false
+	}
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+
+	dir, _ := os.Getwd()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Args: args, Dir: dir, Env: os.Environ()}); err != nil {
+		return 0, false
+	}
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var reply daemonReply
+		if err := decoder.Decode(&reply); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: lost connection to the daemon: %v\n", err)
+			return 1, true
+		}
+		switch {
+		case reply.Exit != nil:
+			return *reply.Exit, true
+		case reply.Stdout != "":
+			os.Stdout.WriteString(reply.Stdout)
+		case reply.Stderr != "":
+			os.Stderr.WriteString(reply.Stderr)
+		}
+	}
+}
+
+func printDaemonHelp() {
+	fmt.Print(`web daemon - keep a browser open to serve web runs without startup time
+
+Usage: web daemon [options]
+
+While the daemon runs, web <url> runs for the same --profile and --engine are
+sent to it and start in well under a second. Without a daemon they start a
+browser of their own as usual. Runs are served one at a time, or up to
+--max-concurrency at once from that many browsers, the others waiting their
+turn. Browsers past the first start on a copy of the profile, and what runs
+change in it there isn't kept.
+
+A run with --session <name> leaves its page open in the daemon, and a later
+run with the same --session and no URL continues on that page.
+
+Options:
+  --help                     Show this help message
+  --profile <name>           Serve runs using this session profile (default: "default")
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --encrypt-profile          Keep the profile encrypted at rest (passphrase from WEB_PROFILE_PASSPHRASE or the OS keychain)
+  --engine <name>            Browser to keep open: firefox (default), chromium or webkit
+  --browser-path <path>      Run this browser executable instead of downloading one
+  --max-concurrency <n>      Serve up to n runs at once, each in a browser of its own (default: 1)
+  --idle-timeout <duration>  Close browsers left idle this long, reopening them for the next run (default: never)
+  --recycle-after <n>        Restart each browser after it has served n runs, so long unattended use doesn't
+                             build up memory
+  --max-memory <size>        Cap the memory of each browser and its driver, e.g. 2G (Linux; without systemd
+                             each process's address space is capped)
+  --max-cpu <percent>        Cap the CPU use of each browser and its driver, 100 being one core (Linux with
+                             systemd, the run fails elsewhere)
+  --stop                     Stop the daemon running for --profile and --engine
+`)
+}
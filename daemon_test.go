@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendToDaemon(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := Config{Engine: "firefox", Profile: "default"}
+
+	if _, ok := sendToDaemon(config, []string{"example.com"}); ok {
+		t.Fatalf("Expected no daemon to be found")
+	}
+
+	socketPath, err := daemonSocket(config.Engine, config.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(socketPath), 0700)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan daemonRequest, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var request daemonRequest
+		json.NewDecoder(conn).Decode(&request)
+		received <- request
+		encoder := json.NewEncoder(conn)
+		encoder.Encode(daemonReply{Stderr: "Navigating...\n"})
+		code := 3
+		encoder.Encode(daemonReply{Exit: &code})
+	}()
+
+	code, ok := sendToDaemon(config, []string{"example.com", "--links"})
+	if !ok || code != 3 {
+		t.Errorf("sendToDaemon = %d, %v, expected the daemon's exit code 3", code, ok)
+	}
+	request := <-received
+	dir, _ := os.Getwd()
+	if len(request.Args) != 2 || request.Args[1] != "--links" || request.Dir != dir || len(request.Env) == 0 {
+		t.Errorf("Request sent incorrectly: %+v", request)
+	}
+}
+
+func TestUseClientContext(t *testing.T) {
+	t.Setenv("WEB_DAEMON_TEST", "daemon")
+	dir := t.TempDir()
+	before, _ := os.Getwd()
+
+	restore := useClientContext(daemonRequest{Dir: dir, Env: []string{"WEB_DAEMON_TEST=client", "PATH=/bin"}})
+	if os.Getenv("WEB_DAEMON_TEST") != "client" {
+		t.Errorf("Expected the client's environment, got WEB_DAEMON_TEST=%q", os.Getenv("WEB_DAEMON_TEST"))
+	}
+	cwd, _ := os.Stat(".")
+	want, _ := os.Stat(dir)
+	if !os.SameFile(cwd, want) {
+		t.Errorf("Expected to run in %s", dir)
+	}
+
+	restore()
+	if os.Getenv("WEB_DAEMON_TEST") != "daemon" {
+		t.Errorf("Expected the daemon's environment back, got WEB_DAEMON_TEST=%q", os.Getenv("WEB_DAEMON_TEST"))
+	}
+	if after, _ := os.Getwd(); after != before {
+		t.Errorf("Expected to be back in %s, got %s", before, after)
+	}
+}
+
+func TestWorkerPoolServe(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "worker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			var request daemonRequest
+			json.NewDecoder(conn).Decode(&request)
+			code := len(request.Args)
+			json.NewEncoder(conn).Encode(daemonReply{Exit: &code})
+			conn.Close()
+		}
+	}()
+
+	pool := newWorkerPool(Config{}, filepath.Join(dir, "daemon.sock"), 1, 0)
+	w := &worker{socket: socket, done: make(chan struct{})}
+	pool.workers[0] = w
+	pool.idle = []*worker{w}
+
+	run := func() []daemonReply {
+		client, server := net.Pipe()
+		go pool.serve(server, func() {})
+		defer client.Close()
+		json.NewEncoder(client).Encode(daemonRequest{Args: []string{"example.com", "--links"}})
+		var replies []daemonReply
+		decoder := json.NewDecoder(client)
+		for {
+			var reply daemonReply
+			if decoder.Decode(&reply) != nil {
+				return replies
+			}
+			replies = append(replies, reply)
+			if reply.Exit != nil {
+				return replies
+			}
+		}
+	}
+
+	replies := run()
+	if len(replies) != 1 || *replies[0].Exit != 2 {
+		t.Fatalf("Expected the worker's exit code 2, got %+v", replies)
+	}
+
+	// With the only slot taken, the next run waits for it
+	pool.slots <- struct{}{}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		<-pool.slots
+	}()
+	replies = run()
+	if len(replies) != 2 || !strings.Contains(replies[0].Stderr, "busy") || *replies[1].Exit != 2 {
+		t.Errorf("Expected a waiting notice then the exit code, got %+v", replies)
+	}
+}
+
+func TestDaemonMaxConcurrencyEncrypted(t *testing.T) {
+	if code := runDaemon([]string{"--max-concurrency", "2", "--encrypt-profile"}); code != 1 {
+		t.Errorf("Expected --max-concurrency with --encrypt-profile to fail, got exit code %d", code)
+	}
+}
+
+func TestRequestSession(t *testing.T) {
+	tests := map[string][]string{
+		"":      {"example.com", "--links"},
+		"login": {"--session", "login", "--links"},
+		"cart":  {"example.com", "--session=cart"},
+	}
+	for want, args := range tests {
+		if got := requestSession(args); got != want {
+			t.Errorf("requestSession(%q) = %q, expected %q", args, got, want)
+		}
+	}
+}
+
+func TestSessionNeedsDaemon(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if code := runFetch([]string{"--session", "login"}, nil); code != 1 {
+		t.Errorf("Expected --session without a daemon to fail, got exit code %d", code)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tebeka/selenium"
+)
+
+// installDialogHandler replaces alert/confirm/prompt with versions that answer
+// according to mode ("accept" or "dismiss") and record what was asked. The log
+// lives in sessionStorage so dialogs that trigger a same-origin navigation
+// (e.g. "Delete?" confirmations on forms) still show up in the output.
+func installDialogHandler(wd selenium.WebDriver, mode, text string) error {
+	_, err := wd.ExecuteScript(`
+		if (!window.__dialogHandlerInstalled) {
+			window.__dialogHandlerInstalled = true;
+			var accept = arguments[0] === 'accept';
+			var promptText = arguments[1];
+			function record(type, message, response) {
+				var log = JSON.parse(sessionStorage.getItem('__webDialogs') || '[]');
+				log.push({ type: type, message: String(message === undefined ? '' : message), response: response });
+				sessionStorage.setItem('__webDialogs', JSON.stringify(log));
+			}
+			window.alert = function(message) {
+				record('alert', message, 'accepted');
+			};
+			window.confirm = function(message) {
+				record('confirm', message, accept ? 'accepted' : 'dismissed');
+				return accept;
+			};
+			window.prompt = function(message, defaultValue) {
+				if (!accept) {
+					record('prompt', message, 'dismissed');
+					return null;
+				}
+				var value = promptText !== null ? promptText : (defaultValue || '');
+				record('prompt', message, 'answered ' + JSON.stringify(value));
+				return value;
+			};
+		}
+	`, []interface{}{mode, dialogText(text)})
+	return err
+}
+
+// dialogText maps an unset --dialog-text to null so prompts fall back to their default value
+func dialogText(text string) interface{} {
+	if text == "" {
+		return nil
+	}
+	return text
+}
+
+// collectDialogs returns the dialogs answered so far, formatted for the output
+func collectDialogs(wd selenium.WebDriver) ([]string, error) {
+	raw, err := wd.ExecuteScript("return JSON.parse(sessionStorage.getItem('__webDialogs') || '[]')", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialogs []string
+	if items, ok := raw.([]interface{}); ok {
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			dialogs = append(dialogs, fmt.Sprintf("[%v] %v -> %v", entry["type"], entry["message"], entry["response"]))
+		}
+	}
+	return dialogs, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "# Todos\n\n- Buy milk\n- Walk dog\n\nFooter\nline 7\nline 8\nline 9\nline 10\nline 11\nlast"
+	after := "# Todos\n\n- Buy milk\n- Walk cat\n- Call mom\n\nFooter\nline 7\nline 8\nline 9\nline 10\nline 11\nlast!"
+
+	expected := `--- before
++++ after
+@@ -3,3 +3,4 @@
+ - Buy milk
+-- Walk dog
++- Walk cat
++- Call mom
+
+@@ -11,2 +12,2 @@
+ line 11
+-last
++last!
+`
+	if diff := unifiedDiff(before, after, "before", "after", 1); diff != expected {
+		t.Errorf("unifiedDiff() =\n%s\nexpected\n%s", diff, expected)
+	}
+
+	if diff := unifiedDiff(before, before, "before", "after", 3); diff != "" {
+		t.Errorf("unifiedDiff() of identical text = %q, expected empty", diff)
+	}
+
+	if diff := unifiedDiff("", "new", "a", "b", 3); diff != "--- a\n+++ b\n@@ -1 +1 @@\n-\n+new\n" {
+		t.Errorf("unifiedDiff() from empty = %q", diff)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadZipChecksum(t *testing.T) {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	f, _ := w.Create("firefox/firefox")
+	f.Write([]byte("#!/bin/sh\n"))
+	w.Close()
+	sum := sha256.Sum256(archive.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	}))
+	defer server.Close()
+
+	dest := t.TempDir()
+	wrong := strings.Repeat("0", 64)
+	if _, err := downloadZip("Firefox", server.URL, dest, wrong); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "firefox", "firefox")); err == nil {
+		t.Errorf("Archive was extracted despite the checksum mismatch")
+	}
+
+	got, err := downloadZip("Firefox", server.URL, dest, strings.ToUpper(checksum))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != checksum {
+		t.Errorf("downloadZip returned %s, expected %s", got, checksum)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "firefox", "firefox")); err != nil {
+		t.Errorf("Expected the archive to be extracted: %v", err)
+	}
+}
+
+func TestExtractZipStaysInDest(t *testing.T) {
+	writeZip := func(entries ...func(*zip.Writer)) string {
+		path := filepath.Join(t.TempDir(), "archive.zip")
+		file, _ := os.Create(path)
+		w := zip.NewWriter(file)
+		for _, entry := range entries {
+			entry(w)
+		}
+		w.Close()
+		file.Close()
+		return path
+	}
+	regular := func(name string) func(*zip.Writer) {
+		return func(w *zip.Writer) {
+			f, _ := w.Create(name)
+			f.Write([]byte("data"))
+		}
+	}
+	symlink := func(name, target string) func(*zip.Writer) {
+		return func(w *zip.Writer) {
+			header := &zip.FileHeader{Name: name}
+			header.SetMode(os.ModeSymlink | 0777)
+			f, _ := w.CreateHeader(header)
+			f.Write([]byte(target))
+		}
+	}
+
+	root := t.TempDir()
+	dest := filepath.Join(root, "dest")
+	for name, archive := range map[string]string{
+		"parent path":   writeZip(regular("../escaped")),
+		"absolute link": writeZip(symlink("link", root)),
+		"relative link": writeZip(symlink("Versions/link", "../../escaped")),
+	} {
+		if err := extractZip(archive, dest); err == nil {
+			t.Errorf("%s: Expected the archive to be refused", name)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(root, "escaped")); err == nil {
+		t.Error("Expected nothing written outside dest")
+	}
+
+	// Links within dest, like a framework's current version, are kept
+	archive := writeZip(regular("Versions/A/lib"), symlink("Versions/Current", "A"))
+	if err := extractZip(archive, dest); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "Versions", "Current", "lib")); err != nil || string(data) != "data" {
+		t.Errorf("Expected the file through the link, got %q, %v", data, err)
+	}
+}
+
+func TestEnsureFirefoxMirrorErrors(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	t.Setenv("WEB_BROWSER_MIRROR", failing.URL)
+
+	// The last mirror's error says why none of them worked
+	err := ensureFirefox("", "", "")
+	if err == nil || !strings.Contains(err.Error(), "from any mirror: bad status: 503") {
+		t.Errorf("Expected the mirror's error, got %v", err)
+	}
+
+	serving := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not the archive"))
+	}))
+	defer serving.Close()
+	t.Setenv("WEB_BROWSER_MIRROR", serving.URL)
+	if err := ensureFirefox("", strings.Repeat("0", 64), ""); !errors.Is(err, errChecksumMismatch) {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// webDriverService is a running WebDriver server
+type webDriverService interface {
+	Stop() error
+}
+
+// driverService is a WebDriver server we started. The selenium client only
+// knows how to launch geckodriver at the root URL that webDriverRequest
+// expects, and can't put it under resource limits, so drivers are started
+// here.
+type driverService struct {
+	cmd *exec.Cmd
+}
+
+// driverPorts maps each session to the port of the driver serving it, for
+// the commands webDriverRequest sends past the selenium client
+var (
+	driverPortsMu sync.Mutex
+	driverPorts   = map[string]int{}
+)
+
+// setDriverPort records the port of wd's driver, or forgets it when port is 0
+func setDriverPort(wd selenium.WebDriver, port int) {
+	driverPortsMu.Lock()
+	defer driverPortsMu.Unlock()
+	if port == 0 {
+		delete(driverPorts, wd.SessionID())
+	} else {
+		driverPorts[wd.SessionID()] = port
+	}
+}
+
+// driverPort is the port of the driver serving wd
+func driverPort(wd selenium.WebDriver) int {
+	driverPortsMu.Lock()
+	defer driverPortsMu.Unlock()
+	return driverPorts[wd.SessionID()]
+}
+
+// startDriver runs a WebDriver server, under --max-memory and --max-cpu when
+// given, and waits for it to accept sessions on port
+func startDriver(config Config, port int, name string, args ...string) (*driverService, error) {
+	command, commandArgs, err := limitCommand(config, name, args)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(command, commandArgs...)
+	cmd.Env = os.Environ()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// The driver only launches the browser once a session is asked for, so
+	// it is limited in time for the browser to inherit the limit
+	if err := limitProcess(config, cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("could not limit the memory of %s: %v", name, err)
+	}
+
+	statusURL := fmt.Sprintf("http://localhost:%d/status", port)
+	for i := 0; i < 100; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if resp, err := http.Get(statusURL); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return &driverService{cmd: cmd}, nil
+			}
+		}
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	return nil, fmt.Errorf("%s did not respond on port %d", name, port)
+}
+
+func (s *driverService) Stop() error {
+	if err := s.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	s.cmd.Wait()
+	return nil
+}
+
+// freePort asks the system for a TCP port nothing is listening on
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// Element is something on the page an agent can click or type into, for --elements
+type Element struct {
+	Index    int    `json:"index"`
+	Tag      string `json:"tag"`
+	Role     string `json:"role,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Text     string `json:"text"`
+	Selector string `json:"selector"`
+	Href     string `json:"href,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Box      Box    `json:"box"`
+	// InViewport is set when the element is at least partly on screen,
+	// and so in a screenshot
+	InViewport bool `json:"in_viewport"`
+}
+
+// Box is an element's position in CSS pixels, relative to the viewport
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// collectElementsJS lists visible links, buttons and fields in document
+// order, with the text an agent would use to tell them apart
+const collectElementsJS = selectorForJS + `
+	var interactive = 'a[href], area[href], button, input:not([type=hidden]), select, textarea, summary, ' +
+		'[contenteditable=""], [contenteditable="true"], [tabindex]:not([tabindex="-1"]), [onclick], [phx-click], ' +
+		'[role=button], [role=link], [role=checkbox], [role=radio], [role=switch], [role=tab], [role=menuitem], ' +
+		'[role=option], [role=combobox], [role=textbox], [role=searchbox], [role=slider]';
+	var implicitRoles = { a: 'link', area: 'link', button: 'button', select: 'combobox', textarea: 'textbox', summary: 'button' };
+	var inputRoles = { checkbox: 'checkbox', radio: 'radio', range: 'slider', search: 'searchbox',
+		submit: 'button', reset: 'button', button: 'button', image: 'button' };
+
+	var textOf = function(el) {
+		var labelledBy = (el.getAttribute('aria-labelledby') || '').split(/\s+/).map(function(id) {
+			var labelEl = id && document.getElementById(id);
+			return labelEl ? labelEl.innerText : '';
+		}).join(' ').trim();
+		var text = el.getAttribute('aria-label') || labelledBy ||
+			(el.labels && el.labels.length ? el.labels[0].innerText : '') ||
+			(el.tagName === 'INPUT' && ['submit', 'reset', 'button'].indexOf(el.type) !== -1 ? el.value : '') ||
+			el.innerText || el.getAttribute('placeholder') || el.getAttribute('title') || el.getAttribute('alt') || '';
+		if (!text) {
+			var img = el.querySelector && el.querySelector('img[alt]');
+			text = img ? img.alt : '';
+		}
+		text = text.replace(/\s+/g, ' ').trim();
+		return text.length > 80 ? text.slice(0, 77) + '...' : text;
+	};
+	var visible = function(el, rect) {
+		if (rect.width === 0 || rect.height === 0) {
+			return false;
+		}
+		var style = getComputedStyle(el);
+		return style.visibility !== 'hidden' && style.display !== 'none' && style.opacity !== '0';
+	};
+
+	var seen = new Set();
+	var elements = [];
+	document.querySelectorAll(interactive).forEach(function(el) {
+		// A button inside a link, or a link wrapping a button, is one target
+		for (var parent = el.parentElement; parent; parent = parent.parentElement) {
+			if (seen.has(parent) && parent.matches('a[href], button, [role=button], [role=link]')) {
+				return;
+			}
+		}
+		var rect = el.getBoundingClientRect();
+		if (!visible(el, rect)) {
+			return;
+		}
+		seen.add(el);
+
+		var tag = el.tagName.toLowerCase();
+		var type = tag === 'input' ? (el.type || 'text') : '';
+		var role = el.getAttribute('role') || implicitRoles[tag] || (tag === 'input' ? (inputRoles[type] || 'textbox') : '');
+		if (!role && el.isContentEditable) {
+			role = 'textbox';
+		}
+		elements.push({
+			index: elements.length + 1,
+			tag: tag,
+			role: role,
+			type: type,
+			text: textOf(el),
+			selector: selectorFor(el),
+			href: el.href && typeof el.href === 'string' ? el.href : '',
+			disabled: !!el.disabled || el.getAttribute('aria-disabled') === 'true',
+			box: { x: Math.round(rect.left), y: Math.round(rect.top), width: Math.round(rect.width), height: Math.round(rect.height) },
+			in_viewport: rect.bottom > 0 && rect.right > 0 && rect.top < window.innerHeight && rect.left < window.innerWidth
+		});
+	});
+	return JSON.stringify(elements);
+`
+
+// annotateElementsJS outlines each element and labels it with its number,
+// in an overlay that stays out of the way of the page's own events. Boxes are
+// placed in document coordinates so they line up on full page screenshots.
+const annotateElementsJS = `
+	var overlay = document.createElement('div');
+	overlay.id = '__webAnnotations';
+	overlay.style.cssText = 'position:absolute;left:0;top:0;width:0;height:0;overflow:visible;pointer-events:none;z-index:2147483647;';
+	arguments[0].forEach(function(element) {
+		var box = { x: element.box.x + window.scrollX, y: element.box.y + window.scrollY, width: element.box.width, height: element.box.height };
+		var outline = document.createElement('div');
+		outline.style.cssText = 'position:absolute;border:2px solid #e11d48;box-sizing:border-box;' +
+			'left:' + box.x + 'px;top:' + box.y + 'px;width:' + box.width + 'px;height:' + box.height + 'px;';
+		var badge = document.createElement('div');
+		badge.textContent = element.index;
+		badge.style.cssText = 'position:absolute;background:#e11d48;color:#fff;font:bold 11px/14px sans-serif;' +
+			'padding:0 3px;border-radius:3px;left:' + box.x + 'px;top:' + Math.max(0, box.y - 14) + 'px;';
+		overlay.appendChild(outline);
+		overlay.appendChild(badge);
+	});
+	document.documentElement.appendChild(overlay);
+`
+
+const removeAnnotationsJS = `
+	var overlay = document.getElementById('__webAnnotations');
+	if (overlay) { overlay.remove(); }
+`
+
+// collectElements lists the page's visible interactive elements, each with
+// a selector that --click, --input and scripts accept
+func collectElements(wd selenium.WebDriver) ([]Element, error) {
+	raw, err := wd.ExecuteScript(collectElementsJS, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not collect elements: %v", err)
+	}
+
+	elements := []Element{}
+	if err := json.Unmarshal([]byte(fmt.Sprint(raw)), &elements); err != nil {
+		return nil, fmt.Errorf("could not decode elements: %v", err)
+	}
+	return elements, nil
+}
+
+func formatElements(elements []Element) string {
+	if len(elements) == 0 {
+		return "No interactive elements found"
+	}
+	var b strings.Builder
+	for _, element := range elements {
+		kind := element.Role
+		if kind == "" {
+			kind = element.Tag
+		}
+		if element.Type != "" && element.Type != kind {
+			kind += " (" + element.Type + ")"
+		}
+		fmt.Fprintf(&b, "[%d] %s %q %s", element.Index, kind, element.Text, element.Selector)
+		if element.Href != "" {
+			fmt.Fprintf(&b, " -> %s", element.Href)
+		}
+		if element.Disabled {
+			b.WriteString(" disabled")
+		}
+		box := element.Box
+		fmt.Fprintf(&b, " @%d,%d %dx%d", box.X, box.Y, box.Width, box.Height)
+		if !element.InViewport {
+			b.WriteString(" offscreen")
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
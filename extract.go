@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// extractHTML returns the HTML of every element matching each --extract
+// selector, in selector order and then document order. Every selector must
+// match something, since an empty capture is almost always a stale selector.
+func extractHTML(wd selenium.WebDriver, config Config, selectors []string) ([]string, error) {
+	var parts []string
+	for _, selector := range selectors {
+		elems, err := findElements(wd, config, selector)
+		if err != nil || len(elems) == 0 {
+			return nil, fmt.Errorf("--extract: no elements match %s", selector)
+		}
+		for _, elem := range elems {
+			html, err := outerHTML(wd, config, elem)
+			if err != nil {
+				return nil, fmt.Errorf("--extract: could not read %s: %v", selector, err)
+			}
+			parts = append(parts, html)
+		}
+	}
+	return parts, nil
+}
+
+// schemaItemsKey turns a schema into a list with one entry per matching element
+const schemaItemsKey = "_items"
+
+// extractSchemaJS walks a --extract-schema definition. Field values are
+// "selector@attribute" strings (first match), one-element lists of them (all
+// matches) or nested schemas; "_items" repeats a schema for every element it
+// matches, with field selectors relative to that element. The attribute is
+// text by default, html for the inner HTML, or any attribute name, with
+// href and src resolved to absolute URLs.
+const extractSchemaJS = `
+	function read(el, attr) {
+		if (attr === 'text') { return el.innerText.trim(); }
+		if (attr === 'html') { return el.innerHTML; }
+		if ((attr === 'href' || attr === 'src') && typeof el[attr] === 'string' && el.getAttribute(attr) !== null) {
+			return el[attr];
+		}
+		return el.getAttribute(attr);
+	}
+	function parse(spec) {
+		var at = spec.lastIndexOf('@');
+		if (at !== -1 && /^[\w-]+$/.test(spec.slice(at + 1))) {
+			return { selector: spec.slice(0, at).trim(), attr: spec.slice(at + 1) };
+		}
+		return { selector: spec.trim(), attr: 'text' };
+	}
+	function matches(scope, selector) {
+		return selector ? Array.prototype.slice.call(scope.querySelectorAll(selector)) : [scope];
+	}
+	function fields(scope, schema) {
+		var result = {};
+		Object.keys(schema).forEach(function(key) {
+			if (key === '_items') { return; }
+			var value = schema[key];
+			if (typeof value === 'string') {
+				var spec = parse(value);
+				var el = matches(scope, spec.selector)[0];
+				result[key] = el ? read(el, spec.attr) : null;
+			} else if (Array.isArray(value)) {
+				var spec = parse(value[0]);
+				result[key] = matches(scope, spec.selector).map(function(el) { return read(el, spec.attr); });
+			} else {
+				result[key] = extract(scope, value);
+			}
+		});
+		return result;
+	}
+	function extract(scope, schema) {
+		if (schema._items) {
+			return matches(scope, schema._items).map(function(el) { return fields(el, schema); });
+		}
+		return fields(scope, schema);
+	}
+	return JSON.stringify(extract(document, JSON.parse(arguments[0])));
+`
+
+// loadSchema reads and validates an --extract-schema file, returning it as JSON
+func loadSchema(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read schema: %v", err)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return "", fmt.Errorf("could not parse schema %s (expected a JSON object): %v", path, err)
+	}
+	if err := validateSchema(schema, ""); err != nil {
+		return "", fmt.Errorf("schema %s: %v", path, err)
+	}
+	return string(data), nil
+}
+
+func validateSchema(schema map[string]interface{}, prefix string) error {
+	for key, value := range schema {
+		field := prefix + key
+		switch value := value.(type) {
+		case string:
+			if value == "" && key == schemaItemsKey {
+				return fmt.Errorf("%s must be a selector", field)
+			}
+		case []interface{}:
+			if len(value) != 1 || key == schemaItemsKey {
+				return fmt.Errorf("%s must be a list with a single selector", field)
+			}
+			if _, ok := value[0].(string); !ok {
+				return fmt.Errorf("%s must be a list with a single selector", field)
+			}
+		case map[string]interface{}:
+			if key == schemaItemsKey {
+				return fmt.Errorf("%s must be a selector", field)
+			}
+			if err := validateSchema(value, field+"."); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s must be a selector, a list with a single selector or a nested schema", field)
+		}
+	}
+	return nil
+}
+
+// extractSchema runs the schema against the current page and returns indented JSON
+func extractSchema(wd selenium.WebDriver, schema string) (string, error) {
+	raw, err := wd.ExecuteScript(extractSchemaJS, []interface{}{schema})
+	if err != nil {
+		return "", fmt.Errorf("could not extract schema: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(fmt.Sprint(raw)), "", "  "); err != nil {
+		return "", fmt.Errorf("could not format extracted data: %v", err)
+	}
+	return out.String(), nil
+}
+
+// Link is an anchor on the page, for --links
+type Link struct {
+	Text     string `json:"text"`
+	Href     string `json:"href"`
+	Rel      string `json:"rel,omitempty"`
+	Internal bool   `json:"internal"`
+}
+
+// collectLinks returns the page's anchors with absolute URLs, in document
+// order and deduplicated by URL. Fragments are dropped, so in-page anchors
+// collapse into the page itself, which is left out.
+func collectLinks(wd selenium.WebDriver) ([]Link, error) {
+	raw, err := wd.ExecuteScript(`
+		var page = location.href.split('#')[0];
+		var seen = {};
+		var links = [];
+		document.querySelectorAll('a[href], area[href]').forEach(function(a) {
+			var href = a.href.split('#')[0];
+			if (!/^(https?|mailto|tel|ftp):/.test(href) || href === page) {
+				return;
+			}
+			var img = a.querySelector('img[alt]');
+			var text = (a.innerText || a.getAttribute('aria-label') || a.title || (img ? img.alt : '') || '').replace(/\s+/g, ' ').trim();
+			if (seen[href] !== undefined) {
+				// Keep the first descriptive text, e.g. after an icon-only link
+				if (!links[seen[href]].text) { links[seen[href]].text = text; }
+				return;
+			}
+			seen[href] = links.length;
+			var internal = false;
+			try { internal = new URL(href).host === location.host; } catch (e) {}
+			links.push({ text: text, href: href, rel: a.getAttribute('rel') || '', internal: internal });
+		});
+		return JSON.stringify(links);
+	`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not collect links: %v", err)
+	}
+
+	var links []Link
+	if err := json.Unmarshal([]byte(fmt.Sprint(raw)), &links); err != nil {
+		return nil, fmt.Errorf("could not decode links: %v", err)
+	}
+	return links, nil
+}
+
+func formatLinks(links []Link) string {
+	if len(links) == 0 {
+		return "No links found"
+	}
+	var b strings.Builder
+	for _, link := range links {
+		text := link.Text
+		if text == "" {
+			text = link.Href
+		}
+		kind := "external"
+		if link.Internal {
+			kind = "internal"
+		}
+		if link.Rel != "" {
+			kind += ", rel=" + link.Rel
+		}
+		fmt.Fprintf(&b, "- [%s](%s) (%s)\n", text, link.Href, kind)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// PageMeta is the page's machine-readable metadata, for --meta
+type PageMeta struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	Canonical   string                 `json:"canonical,omitempty"`
+	Lang        string                 `json:"lang,omitempty"`
+	Dir         string                 `json:"dir,omitempty"`
+	Charset     string                 `json:"charset,omitempty"`
+	OpenGraph   map[string]interface{} `json:"opengraph,omitempty"`
+	Twitter     map[string]interface{} `json:"twitter,omitempty"`
+	JSONLD      []interface{}          `json:"json_ld,omitempty"`
+}
+
+// collectMeta reads the title, description, canonical link, language,
+// direction and encoding, OpenGraph (og:) and Twitter card (twitter:) tags
+// keyed without their prefix, and every JSON-LD block. Tags that repeat, like
+// og:image, become lists.
+func collectMeta(wd selenium.WebDriver) (PageMeta, error) {
+	raw, err := wd.ExecuteScript(`
+		function add(group, key, value) {
+			if (!(key in group)) {
+				group[key] = value;
+			} else if (Array.isArray(group[key])) {
+				group[key].push(value);
+			} else {
+				group[key] = [group[key], value];
+			}
+		}
+		var description = document.querySelector('meta[name="description" i]');
+		var canonical = document.querySelector('link[rel~="canonical" i]');
+		var meta = {
+			title: document.title,
+			description: description ? description.content : '',
+			canonical: canonical ? canonical.href : '',
+			opengraph: {},
+			twitter: {},
+			json_ld: []
+		};
+		document.querySelectorAll('meta[property], meta[name]').forEach(function(tag) {
+			var key = (tag.getAttribute('property') || tag.getAttribute('name') || '').toLowerCase();
+			if (key.indexOf('og:') === 0) {
+				add(meta.opengraph, key.slice(3), tag.content);
+			} else if (key.indexOf('twitter:') === 0) {
+				add(meta.twitter, key.slice(8), tag.content);
+			}
+		});
+		document.querySelectorAll('script[type="application/ld+json"]').forEach(function(script) {
+			meta.json_ld.push(script.textContent);
+		});
+		return meta;
+	`, nil)
+	if err != nil {
+		return PageMeta{}, fmt.Errorf("could not read metadata: %v", err)
+	}
+
+	entry, _ := raw.(map[string]interface{})
+	meta := PageMeta{}
+	meta.Title, _ = entry["title"].(string)
+	meta.Description, _ = entry["description"].(string)
+	meta.Canonical, _ = entry["canonical"].(string)
+	language := detectLanguage(wd)
+	meta.Lang, meta.Dir, meta.Charset = language.Lang, language.Dir, language.Charset
+	if group, ok := entry["opengraph"].(map[string]interface{}); ok && len(group) > 0 {
+		meta.OpenGraph = group
+	}
+	if group, ok := entry["twitter"].(map[string]interface{}); ok && len(group) > 0 {
+		meta.Twitter = group
+	}
+	blocks, _ := entry["json_ld"].([]interface{})
+	for i, block := range blocks {
+		var data interface{}
+		if err := json.Unmarshal([]byte(fmt.Sprint(block)), &data); err != nil {
+			logf("Warning: Skipping invalid JSON-LD block %d: %v\n", i+1, err)
+			continue
+		}
+		meta.JSONLD = append(meta.JSONLD, data)
+	}
+	return meta, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+
+	valid := `{"_items": ".product", "name": "h2", "image": "img@src", "tags": [".tag"], "seller": {"name": ".seller"}}`
+	os.WriteFile(path, []byte(valid), 0644)
+	schema, err := loadSchema(path)
+	if err != nil || schema != valid {
+		t.Errorf("loadSchema() = %q, %v", schema, err)
+	}
+
+	cases := []struct {
+		schema   string
+		expected string
+	}{
+		{`[".product"]`, "expected a JSON object"},
+		{`{"price": 42}`, "price must be a selector, a list with a single selector or a nested schema"},
+		{`{"tags": [".a", ".b"]}`, "tags must be a list with a single selector"},
+		{`{"seller": {"_items": {"name": "h2"}}}`, "seller._items must be a selector"},
+	}
+	for _, tc := range cases {
+		os.WriteFile(path, []byte(tc.schema), 0644)
+		_, err := loadSchema(path)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("loadSchema(%s) error = %v, expected %q", tc.schema, err, tc.expected)
+		}
+	}
+}
+
+func TestFormatLinks(t *testing.T) {
+	links := []Link{
+		{Text: "Guides", Href: "https://hexdocs.pm/phoenix/guides.html", Internal: true},
+		{Href: "https://github.com/phoenixframework/phoenix", Rel: "nofollow"},
+	}
+
+	expected := "- [Guides](https://hexdocs.pm/phoenix/guides.html) (internal)\n" +
+		"- [https://github.com/phoenixframework/phoenix](https://github.com/phoenixframework/phoenix) (external, rel=nofollow)"
+	if got := formatLinks(links); got != expected {
+		t.Errorf("formatLinks() =\n%s\nexpected\n%s", got, expected)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type flagKind int
+
+const (
+	flagBool     flagKind = iota // no value
+	flagString                   // any value
+	flagInt                      // positive integer
+	flagDuration                 // Go duration such as 500ms or 2m
+	flagURL                      // URL, http:// is added when no protocol is given
+	flagFile                     // path to an existing file
+	flagOutput                   // path to a file that will be written
+	flagOptional                 // value only given as --flag=value, so the bare flag is empty
+)
+
+// flagDef describes a command line flag. The parsed value is stored in target
+// (a *bool, *string, *int or *time.Duration matching kind) or, for flags that
+// need custom handling such as repeatable or paired flags, passed to apply.
+type flagDef struct {
+	name   string
+	kind   flagKind
+	target interface{}
+	apply  func(value string) error
+}
+
+// parseFlags parses args against defs, passing non-flag arguments to positional.
+// Values are validated according to their kind and unknown flags are rejected
+// with a suggestion for the closest known flag.
+func parseFlags(args []string, defs []flagDef, positional func(arg string) error) error {
+	byName := map[string]flagDef{}
+	for _, def := range defs {
+		byName[def.name] = def
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if !strings.HasPrefix(arg, "--") {
+			if err := positional(arg); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		def, ok := byName[name]
+		if !ok {
+			if suggestion := suggestFlag(name, defs); suggestion != "" {
+				return fmt.Errorf("unknown flag %s (did you mean %s?)", name, suggestion)
+			}
+			return fmt.Errorf("unknown flag %s", name)
+		}
+
+		if def.kind == flagBool {
+			if hasValue {
+				return fmt.Errorf("%s does not take a value", name)
+			}
+			value = "true"
+		} else if !hasValue && def.kind == flagOptional {
+			value = ""
+		} else if !hasValue {
+			// A following known flag means the value was forgotten, not that it is the value
+			if i+1 >= len(args) || isKnownFlag(args[i+1], byName) {
+				return fmt.Errorf("%s requires a value", name)
+			}
+			value = args[i+1]
+			i++
+		}
+
+		if err := setFlag(def, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setFlag(def flagDef, value string) error {
+	var parsed interface{}
+
+	switch def.kind {
+	case flagBool:
+		parsed = true
+	case flagInt:
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s expects a positive number, got %q", def.name, value)
+		}
+		parsed = n
+	case flagDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%s expects a duration such as 500ms, 10s or 2m, got %q", def.name, value)
+		}
+		parsed = d
+	case flagURL:
+		value = ensureProtocol(value)
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("%s expects a URL, got %q", def.name, value)
+		}
+		parsed = value
+	case flagFile:
+		info, err := os.Stat(value)
+		if err != nil {
+			return fmt.Errorf("%s: file not found: %s", def.name, value)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s expects a file but %s is a directory", def.name, value)
+		}
+		parsed = value
+	case flagOutput:
+		if dir := filepath.Dir(value); dir != "." {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("%s: directory does not exist: %s", def.name, dir)
+			}
+		}
+		parsed = value
+	default:
+		parsed = value
+	}
+
+	if def.apply != nil {
+		return def.apply(value)
+	}
+
+	switch target := def.target.(type) {
+	case *bool:
+		*target = parsed.(bool)
+	case *int:
+		*target = parsed.(int)
+	case *time.Duration:
+		*target = parsed.(time.Duration)
+	case *string:
+		*target = parsed.(string)
+	}
+	return nil
+}
+
+func isKnownFlag(arg string, byName map[string]flagDef) bool {
+	name, _, _ := strings.Cut(arg, "=")
+	_, ok := byName[name]
+	return ok
+}
+
+// suggestFlag returns the known flag closest to name, if any is close enough to be a typo
+func suggestFlag(name string, defs []flagDef) string {
+	best, bestDistance := "", 3
+	for _, def := range defs {
+		distance := levenshtein(name, def.name)
+		if distance < bestDistance || (strings.HasPrefix(def.name, name) && len(name) > 3 && best == "") {
+			best, bestDistance = def.name, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
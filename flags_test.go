@@ -0,0 +1,226 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	config, err := parseArgs([]string{
+		"--profile", "mysite",
+		"localhost:4000/login",
+		"--form", "login_form",
+		"--input", "email", "--value", "foo@bar.com",
+		"--input", "password", "--value", "--secret--",
+		"--truncate-after=500",
+		"--after-submit", "localhost:4000/dashboard",
+		"--drag", "#card", "--drop", "#done",
+		"--right-click", "#menu",
+		"--mouse-drag", "10,20 300,40",
+		"--raw",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	if config.URL != "localhost:4000/login" || config.Profile != "mysite" || len(config.Forms) != 1 || config.Forms[0].ID != "login_form" {
+		t.Errorf("Basic options parsed incorrectly: %+v", config)
+	}
+	if inputs := config.Forms[0].Inputs; len(inputs) != 2 || inputs[1].Name != "password" || inputs[1].Value != "--secret--" {
+		t.Errorf("Inputs parsed incorrectly: %+v", inputs)
+	}
+	if config.TruncateAfter != 500 {
+		t.Errorf("Expected --truncate-after=500 to be parsed, got %d", config.TruncateAfter)
+	}
+	if config.AfterSubmitURL != "http://localhost:4000/dashboard" {
+		t.Errorf("Expected protocol to be added to --after-submit, got %q", config.AfterSubmitURL)
+	}
+	expectedActions := []Action{
+		{Type: "drag", Selector: "#card", Target: "#done"},
+		{Type: "right-click", Selector: "#menu"},
+		{Type: "mouse-drag", From: Point{10, 20}, To: Point{300, 40}},
+	}
+	if !reflect.DeepEqual(config.Actions, expectedActions) {
+		t.Errorf("Actions parsed incorrectly: %+v", config.Actions)
+	}
+	if !config.RawFlag {
+		t.Errorf("Expected --raw to be set")
+	}
+}
+
+func TestParseArgsFormGroups(t *testing.T) {
+	config, err := parseArgs([]string{
+		"localhost:4000/signup",
+		"--input", "email", "--value", "foo@bar.com",
+		"--form", "account",
+		"--input", "password", "--value", "secret",
+		"--form", "profile",
+		"--clear", "nickname",
+		"--input", "name", "--value", "Foo",
+		"--input-label", "Date of birth", "--value", "2000-01-01",
+		"--form", "confirm",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+
+	expected := []Form{
+		{ID: "account", Inputs: []FormInput{{Name: "email", Value: "foo@bar.com"}, {Name: "password", Value: "secret"}}},
+		{ID: "profile", Inputs: []FormInput{{Name: "nickname", Clear: true}, {Name: "name", Value: "Foo"}, {Name: "Date of birth", Value: "2000-01-01", Label: true}}},
+		{ID: "confirm"},
+	}
+	if !reflect.DeepEqual(config.Forms, expected) {
+		t.Errorf("Form groups parsed incorrectly:\n%+v\nexpected\n%+v", config.Forms, expected)
+	}
+}
+
+func TestParseArgsDumpCookies(t *testing.T) {
+	// The path is only taken from --dump-cookies=<path>, so a URL after the bare flag stays the URL
+	config, err := parseArgs([]string{"--dump-cookies", "example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if !config.DumpCookies || config.CookiesPath != "" || config.URL != "example.com" {
+		t.Errorf("Bare --dump-cookies parsed incorrectly: %+v", config)
+	}
+
+	config, err = parseArgs([]string{"example.com", "--dump-cookies=cookies.json"})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if !config.DumpCookies || config.CookiesPath != "cookies.json" {
+		t.Errorf("--dump-cookies=<path> parsed incorrectly: %+v", config)
+	}
+
+	if _, err := parseArgs([]string{"example.com", "--dump-cookies=missing/cookies.json"}); err == nil || err.Error() != "--dump-cookies: directory does not exist: missing" {
+		t.Errorf("Expected missing directory error, got %v", err)
+	}
+}
+
+func TestParseArgsOutputFormat(t *testing.T) {
+	tests := []struct {
+		args     []string
+		format   string
+		raw      bool
+		jsonFlag bool
+	}{
+		{[]string{"example.com"}, "markdown", false, false},
+		{[]string{"example.com", "--output", "-"}, "markdown", false, false},
+		{[]string{"example.com", "--output", "page.JSON"}, "json", false, false},
+		{[]string{"example.com", "--output", "page.html"}, "html", true, false},
+		{[]string{"example.com", "--output", "page.json", "--format", "markdown"}, "markdown", false, false},
+		{[]string{"example.com", "--links", "--output", "links.json"}, "json", false, true},
+		{[]string{"example.com", "--elements", "--format", "json"}, "json", false, true},
+	}
+	for _, test := range tests {
+		config, err := parseArgs(test.args)
+		if err != nil {
+			t.Errorf("parseArgs(%v) returned error: %v", test.args, err)
+			continue
+		}
+		if config.Format != test.format || config.RawFlag != test.raw || config.JSONFlag != test.jsonFlag {
+			t.Errorf("parseArgs(%v) format = %q, raw = %v, json = %v; expected %q, %v, %v",
+				test.args, config.Format, config.RawFlag, config.JSONFlag, test.format, test.raw, test.jsonFlag)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"example.com", "--inpt", "email"}, "unknown flag --inpt (did you mean --input?)"},
+		{[]string{"example.com", "--truncate-after", "lots"}, "--truncate-after expects a positive number"},
+		{[]string{"example.com", "--truncate-after", "-5"}, "--truncate-after expects a positive number"},
+		{[]string{"example.com", "--form"}, "--form requires a value"},
+		{[]string{"example.com", "--form", "--raw"}, "--form requires a value"},
+		{[]string{"example.com", "--raw=yes"}, "--raw does not take a value"},
+		{[]string{"example.com", "--form", "f", "--input", "email"}, "--input email is missing a --value"},
+		{[]string{"example.com", "--form", "f", "--value", "x"}, "--value must follow an --input"},
+		{[]string{"example.com", "--form", "f", "--input-label", "Email address"}, "--input-label \"Email address\" is missing a --value"},
+		{[]string{"example.com", "--input-label", "Email", "--value", "x"}, "--input requires --form <id>"},
+		{[]string{"example.com", "--input", "email", "--value", "x"}, "--input requires --form <id>"},
+		{[]string{"example.com", "--drag", "#a"}, "--drag #a is missing a --drop"},
+		{[]string{"example.com", "--fill-mode", "prepend"}, "--fill-mode must be replace or append"},
+		{[]string{"example.com", "--clear", "notes"}, "--input requires --form <id>"},
+		{[]string{"example.com", "--keys", "Hyper+K"}, "--keys: unknown key \"Hyper\""},
+		{[]string{"example.com", "--mouse-click", "10"}, "--mouse-click: expected x,y coordinates, got \"10\""},
+		{[]string{"example.com", "--mouse-drag", "10,10"}, "--mouse-drag expects \"x1,y1 x2,y2\""},
+		{[]string{"example.com", "--json"}, "--json requires --links or --elements"},
+		{[]string{"example.com", "--chunk", "lots"}, "--chunk expects a positive number of characters or tokens"},
+		{[]string{"example.com", "--chunk-index", "2"}, "--chunk-index requires --chunk"},
+		{[]string{"example.com", "--tokenizer", "gpt2"}, "--tokenizer must be one of cl100k_base, o200k_base, p50k_base, r50k_base, estimate"},
+		{[]string{"example.com", "--grep", "a(b"}, "--grep: invalid regular expression"},
+		{[]string{"example.com", "--context", "2"}, "--context requires --grep"},
+		{[]string{"example.com", "--format", "yaml"}, "--format must be one of markdown, json, html, got \"yaml\""},
+		{[]string{"example.com", "--format", "json", "--raw"}, "--raw cannot be used with JSON output"},
+		{[]string{"example.com", "--console-level", "warning"}, `--console-level must be one of debug, log, info, warn, error, got "warning"`},
+		{[]string{"example.com", "--front-matter", "--format", "json"}, "--front-matter requires markdown output"},
+		{[]string{"example.com", "--clip", "0,0,100,100"}, "--screenshot-viewport, --clip, --screenshot-quality and --screenshot-scale require --screenshot"},
+		{[]string{"example.com", "--screenshot", "shot.png", "--screenshot-quality", "80"}, "--screenshot-quality only applies to .jpg and .webp screenshots"},
+		{[]string{"example.com", "--screenshot", "shot.jpg", "--screenshot-scale", "0"}, `--screenshot-scale expects a number above 0 and up to 4, e.g. 2, got "0"`},
+		{[]string{"example.com", "--meta", "--links"}, "--links cannot be combined with --meta"},
+		{[]string{"example.com", "--list-forms", "--links"}, "--links cannot be combined with --list-forms"},
+		{[]string{"example.com", "--dialog", "maybe"}, "--dialog must be accept or dismiss"},
+		{[]string{"example.com", "--poll-until-text", "Done", "--poll-interval", "100ms"}, "--poll-interval must be at least 1s"},
+		{[]string{"example.com", "--poll-timeout", "1m"}, "--poll-interval and --poll-timeout require --poll-until-text"},
+		{[]string{"example.com", "--wait-timeout", "5s"}, "--wait-timeout requires --wait-for, --wait-for-selector, --wait-for-text or --wait-for-url"},
+		{[]string{"example.com", "--wait-interval", "1s"}, "--wait-interval requires --wait-for"},
+		{[]string{"example.com", "--retry-backoff", "2s"}, "--retry-backoff requires --retries"},
+		{[]string{"example.com", "--if-changed"}, "--if-changed requires --http-only"},
+		{[]string{"example.com", "--cache", "--screenshot", "page.png"}, "--cache cannot stand in for a run with --screenshot"},
+		{[]string{"example.com", "--cache", "--manifest", "run.json"}, "--cache cannot stand in for a run with --manifest"},
+		{[]string{"example.com", "--cache=soon"}, "--cache expects a duration such as 10m or 2h"},
+		{[]string{"example.com", "--no-follow-redirects", "--max-redirects", "3"}, "--max-redirects cannot be used with --no-follow-redirects"},
+		{[]string{"example.com", "--wait-for-url", "/dash(/"}, "--wait-for-url: invalid regular expression"},
+		{[]string{"example.com", "--engine", "safari"}, `--engine must be one of firefox, chromium, webkit, got "safari"`},
+		{[]string{"example.com", "--browser-path", "/does/not/exist/firefox"}, "--browser-path: file not found"},
+		{[]string{"example.com", "--browser-build", "latest"}, "--browser-build expects a Playwright Firefox build number"},
+		{[]string{"example.com", "--browser-sha256", "abc123"}, "--browser-sha256 expects a SHA-256 checksum in hex"},
+		{[]string{"example.com", "--engine", "chromium", "--browser-build", "1490"}, "--browser-build, --browser-sha256 and --browser-archive only apply to the managed Firefox"},
+		{[]string{"example.com", "--hold"}, "--hold requires --headed"},
+		{[]string{"example.com", "--pause", "before submit"}, "--pause requires --headed or --devtools"},
+		{[]string{"example.com", "--slow-mo", "fast"}, "--slow-mo expects a positive number"},
+		{[]string{"example.com", "--max-memory", "2GB"}, "--max-memory expects a size such as 512M or 2G"},
+		{[]string{"example.com", "--max-memory", "0"}, "--max-memory expects a size such as 512M or 2G"},
+		{[]string{"example.com", "--max-cpu", "half"}, "--max-cpu expects a positive number"},
+		{[]string{"example.com", "--engine", "webkit", "--profile", "work"}, "--engine webkit does not keep profiles"},
+		{[]string{"example.com", "--engine", "webkit", "--no-js"}, "--no-js is not supported with --engine webkit"},
+		{[]string{"example.com", "--engine", "webkit", "--webgl"}, "--enable-gpu and --webgl are not supported with --engine webkit"},
+		{[]string{"example.com", "--http-only", "--screenshot", "page.png"}, "--screenshot needs a browser, which --http-only doesn't use"},
+		{[]string{"example.com", "--http-only", "--encrypt-profile"}, "--http-only keeps the profile's cookies in a file next to it"},
+		{[]string{"example.com", "--proxy", "ftp://proxy.corp:21"}, `--proxy must be one of http, https, socks5, socks5h, got "ftp"`},
+		{[]string{"example.com", "--ua-preset", "netscape"}, `--ua-preset must be one of googlebot, bingbot`},
+		{[]string{"example.com", "--engine", "webkit", "--ua-preset", "iphone"}, "--user-agent and --ua-preset are not supported with --engine webkit"},
+		{[]string{"example.com", "--wait-until", "idle"}, "--wait-until must be one of load, domcontentloaded, networkidle"},
+		{[]string{"example.com", "--dialog", "dismiss", "--dialog-text", "x"}, "--dialog-text cannot be used with --dialog dismiss"},
+		{[]string{"example.com", "--script", "does-not-exist.yaml"}, "--script: file not found"},
+		{[]string{"example.com", "--screenshot", "missing-dir/shot.png"}, "--screenshot: directory does not exist"},
+		{[]string{"example.com", "other.com"}, "unexpected argument \"other.com\""},
+	}
+
+	for _, tc := range cases {
+		_, err := parseArgs(tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("parseArgs(%q) error = %v, expected %q", tc.args, err, tc.expected)
+		}
+	}
+}
+
+func TestSuggestFlag(t *testing.T) {
+	defs := []flagDef{{name: "--screenshot"}, {name: "--script"}, {name: "--truncate-after"}}
+
+	cases := map[string]string{
+		"--screensht":  "--screenshot",
+		"--scirpt":     "--script",
+		"--truncate":   "--truncate-after",
+		"--completely": "",
+	}
+	for input, expected := range cases {
+		if got := suggestFlag(input, defs); got != expected {
+			t.Errorf("suggestFlag(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
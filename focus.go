@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// describeFocusJS describes document.activeElement as a short selector plus
+// the label a screen reader would most likely announce
+const describeFocusJS = `
+	var el = document.activeElement;
+	if (!el || el === document.body || el === document.documentElement) {
+		return { element: 'body', label: '' };
+	}
+
+	var element = el.tagName.toLowerCase();
+	if (el.id) {
+		element += '#' + el.id;
+	} else if (el.getAttribute('name')) {
+		element += '[name="' + el.getAttribute('name') + '"]';
+	} else if (el.classList.length) {
+		element += '.' + el.classList[0];
+	}
+	if (el.getAttribute('role')) {
+		element += ' (role=' + el.getAttribute('role') + ')';
+	}
+
+	var label = el.getAttribute('aria-label') ||
+		(el.labels && el.labels.length ? el.labels[0].innerText : '') ||
+		el.innerText || el.getAttribute('placeholder') || el.getAttribute('title') || el.value || '';
+	label = label.trim().replace(/\s+/g, ' ');
+	if (label.length > 60) {
+		label = label.slice(0, 57) + '...';
+	}
+	return { element: element, label: label };
+`
+
+// FocusStep is the element that had focus after a number of Tab presses
+type FocusStep struct {
+	Presses int
+	Element string
+	Label   string
+}
+
+// walkFocus focuses the element matching selector (when given), then presses
+// Tab presses times, recording the focused element before the first press and
+// after each one
+func walkFocus(wd selenium.WebDriver, config Config, selector string, presses int) ([]FocusStep, error) {
+	if selector != "" {
+		elem, err := waitForElement(wd, config, selector)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := wd.ExecuteScript("arguments[0].focus()", []interface{}{elem}); err != nil {
+			return nil, fmt.Errorf("could not focus %s: %v", selector, err)
+		}
+	}
+
+	var steps []FocusStep
+	for i := 0; i <= presses; i++ {
+		if i > 0 {
+			active, err := wd.ActiveElement()
+			if err != nil {
+				return steps, fmt.Errorf("could not find focused element: %v", err)
+			}
+			if err := active.SendKeys(selenium.TabKey); err != nil {
+				return steps, fmt.Errorf("could not press Tab: %v", err)
+			}
+		}
+
+		raw, err := wd.ExecuteScript(describeFocusJS, nil)
+		if err != nil {
+			return steps, fmt.Errorf("could not describe focused element: %v", err)
+		}
+		entry, _ := raw.(map[string]interface{})
+		element, _ := entry["element"].(string)
+		label, _ := entry["label"].(string)
+		steps = append(steps, FocusStep{Presses: i, Element: element, Label: label})
+	}
+
+	return steps, nil
+}
+
+func formatFocusSteps(steps []FocusStep) string {
+	var b strings.Builder
+	for _, step := range steps {
+		if step.Presses == 0 {
+			b.WriteString("start: ")
+		} else {
+			fmt.Fprintf(&b, "tab %d: ", step.Presses)
+		}
+		b.WriteString(step.Element)
+		if step.Label != "" {
+			fmt.Fprintf(&b, " %q", step.Label)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestFormatFocusSteps(t *testing.T) {
+	steps := []FocusStep{
+		{Presses: 0, Element: "input#email", Label: "Email"},
+		{Presses: 1, Element: "button.primary", Label: "Log in"},
+		{Presses: 2, Element: "body"},
+	}
+
+	expected := "start: input#email \"Email\"\ntab 1: button.primary \"Log in\"\ntab 2: body\n"
+	if got := formatFocusSteps(steps); got != expected {
+		t.Errorf("formatFocusSteps() = %q, expected %q", got, expected)
+	}
+}
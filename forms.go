@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// findFormField resolves an --input name within the form: first by name
+// attribute, then by id, across inputs, selects, textareas and contenteditable
+// editors
+func findFormField(wd selenium.WebDriver, config Config, formID, name string) (selenium.WebElement, error) {
+	selectors := []string{
+		fmt.Sprintf("#%s input[name='%s']", formID, name),
+		fmt.Sprintf("#%s select[name='%s']", formID, name),
+		fmt.Sprintf("#%s textarea[name='%s']", formID, name),
+		fmt.Sprintf("#%s [contenteditable][name='%s'], #%s [contenteditable][data-name='%s']", formID, name, formID, name),
+		fmt.Sprintf("#%s [id='%s']", formID, name),
+	}
+
+	var elem selenium.WebElement
+	err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		for _, selector := range selectors {
+			if found, err := findElement(wd, config, selector); err == nil {
+				elem = found
+				return true, nil
+			}
+		}
+		return false, nil
+	}, config.ActionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no input, select, textarea or contenteditable named %q in #%s", name, formID)
+	}
+	return elem, nil
+}
+
+// labeledControlsJS returns the controls in the form whose visible label
+// matches arguments[1], via <label for>/wrapping labels or aria-labelledby.
+// Labels are compared case-insensitively, ignoring whitespace and a trailing
+// colon or required-field asterisk.
+const labeledControlsJS = `
+	var form = document.getElementById(arguments[0]);
+	if (!form) { return []; }
+	var normalize = function(text) {
+		return (text || '').replace(/\s+/g, ' ').trim().replace(/\s*[:*]+$/, '').toLowerCase();
+	};
+	var wanted = normalize(arguments[1]);
+
+	var controls = [];
+	form.querySelectorAll('label').forEach(function(label) {
+		if (normalize(label.innerText) === wanted && label.control && controls.indexOf(label.control) === -1) {
+			controls.push(label.control);
+		}
+	});
+	form.querySelectorAll('[aria-labelledby]').forEach(function(el) {
+		var text = el.getAttribute('aria-labelledby').split(/\s+/).map(function(id) {
+			var labelEl = document.getElementById(id);
+			return labelEl ? labelEl.innerText : '';
+		}).join(' ');
+		if (normalize(text) === wanted && controls.indexOf(el) === -1) {
+			controls.push(el);
+		}
+	});
+	return controls;
+`
+
+// findLabeledField resolves an --input-label to the control its label
+// describes, waiting up to --action-timeout for the form to render
+func findLabeledField(wd selenium.WebDriver, config Config, formID, label string) (selenium.WebElement, error) {
+	var controls []selenium.WebElement
+	err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		raw, err := wd.ExecuteScriptRaw(labeledControlsJS, []interface{}{formID, label})
+		if err != nil {
+			return false, nil
+		}
+		controls, err = wd.DecodeElements(raw)
+		return err == nil && len(controls) > 0, nil
+	}, config.ActionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no control labeled %q in #%s", label, formID)
+	}
+	if len(controls) > 1 {
+		return nil, fmt.Errorf("%d controls are labeled %q in #%s, use --input with a name instead", len(controls), label, formID)
+	}
+	return controls[0], nil
+}
+
+// selectRadio checks the radio button with the given value when name refers to
+// a radio group in the form. It reports whether name was a radio group.
+func selectRadio(wd selenium.WebDriver, config Config, formID, name, value string) (bool, error) {
+	radios, err := findElements(wd, config, fmt.Sprintf("#%s input[type='radio'][name='%s']", formID, name))
+	if err != nil || len(radios) == 0 {
+		return false, nil
+	}
+
+	var values []string
+	for _, radio := range radios {
+		radioValue, _ := radio.GetAttribute("value")
+		if radioValue != value {
+			values = append(values, radioValue)
+			continue
+		}
+		if selected, _ := radio.IsSelected(); selected {
+			return true, nil
+		}
+		// Clicking rather than setting checked fires the change events the page listens for
+		if err := radio.Click(); err != nil {
+			return true, fmt.Errorf("could not select %s=%s: %v", name, value, err)
+		}
+		return true, nil
+	}
+
+	return true, fmt.Errorf("radio group %s has no option %q (options: %s)", name, value, strings.Join(values, ", "))
+}
+
+// selectOptionsJS selects the options of a <select> matching arguments[1] by
+// value or visible text, deselecting the rest, then fires input and change
+// once. It returns the values that matched no option along with the options.
+const selectOptionsJS = `
+	var select = arguments[0], wanted = arguments[1];
+	var options = Array.prototype.slice.call(select.options);
+	var matches = function(option, value) {
+		return option.value === value || option.text.trim() === value;
+	};
+	var missing = wanted.filter(function(value) {
+		return !options.some(function(option) { return matches(option, value); });
+	});
+	if (missing.length === 0) {
+		options.forEach(function(option) {
+			option.selected = wanted.some(function(value) { return matches(option, value); });
+		});
+		select.dispatchEvent(new Event('input', { bubbles: true }));
+		select.dispatchEvent(new Event('change', { bubbles: true }));
+	}
+	return {
+		missing: missing,
+		options: options.map(function(option) { return option.value; })
+	};
+`
+
+// selectOptions chooses the options named by value. For <select multiple>
+// value is a comma-separated list of option values or labels.
+func selectOptions(wd selenium.WebDriver, elem selenium.WebElement, value string) error {
+	multiple, _ := elem.GetAttribute("multiple")
+	wanted := []string{value}
+	if multiple != "" && multiple != "false" {
+		// An empty list (e.g. from --clear) deselects everything
+		wanted = []string{}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				wanted = append(wanted, part)
+			}
+		}
+	}
+
+	raw, err := wd.ExecuteScript(selectOptionsJS, []interface{}{elem, wanted})
+	if err != nil {
+		return err
+	}
+	result, _ := raw.(map[string]interface{})
+	missing, _ := result["missing"].([]interface{})
+	if len(missing) == 0 {
+		return nil
+	}
+	var options []string
+	list, _ := result["options"].([]interface{})
+	for _, option := range list {
+		options = append(options, fmt.Sprint(option))
+	}
+	return fmt.Errorf("no option %q (options: %s)", missing[0], strings.Join(options, ", "))
+}
+
+// fillFormField replaces the value of a form control with real keystrokes so
+// the page sees the same input events as for a user (which LiveView phx-change
+// and React controlled inputs rely on). Contenteditable editors are cleared
+// through a selection since WebDriver's clear only applies to form controls.
+// Selects get their matching options chosen instead. With appendValue the
+// existing text is kept and value is typed after it.
+func fillFormField(wd selenium.WebDriver, elem selenium.WebElement, value string, appendValue bool) error {
+	if tag, err := elem.TagName(); err == nil && strings.EqualFold(tag, "select") {
+		return selectOptions(wd, elem, value)
+	}
+
+	if appendValue {
+		// Put the caret after the existing text; email and number inputs
+		// don't support selection ranges but already type at the end
+		_, err := wd.ExecuteScript(`
+			var el = arguments[0];
+			el.focus();
+			if (el.isContentEditable && !('value' in el)) {
+				var range = document.createRange();
+				range.selectNodeContents(el);
+				range.collapse(false);
+				var selection = window.getSelection();
+				selection.removeAllRanges();
+				selection.addRange(range);
+			} else {
+				try { el.setSelectionRange(el.value.length, el.value.length); } catch (e) {}
+			}
+		`, []interface{}{elem})
+		if err != nil {
+			return err
+		}
+		return elem.SendKeys(value)
+	}
+
+	editable, err := wd.ExecuteScript("return arguments[0].isContentEditable && !('value' in arguments[0])", []interface{}{elem})
+	if err != nil {
+		return err
+	}
+
+	if editable == true {
+		_, err = wd.ExecuteScript(`
+			var el = arguments[0];
+			el.focus();
+			var range = document.createRange();
+			range.selectNodeContents(el);
+			var selection = window.getSelection();
+			selection.removeAllRanges();
+			selection.addRange(range);
+		`, []interface{}{elem})
+		if err != nil {
+			return err
+		}
+		// Typing over the selection replaces it; an empty value deletes it
+		if value == "" {
+			return elem.SendKeys(selenium.DeleteKey)
+		}
+		return elem.SendKeys(value)
+	}
+
+	if err := elem.Clear(); err != nil {
+		return err
+	}
+	return elem.SendKeys(value)
+}
+
+// FormInfo describes a form on the page, for --list-forms
+type FormInfo struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name,omitempty"`
+	Action  string       `json:"action"`
+	Method  string       `json:"method"`
+	Fields  []FormField  `json:"fields"`
+	Buttons []FormButton `json:"buttons"`
+}
+
+// FormField is a control that takes a value; radio groups are one field
+// with their values as options
+type FormField struct {
+	Name     string   `json:"name"`
+	ID       string   `json:"id,omitempty"`
+	Type     string   `json:"type"`
+	Label    string   `json:"label,omitempty"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// FormButton is a control that submits or resets the form
+type FormButton struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	Text  string `json:"text"`
+	Type  string `json:"type"`
+}
+
+const listFormsJS = `
+	var normalize = function(text) {
+		return (text || '').replace(/\s+/g, ' ').trim().replace(/\s*[:*]+$/, '');
+	};
+	var labelOf = function(el) {
+		if (el.labels && el.labels.length > 0) {
+			return normalize(el.labels[0].innerText);
+		}
+		if (el.getAttribute('aria-labelledby')) {
+			return normalize(el.getAttribute('aria-labelledby').split(/\s+/).map(function(id) {
+				var labelEl = document.getElementById(id);
+				return labelEl ? labelEl.innerText : '';
+			}).join(' '));
+		}
+		return normalize(el.getAttribute('aria-label'));
+	};
+	var buttonTypes = ['submit', 'reset', 'button', 'image'];
+
+	return JSON.stringify(Array.from(document.forms).map(function(form) {
+		var info = {
+			id: form.id,
+			name: form.getAttribute('name') || '',
+			action: form.action,
+			method: (form.method || 'get').toUpperCase(),
+			fields: [],
+			buttons: []
+		};
+		var radios = {};
+		Array.from(form.elements).forEach(function(el) {
+			var tag = el.tagName.toLowerCase();
+			var type = (el.type || tag).toLowerCase();
+			if (tag === 'fieldset' || tag === 'object' || tag === 'output') {
+				return;
+			}
+			if (tag === 'button' || (tag === 'input' && buttonTypes.indexOf(type) !== -1)) {
+				info.buttons.push({
+					name: el.name || '',
+					value: el.value || '',
+					text: normalize(tag === 'button' ? el.innerText : (el.value || el.alt)),
+					type: type
+				});
+				return;
+			}
+			if (type === 'radio') {
+				if (radios[el.name]) {
+					radios[el.name].options.push(el.value);
+					radios[el.name].required = radios[el.name].required || el.required;
+					return;
+				}
+				var group = el.closest('fieldset');
+				var legend = group && group.querySelector('legend');
+				radios[el.name] = {name: el.name, id: '', type: 'radio', label: legend ? normalize(legend.innerText) : '', required: el.required, options: [el.value]};
+				info.fields.push(radios[el.name]);
+				return;
+			}
+			var field = {name: el.name || '', id: el.id || '', type: type, label: labelOf(el), required: el.required, options: []};
+			if (tag === 'select') {
+				field.options = Array.from(el.options).map(function(option) { return option.value; });
+			}
+			info.fields.push(field);
+		});
+		form.querySelectorAll('[contenteditable=""], [contenteditable="true"]').forEach(function(el) {
+			info.fields.push({name: el.getAttribute('name') || '', id: el.id || '', type: 'contenteditable', label: labelOf(el), required: el.getAttribute('aria-required') === 'true', options: []});
+		});
+		return info;
+	}));
+`
+
+// listForms describes every form on the page: its fields with their names,
+// types, labels and whether they're required, and its buttons
+func listForms(wd selenium.WebDriver) ([]FormInfo, error) {
+	raw, err := wd.ExecuteScript(listFormsJS, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not list forms: %v", err)
+	}
+
+	forms := []FormInfo{}
+	if err := json.Unmarshal([]byte(fmt.Sprint(raw)), &forms); err != nil {
+		return nil, fmt.Errorf("could not decode forms: %v", err)
+	}
+	return forms, nil
+}
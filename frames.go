@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// frameSeparator separates nested frames in --frame, e.g. "checkout > card-number"
+const frameSeparator = ">"
+
+// switchToFrame enters the frame described by spec, starting from the top-level
+// document. Each level matches an iframe/frame by name or id, or by a src URL
+// pattern (a substring, or a glob when it contains *). Frames that are still
+// being inserted by the page are waited for.
+func switchToFrame(wd selenium.WebDriver, spec string, timeout time.Duration) error {
+	if err := wd.SwitchFrame(nil); err != nil {
+		return fmt.Errorf("could not switch to top-level document: %v", err)
+	}
+
+	for _, pattern := range strings.Split(spec, frameSeparator) {
+		pattern = strings.TrimSpace(pattern)
+
+		var frame selenium.WebElement
+		err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+			frame = findFrame(wd, pattern)
+			return frame != nil, nil
+		}, timeout)
+		if err != nil {
+			return fmt.Errorf("no frame matches %q", pattern)
+		}
+
+		if err := wd.SwitchFrame(frame); err != nil {
+			return fmt.Errorf("could not switch to frame %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// findFrame returns the first frame in the current document matching pattern
+func findFrame(wd selenium.WebDriver, pattern string) selenium.WebElement {
+	frames, err := wd.FindElements(selenium.ByCSSSelector, "iframe, frame")
+	if err != nil {
+		return nil
+	}
+
+	for _, frame := range frames {
+		name, _ := frame.GetAttribute("name")
+		id, _ := frame.GetAttribute("id")
+		src, _ := frame.GetAttribute("src")
+		if frameMatches(pattern, name, id, src) {
+			return frame
+		}
+	}
+	return nil
+}
+
+func frameMatches(pattern, name, id, src string) bool {
+	if pattern == name || pattern == id {
+		return true
+	}
+	if src == "" {
+		return false
+	}
+	if strings.Contains(pattern, "*") {
+		return globRegexp(pattern).MatchString(src)
+	}
+	return strings.Contains(src, pattern)
+}
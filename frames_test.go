@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestFrameMatches(t *testing.T) {
+	src := "https://js.stripe.com/v3/elements-inner-card.html#locale=en"
+	cases := []struct {
+		pattern  string
+		expected bool
+	}{
+		{"card-frame", true},
+		{"__privateStripeFrame1", true},
+		{"elements-inner-card", true},
+		{"https://js.stripe.com/*", true},
+		{"https://example.com/*", false},
+		{"comments", false},
+	}
+
+	for _, tc := range cases {
+		if got := frameMatches(tc.pattern, "__privateStripeFrame1", "card-frame", src); got != tc.expected {
+			t.Errorf("frameMatches(%q) = %v, expected %v", tc.pattern, got, tc.expected)
+		}
+	}
+}
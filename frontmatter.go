@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter describes a captured page in the YAML header --front-matter
+// writes instead of the banner, as static site generators and document
+// loaders expect
+type FrontMatter struct {
+	URL       string    `yaml:"url"`
+	FinalURL  string    `yaml:"final_url"`
+	Title     string    `yaml:"title"`
+	Lang      string    `yaml:"lang,omitempty"`
+	FetchedAt time.Time `yaml:"fetched_at"`
+	Status    int       `yaml:"status,omitempty"`
+	WordCount int       `yaml:"word_count"`
+	Tokens    int       `yaml:"tokens"`
+}
+
+func newFrontMatter(url string, response DocumentResponse, title, lang, markdown string, tokens int) FrontMatter {
+	finalURL := response.URL
+	if finalURL == "" {
+		finalURL = url
+	}
+	return FrontMatter{
+		URL:       url,
+		FinalURL:  finalURL,
+		Title:     title,
+		Lang:      lang,
+		FetchedAt: time.Now().UTC().Truncate(time.Second),
+		Status:    response.Status,
+		WordCount: countWords(markdown),
+		Tokens:    tokens,
+	}
+}
+
+// countWords counts the words of markdown, leaving out syntax such as # and -
+func countWords(markdown string) int {
+	words := 0
+	for _, field := range strings.Fields(markdown) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// render is the front matter between --- lines, followed by a blank line
+func (fm FrontMatter) render() (string, error) {
+	data, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", fmt.Errorf("could not encode front matter: %v", err)
+	}
+	return "---\n" + string(data) + "---\n\n", nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrontMatter(t *testing.T) {
+	fm := newFrontMatter("http://example.com", DocumentResponse{URL: "https://example.com/", Status: 200}, "Example: \"Domain\"", "en", "# Example Domain\n\nThis domain is for use in examples.", 12)
+	if fm.WordCount != 9 {
+		t.Errorf("WordCount = %d, expected 9", fm.WordCount)
+	}
+
+	rendered, err := fm.render()
+	if err != nil {
+		t.Fatalf("render returned error: %v", err)
+	}
+	expected := "---\nurl: http://example.com\nfinal_url: https://example.com/\ntitle: 'Example: \"Domain\"'\nlang: en\nfetched_at: " + fm.FetchedAt.Format(time.RFC3339) + "\nstatus: 200\nword_count: 9\ntokens: 12\n---\n\n"
+	if rendered != expected {
+		t.Errorf("render =\n%s\nexpected\n%s", rendered, expected)
+	}
+
+	// Without a response, the requested URL is where the page was fetched from
+	fm = newFrontMatter("file:///tmp/page.html", DocumentResponse{}, "", "", "", 0)
+	if rendered, _ := fm.render(); !strings.Contains(rendered, "final_url: file:///tmp/page.html\n") || strings.Contains(rendered, "status:") {
+		t.Errorf("Unexpected front matter without a response:\n%s", rendered)
+	}
+}
@@ -3,15 +3,15 @@ module web
 go 1.24
 
 require (
-	github.com/jaytaylor/html2text v0.0.0-20230321000545-74c2419ad056
+	github.com/pkoukk/tiktoken-go v0.1.8
 	github.com/tebeka/selenium v0.9.9
+	golang.org/x/net v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/blang/semver v3.5.1+incompatible // indirect
+	github.com/dlclark/regexp2 v1.10.0 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
-	github.com/mattn/go-runewidth v0.0.9 // indirect
-	github.com/olekukonko/tablewriter v0.0.5 // indirect
-	github.com/ssor/bom v0.0.0-20170718123548-6386211fdfcf // indirect
-	golang.org/x/net v0.10.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
 )
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// GraphQLOperation is a GraphQL request the page made and what it got back,
+// for --capture-graphql
+type GraphQLOperation struct {
+	// Type is query, mutation or subscription
+	Type      string          `json:"type"`
+	Name      string          `json:"name,omitempty"`
+	URL       string          `json:"url"`
+	Status    int             `json:"status,omitempty"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Errors    []string        `json:"errors,omitempty"`
+	// Error is why the request itself failed
+	Error string `json:"error,omitempty"`
+}
+
+// graphQLRequest is a GraphQL request body, or one of a batch
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+// graphQLResponse is a GraphQL response body, or one of a batch
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+// operationPattern finds the operation in a query document, by name when
+// the request gives one
+var operationPattern = regexp.MustCompile(`\b(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+// graphQLRequests returns the GraphQL operations of a request: a POST of a
+// JSON body with a query document or operationName, one batched in a JSON
+// array, or a GET with them in the query string, as persisted queries use.
+// A search API's {"query": "shoes"} has no document, so it isn't one.
+func graphQLRequests(exchange *Exchange) []graphQLRequest {
+	var requests []graphQLRequest
+	switch exchange.Method {
+	case http.MethodPost:
+		body := strings.TrimSpace(string(exchange.RequestBody))
+		if strings.HasPrefix(body, "[") {
+			json.Unmarshal([]byte(body), &requests)
+		} else {
+			var request graphQLRequest
+			if json.Unmarshal([]byte(body), &request) == nil {
+				requests = append(requests, request)
+			}
+		}
+	case http.MethodGet:
+		u, err := url.Parse(exchange.URL)
+		if err != nil {
+			return nil
+		}
+		query := u.Query()
+		requests = append(requests, graphQLRequest{
+			Query:         query.Get("query"),
+			OperationName: query.Get("operationName"),
+			Variables:     json.RawMessage(query.Get("variables")),
+		})
+	}
+	for _, request := range requests {
+		if !strings.Contains(request.Query, "{") && request.OperationName == "" {
+			return nil
+		}
+	}
+	return requests
+}
+
+// operationType reads the type and name of the request's operation from its
+// query document. Shorthand { ... } documents and persisted queries without
+// one are queries.
+func operationType(request graphQLRequest) (string, string) {
+	for _, match := range operationPattern.FindAllStringSubmatch(request.Query, -1) {
+		if request.OperationName == "" || match[2] == request.OperationName {
+			name := match[2]
+			if request.OperationName != "" {
+				name = request.OperationName
+			}
+			return match[1], name
+		}
+	}
+	return "query", request.OperationName
+}
+
+// capturedGraphQL picks the GraphQL operations out of the recorded traffic,
+// with the data and errors of their responses
+func capturedGraphQL(exchanges []*Exchange) []GraphQLOperation {
+	var operations []GraphQLOperation
+	for _, exchange := range exchanges {
+		requests := graphQLRequests(exchange)
+		if len(requests) == 0 {
+			continue
+		}
+
+		var responses []graphQLResponse
+		var responseErr string
+		if exchange.Err != nil {
+			responseErr = exchange.Err.Error()
+		} else if body, err := decodeBody(exchange.ResponseHeader.Get("Content-Encoding"), exchange.ResponseBody); err != nil {
+			responseErr = err.Error()
+		} else if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+			json.Unmarshal(body, &responses)
+		} else {
+			var response graphQLResponse
+			if json.Unmarshal(body, &response) == nil {
+				responses = append(responses, response)
+			}
+		}
+
+		for i, request := range requests {
+			kind, name := operationType(request)
+			operation := GraphQLOperation{
+				Type:   kind,
+				Name:   name,
+				URL:    exchange.URL,
+				Status: exchange.StatusCode,
+				Error:  responseErr,
+			}
+			if variables := compactJSON(request.Variables); variables != nil && string(variables) != "null" && string(variables) != "{}" {
+				operation.Variables = variables
+			}
+			if i < len(responses) {
+				if data := compactJSON(responses[i].Data); string(data) != "null" {
+					operation.Data = data
+				}
+				for _, graphQLErr := range responses[i].Errors {
+					message := graphQLErr.Message
+					if len(graphQLErr.Path) > 0 {
+						path := make([]string, len(graphQLErr.Path))
+						for j, segment := range graphQLErr.Path {
+							path[j] = fmt.Sprint(segment)
+						}
+						message += " (at " + strings.Join(path, ".") + ")"
+					}
+					operation.Errors = append(operation.Errors, message)
+				}
+			}
+			operations = append(operations, operation)
+		}
+	}
+	return operations
+}
+
+// compactJSON returns raw JSON without insignificant whitespace, or nil for
+// something that isn't JSON
+func compactJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var value interface{}
+	if json.Unmarshal(raw, &value) != nil {
+		return nil
+	}
+	compact, _ := json.Marshal(value)
+	return compact
+}
+
+func formatGraphQL(operations []GraphQLOperation) string {
+	if len(operations) == 0 {
+		return "No GraphQL operations\n"
+	}
+	var b strings.Builder
+	for i, operation := range operations {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := operation.Name
+		if name == "" {
+			name = "(anonymous)"
+		}
+		outcome := operation.Error
+		if outcome == "" {
+			outcome = fmt.Sprintf("%d %s", operation.Status, http.StatusText(operation.Status))
+		}
+		fmt.Fprintf(&b, "%s %s (%s, %s)\n", operation.Type, name, operation.URL, outcome)
+		if operation.Variables != nil {
+			fmt.Fprintf(&b, "Variables: %s\n", operation.Variables)
+		}
+		for _, message := range operation.Errors {
+			fmt.Fprintf(&b, "Error: %s\n", message)
+		}
+		if operation.Data != nil {
+			data, _, truncated := apiBody("application/json", operation.Data)
+			fmt.Fprintf(&b, "Data:\n%s\n", data)
+			if truncated {
+				fmt.Fprintf(&b, "... (truncated at %s)\n", formatBytes(apiBodyLimit))
+			}
+		}
+	}
+	return b.String()
+}
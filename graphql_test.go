@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCapturedGraphQL(t *testing.T) {
+	exchanges := []*Exchange{
+		{
+			Method: "POST", URL: "https://example.com/graphql", StatusCode: 200,
+			RequestHeader:  http.Header{"Content-Type": {"application/json"}},
+			RequestBody:    []byte(`{"query":"fragment U on User { id } query GetUser($id: ID!) { user(id: $id) { ...U } }","operationName":"GetUser","variables":{"id": "1"}}`),
+			ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+			ResponseBody:   []byte(`{"data": {"user": {"id": "1"}}}`),
+		},
+		{
+			Method: "POST", URL: "https://example.com/graphql", StatusCode: 200,
+			RequestBody:    []byte(`[{"query":"mutation { like(post: 7) }"}, {"query":"{ viewer { name } }"}]`),
+			ResponseHeader: http.Header{},
+			ResponseBody:   []byte(`[{"data": null, "errors": [{"message": "Not signed in", "path": ["like"]}]}, {"data": {"viewer": null}}]`),
+		},
+		{
+			// A persisted query sends only its name and hash
+			Method: "GET", URL: `https://example.com/graphql?operationName=Feed&variables={"first":10}&extensions={}`, StatusCode: 200,
+			ResponseHeader: http.Header{},
+			ResponseBody:   []byte(`{"data": {"feed": []}}`),
+		},
+		{
+			// Not GraphQL: a search API
+			Method: "POST", URL: "https://example.com/search", StatusCode: 200,
+			RequestBody:    []byte(`{"query":"shoes"}`),
+			ResponseHeader: http.Header{},
+			ResponseBody:   []byte(`{"results": []}`),
+		},
+	}
+
+	operations := capturedGraphQL(exchanges)
+	if len(operations) != 4 {
+		t.Fatalf("Expected 4 operations, got %+v", operations)
+	}
+	expected := []struct{ kind, name, variables, data string }{
+		{"query", "GetUser", `{"id":"1"}`, `{"user":{"id":"1"}}`},
+		{"mutation", "", "", ""},
+		{"query", "", "", `{"viewer":null}`},
+		{"query", "Feed", `{"first":10}`, `{"feed":[]}`},
+	}
+	for i, want := range expected {
+		got := operations[i]
+		if got.Type != want.kind || got.Name != want.name || string(got.Variables) != want.variables || string(got.Data) != want.data {
+			t.Errorf("Operation %d = %s %q variables %s data %s, expected %+v", i, got.Type, got.Name, got.Variables, got.Data, want)
+		}
+	}
+	if errors := operations[1].Errors; len(errors) != 1 || errors[0] != "Not signed in (at like)" {
+		t.Errorf("Unexpected errors %q", errors)
+	}
+
+	output := formatGraphQL(operations)
+	for _, want := range []string{"query GetUser (https://example.com/graphql, 200 OK)", "Variables: {\"id\":\"1\"}", "mutation (anonymous)", "Error: Not signed in (at like)", "Data:\n{\n  \"user\": {"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grepMarkdown returns the lines of markdown matching pattern, grep style:
+// matches as "N: line" and the context lines around them as "N- line", with
+// "--" between separate groups. Each group is preceded by the nearest heading
+// above it, so a match can be placed in the document.
+func grepMarkdown(markdown string, pattern *regexp.Regexp, context int) string {
+	lines := strings.Split(markdown, "\n")
+
+	// Mark the matches and the context lines to keep
+	keep := make([]bool, len(lines))
+	matched := make([]bool, len(lines))
+	for i, line := range lines {
+		if !pattern.MatchString(line) {
+			continue
+		}
+		matched[i] = true
+		for j := max(0, i-context); j <= min(len(lines)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+
+	var groups []string
+	var group strings.Builder
+	heading := -1
+	fence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fence = !fence
+		} else if !fence && strings.HasPrefix(line, "#") {
+			heading = i
+		}
+
+		if !keep[i] {
+			if group.Len() > 0 {
+				groups = append(groups, strings.TrimSuffix(group.String(), "\n"))
+				group.Reset()
+			}
+			continue
+		}
+		if group.Len() == 0 && heading >= 0 && heading < i && !keep[heading] {
+			fmt.Fprintf(&group, "%d= %s\n", heading+1, lines[heading])
+		}
+		separator := "-"
+		if matched[i] {
+			separator = ":"
+		}
+		group.WriteString(strings.TrimRight(fmt.Sprintf("%d%s %s", i+1, separator, line), " ") + "\n")
+	}
+	if group.Len() > 0 {
+		groups = append(groups, strings.TrimSuffix(group.String(), "\n"))
+	}
+
+	if len(groups) == 0 {
+		return fmt.Sprintf("No matches for /%s/", pattern)
+	}
+	return strings.Join(groups, "\n--\n")
+}
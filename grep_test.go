@@ -0,0 +1,46 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGrepMarkdown(t *testing.T) {
+	markdown := `# Guide
+
+## Install
+
+Run the installer.
+Then restart.
+
+## Usage
+
+Call web with a URL.
+Restart is never needed.
+Done.`
+
+	output := grepMarkdown(markdown, regexp.MustCompile(`(?i)restart`), 0)
+	expected := `3= ## Install
+6: Then restart.
+--
+8= ## Usage
+11: Restart is never needed.`
+	if output != expected {
+		t.Errorf("grepMarkdown() =\n%s\nexpected\n%s", output, expected)
+	}
+
+	// Overlapping context merges into one group that already shows its heading
+	output = grepMarkdown(markdown, regexp.MustCompile(`URL|Done`), 2)
+	expected = `8- ## Usage
+9-
+10: Call web with a URL.
+11- Restart is never needed.
+12: Done.`
+	if output != expected {
+		t.Errorf("grepMarkdown() with context =\n%s\nexpected\n%s", output, expected)
+	}
+
+	if output := grepMarkdown(markdown, regexp.MustCompile(`missing`), 1); output != "No matches for /missing/" {
+		t.Errorf("grepMarkdown() without matches = %q", output)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR 1.2 types, as browser devtools and HAR viewers read them. Sizes and
+// timings the proxy doesn't see are -1, as the format has it.
+type harLog struct {
+	Log harLogBody `json:"log"`
+}
+
+type harLogBody struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Pages   []harPage  `json:"pages"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     harPageTimings `json:"pageTimings"`
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	PageRef         string      `json:"pageref"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// writeHAR saves the exchanges as a HAR file with a single page, titled
+// title. With omitContent the request and response bodies are left out,
+// which keeps the file small and free of the data they carry.
+func writeHAR(path, title string, exchanges []*Exchange, omitContent bool) error {
+	started := time.Now()
+	if len(exchanges) > 0 {
+		started = exchanges[0].Started
+	}
+	har := harLog{Log: harLogBody{
+		Version: "1.2",
+		Creator: harCreator{Name: "web", Version: "1.0"},
+		Pages: []harPage{{
+			StartedDateTime: started.Format(time.RFC3339Nano),
+			ID:              "page_1",
+			Title:           title,
+			PageTimings:     harPageTimings{OnContentLoad: -1, OnLoad: -1},
+		}},
+		Entries: []harEntry{},
+	}}
+	for _, exchange := range exchanges {
+		har.Log.Entries = append(har.Log.Entries, harEntryFor(exchange, omitContent))
+	}
+
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode HAR: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+// harEntryFor converts an exchange, with failed requests as entries
+// without a response status, their error as a comment
+func harEntryFor(exchange *Exchange, omitContent bool) harEntry {
+	milliseconds := float64(exchange.Duration) / float64(time.Millisecond)
+	entry := harEntry{
+		PageRef:         "page_1",
+		StartedDateTime: exchange.Started.Format(time.RFC3339Nano),
+		Time:            milliseconds,
+		Request: harRequest{
+			Method:      exchange.Method,
+			URL:         exchange.URL,
+			HTTPVersion: exchange.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(exchange.RequestHeader),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(exchange.RequestBody),
+		},
+		Response: harResponse{
+			HTTPVersion: exchange.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(exchange.ResponseHeader),
+			HeadersSize: -1,
+			BodySize:    len(exchange.ResponseBody),
+		},
+		Timings: harTimings{Send: 0, Wait: milliseconds, Receive: 0},
+	}
+	if host, _, err := net.SplitHostPort(exchange.RemoteAddr); err == nil {
+		entry.ServerIPAddress = host
+	}
+	if u, err := url.Parse(exchange.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{name, value})
+			}
+		}
+		sort.Slice(entry.Request.QueryString, func(i, j int) bool {
+			return entry.Request.QueryString[i].Name < entry.Request.QueryString[j].Name
+		})
+	}
+	for _, cookie := range (&http.Request{Header: exchange.RequestHeader}).Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, harNameValue{cookie.Name, cookie.Value})
+	}
+	if len(exchange.RequestBody) > 0 && !omitContent {
+		entry.Request.PostData = &harPostData{MimeType: exchange.RequestHeader.Get("Content-Type"), Text: string(exchange.RequestBody)}
+	}
+
+	if exchange.Err != nil {
+		entry.Comment = exchange.Err.Error()
+		entry.Response.BodySize = -1
+		return entry
+	}
+	entry.Response.Status = exchange.StatusCode
+	entry.Response.StatusText = http.StatusText(exchange.StatusCode)
+	entry.Response.RedirectURL = exchange.ResponseHeader.Get("Location")
+	for _, cookie := range (&http.Response{Header: exchange.ResponseHeader}).Cookies() {
+		entry.Response.Cookies = append(entry.Response.Cookies, harNameValue{cookie.Name, cookie.Value})
+	}
+
+	content := harContent{MimeType: exchange.ResponseHeader.Get("Content-Type"), Size: len(exchange.ResponseBody)}
+	// Streamed responses are relayed without being kept
+	if exchange.ResponseBody == nil {
+		content.Comment = "streamed, not recorded"
+	} else if body, err := decodeBody(exchange.ResponseHeader.Get("Content-Encoding"), exchange.ResponseBody); err != nil {
+		content.Comment = err.Error()
+	} else {
+		content.Size = len(body)
+		if !omitContent && len(body) > 0 {
+			mediaType, _, _ := mime.ParseMediaType(content.MimeType)
+			if utf8.Valid(body) && mediaType != "application/octet-stream" {
+				content.Text = string(body)
+			} else {
+				content.Text, content.Encoding = base64.StdEncoding.EncodeToString(body), "base64"
+			}
+		}
+	}
+	entry.Response.Content = content
+	return entry
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, harNameValue{name, value})
+		}
+	}
+	return headers
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteHAR(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	exchanges := []*Exchange{
+		{
+			Method: "POST", URL: "https://example.com/api/search?q=go&page=2", Proto: "HTTP/1.1",
+			RequestHeader: http.Header{"Content-Type": {"application/json"}, "Cookie": {"session=abc"}},
+			RequestBody:   []byte(`{"q":"go"}`),
+			Status:        "200 OK", StatusCode: 200,
+			ResponseHeader: http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"seen=1; Path=/"}},
+			ResponseBody:   []byte(`{"results":[]}`),
+			RemoteAddr:     "93.184.216.34:443",
+			Started:        started,
+			Duration:       250 * time.Millisecond,
+		},
+		{
+			Method: "GET", URL: "https://down.example.com/", Proto: "HTTP/1.1",
+			RequestHeader: http.Header{},
+			Started:       started.Add(time.Second),
+			Duration:      time.Millisecond,
+			Err:           errors.New("connection refused"),
+		},
+	}
+
+	for _, omit := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "run.har")
+		if err := writeHAR(path, "Search", exchanges, omit); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		var har harLog
+		if err := json.Unmarshal(data, &har); err != nil {
+			t.Fatalf("HAR is not valid JSON: %v", err)
+		}
+		if har.Log.Version != "1.2" || len(har.Log.Pages) != 1 || har.Log.Pages[0].Title != "Search" || len(har.Log.Entries) != 2 {
+			t.Fatalf("Unexpected HAR log: %+v", har.Log)
+		}
+
+		entry := har.Log.Entries[0]
+		if entry.Time != 250 || entry.ServerIPAddress != "93.184.216.34" || entry.StartedDateTime != "2024-05-01T12:00:00Z" {
+			t.Errorf("Unexpected entry timing: %+v", entry)
+		}
+		if q := entry.Request.QueryString; len(q) != 2 || q[0] != (harNameValue{"page", "2"}) || q[1] != (harNameValue{"q", "go"}) {
+			t.Errorf("Unexpected query string %+v", q)
+		}
+		if entry.Request.Cookies[0] != (harNameValue{"session", "abc"}) || entry.Response.Cookies[0] != (harNameValue{"seen", "1"}) {
+			t.Errorf("Unexpected cookies %+v, %+v", entry.Request.Cookies, entry.Response.Cookies)
+		}
+		if entry.Response.Status != 200 || entry.Response.Content.Size != 14 {
+			t.Errorf("Unexpected response %+v", entry.Response)
+		}
+		if omit {
+			if entry.Request.PostData != nil || entry.Response.Content.Text != "" {
+				t.Errorf("Expected bodies to be left out, got %+v and %q", entry.Request.PostData, entry.Response.Content.Text)
+			}
+		} else if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"q":"go"}` || entry.Response.Content.Text != `{"results":[]}` {
+			t.Errorf("Expected bodies in the HAR, got %+v and %q", entry.Request.PostData, entry.Response.Content.Text)
+		}
+
+		if failed := har.Log.Entries[1]; failed.Response.Status != 0 || failed.Comment != "connection refused" {
+			t.Errorf("Unexpected failed entry %+v", failed)
+		}
+	}
+}
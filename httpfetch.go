@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// httpUserAgent identifies --http-only requests like the managed Firefox, as
+// some sites turn away clients they don't recognize
+const httpUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+// browserOnlyFlags are the flags --http-only can't honor, as they need the
+// page rendered, its scripts run or the browser driven
+func browserOnlyFlags(config Config) []string {
+	var flags []string
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"--form", len(config.Forms) > 0},
+		{"--click, --keys and the other actions", len(config.Actions) > 0},
+		{"--after-submit", config.AfterSubmitURL != ""},
+		{"--js", config.JSCode != ""},
+		{"--script", config.ScriptPath != ""},
+		{"--session", config.Session != ""},
+		{"--save-state", config.SaveState != ""},
+		{"--screenshot", config.ScreenshotPath != ""},
+		{"--save-page", config.SavePage != ""},
+		{"--archive-dir", config.ArchiveDir != ""},
+		{"--warc", config.WARCPath != ""},
+		{"--har", config.HARPath != ""},
+		{"--mock and --mock-file", len(config.Mocks) > 0},
+		{"--record-session", config.RecordSession != ""},
+		{"--replay-session", config.Replay != nil},
+		{"--changed-regions", config.ChangedRegions},
+		{"--diff-dom", config.DiffDOM},
+		{"--dialog", config.DialogMode != ""},
+		{"--csrf", config.CSRF},
+		{"--headed", config.Headed},
+		{"--enable-gpu", config.EnableGPU},
+		{"--webgl", config.WebGL},
+		{"--follow-popup", config.FollowPopup},
+		{"--frame", config.Frame != ""},
+		{"--deep", config.Deep},
+		{"--wait-for-selector", config.WaitSelector != ""},
+		{"--wait-for-text", config.WaitText != ""},
+		{"--wait-for-url", config.WaitURL != ""},
+		{"--wait-for", config.WaitFunction != ""},
+		{"--wait-until", config.WaitUntil != ""},
+		{"--poll-until-text", config.PollText != ""},
+		{"--focus", config.FocusSelector != ""},
+		{"--tab-walk", config.TabWalk > 0},
+		{"--cache-stats", config.CacheStats},
+		{"--extract", len(config.Extract) > 0},
+		{"--extract-schema", config.SchemaPath != ""},
+		{"--links", config.Links},
+		{"--meta", config.Meta},
+		{"--list-forms", config.ListForms},
+		{"--elements", config.Elements},
+		{"--strip-boilerplate", config.StripBoilerplate},
+		{"--remove", len(config.Remove) > 0},
+		{"--assert-text, --assert-selector and --assert-title", len(config.Assertions) > 0},
+		{"--network-failures", config.NetworkFailures},
+		{"--capture-api", config.CaptureAPI},
+		{"--capture-graphql", config.CaptureGraphQL},
+		{"--fail-on-page-error", config.FailOnPageError},
+		{"--console-level", config.ConsoleLevel != ""},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+	return flags
+}
+
+// fetchHTTP implements --http-only: the page is fetched with net/http, with
+// the cookies of the profile's cookie jar, and its HTML goes through the same
+// conversion as a rendered page. Content that scripts add never shows up.
+func fetchHTTP(config Config) (string, error) {
+	baseURL := ensureProtocol(config.URL)
+	if len(config.StorageOrigins) > 0 {
+		logf("Warning: --http-only runs no scripts, so the localStorage of --load-state is left out\n")
+	}
+
+	var content string
+	var response DocumentResponse
+	var cookies []Cookie
+	var redirects []RedirectHop
+	var validatorsFile string
+	var validators Validators
+	var notModified bool
+	if config.IfChanged {
+		var err error
+		if validatorsFile, err = validatorsPath(config.Profile, baseURL); err != nil {
+			return "", err
+		}
+		validators, _ = readValidators(validatorsFile)
+	}
+	fetch := func(jar *cookieJar) error {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		if config.ProxyURL != nil {
+			upstream := &upstreamProxy{url: config.ProxyURL, bypass: config.ProxyBypass}
+			transport.Proxy = upstream.proxyFor
+		}
+		if len(config.Resolves) > 0 {
+			transport.DialContext = resolvingDialer(config.Resolves)
+		}
+		client := &http.Client{Jar: jar, Timeout: config.NavTimeout, Transport: transport}
+		if len(config.Rewrites) > 0 {
+			client.Transport = rewriteTransport{rules: config.Rewrites, next: transport}
+		}
+		client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+			// via holds the request and the redirects followed so far
+			if config.NoFollowRedirects || config.MaxRedirects > 0 && len(via) > config.MaxRedirects {
+				return http.ErrUseLastResponse
+			}
+			// net/http's own limit
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			// The validators are for the page asked for, not where it moved
+			next.Header.Del("If-None-Match")
+			next.Header.Del("If-Modified-Since")
+			redirect := next.Response
+			redirects = append(redirects, RedirectHop{URL: redirect.Request.URL.String(), Status: redirect.StatusCode, Location: next.URL.String(), Followed: true})
+			return nil
+		}
+		request, err := http.NewRequest("GET", baseURL, nil)
+		if err != nil {
+			return fmt.Errorf("bad URL %q: %v", baseURL, err)
+		}
+		// --cookie cookies without a Domain are for the page's host
+		for _, cookie := range config.Cookies {
+			if cookie.Domain == "" {
+				cookie.Domain = request.URL.Hostname()
+			}
+			jar.add(cookie)
+		}
+		userAgent := httpUserAgent
+		if config.UserAgent != "" {
+			userAgent = config.UserAgent
+		}
+		request.Header.Set("User-Agent", userAgent)
+		// net/http drops it on redirects to other sites
+		if config.Bearer != "" {
+			request.Header.Set("Authorization", "Bearer "+config.Bearer)
+		}
+		request.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+		validators.setConditional(request.Header)
+		// A 502, 503 or 504 left after the last retry is kept as the page
+		var reply *http.Response
+		var body []byte
+		var badStatus bool
+		err = retry(config, "Fetching "+baseURL, func() (bool, error) {
+			redirects, badStatus = nil, false
+			var err error
+			if reply, err = client.Do(request); err != nil {
+				return transientError(err), fmt.Errorf("could not fetch %s: %v", baseURL, err)
+			}
+			defer reply.Body.Close()
+			if body, err = io.ReadAll(reply.Body); err != nil {
+				return transientError(err), fmt.Errorf("could not read %s: %v", baseURL, err)
+			}
+			if config.Retries > 0 && transientStatus(reply.StatusCode) {
+				badStatus = true
+				return true, fmt.Errorf("HTTP %d %s", reply.StatusCode, http.StatusText(reply.StatusCode))
+			}
+			return false, nil
+		})
+		if err != nil && !badStatus {
+			return err
+		}
+
+		redirects = append(redirects, RedirectHop{
+			URL:      reply.Request.URL.String(),
+			Status:   reply.StatusCode,
+			Location: redirectLocation(reply.Request.URL.String(), reply.StatusCode, reply.Header),
+		})
+
+		response = DocumentResponse{
+			URL:         reply.Request.URL.String(),
+			Status:      reply.StatusCode,
+			StatusText:  http.StatusText(reply.StatusCode),
+			ContentType: reply.Header.Get("Content-Type"),
+			Headers:     map[string]string{},
+		}
+		for _, name := range relevantHeaders {
+			if values := reply.Header.Values(name); len(values) > 0 {
+				response.Headers[name] = strings.Join(values, ", ")
+			}
+		}
+		cookies = jar.matching(reply.Request.URL)
+
+		if config.IfChanged {
+			if reply.StatusCode == http.StatusNotModified && len(redirects) == 1 {
+				notModified = true
+				return nil
+			}
+			if reply.StatusCode == http.StatusOK && len(redirects) == 1 {
+				if err := saveValidators(validatorsFile, baseURL, reply.Header); err != nil {
+					return err
+				}
+			}
+		}
+
+		mediaType, params, _ := mime.ParseMediaType(response.ContentType)
+		if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+			return fmt.Errorf("%s is %s, --http-only only reads HTML pages", response.URL, mediaType)
+		}
+		if charset := strings.ToLower(params["charset"]); charset != "" && charset != "utf-8" && charset != "utf8" && charset != "us-ascii" {
+			logf("Warning: The page is encoded as %s, which --http-only reads as UTF-8\n", charset)
+		}
+		content = string(body)
+		return nil
+	}
+
+	// WebKit keeps no profiles, so there is no jar to share
+	var err error
+	if config.Engine == "webkit" {
+		err = fetch(&cookieJar{})
+	} else {
+		err = withCookieJar(config, fetch)
+	}
+	if err != nil {
+		return "", err
+	}
+	config.Manifest.mark("capture")
+	if config.Manifest != nil {
+		config.Manifest.FinalURL = response.URL
+	}
+	if !config.Redirects {
+		redirects = nil
+	}
+
+	var runErr error
+	if config.FailOnStatus && response.Status >= 400 {
+		runErr = fmt.Errorf("page returned HTTP %d %s", response.Status, response.StatusText)
+	}
+
+	if config.DumpCookies && config.CookiesPath != "" {
+		if err := writeCookies(config.CookiesPath, cookies); err != nil {
+			return "", err
+		}
+		logf("Cookies saved to %s\n", config.CookiesPath)
+		config.Manifest.addFile("cookies", config.CookiesPath)
+	}
+
+	// A page the server says hasn't changed isn't converted again
+	if notModified {
+		if config.Format == "json" {
+			encoded, err := json.MarshalIndent(PageResult{
+				URL:         response.URL,
+				Response:    response,
+				Redirects:   redirects,
+				Cookies:     withoutValues(cookies),
+				NotModified: true,
+			}, "", "  ")
+			if err != nil {
+				return "", fmt.Errorf("could not encode page: %v", err)
+			}
+			return string(encoded), runErr
+		}
+		result := fmt.Sprintf("==========================\n%s==========================\n\n%s", pageHeader(baseURL, response), formatNotModified(validators))
+		if config.Headers {
+			result += formatSection("RESPONSE HEADERS", formatResponse(response))
+		}
+		return result, runErr
+	}
+
+	if config.RawFlag {
+		return declareUTF8(content), runErr
+	}
+
+	markdown, tokens, err := outputMarkdown(config, content)
+	if err != nil {
+		return "", err
+	}
+	tokenCount := tokens.count(markdown)
+
+	var changes string
+	if config.DiffPrev {
+		path, err := snapshotPath(config.Profile, baseURL)
+		if err != nil {
+			return "", err
+		}
+		if changes, err = diffPrevious(path, markdown); err != nil {
+			return "", err
+		}
+	}
+
+	title, lang := documentTitleAndLang(content)
+	if config.Format == "json" {
+		encoded, err := json.MarshalIndent(PageResult{
+			URL:          response.URL,
+			Title:        title,
+			PageLanguage: PageLanguage{Lang: lang},
+			Response:     response,
+			Redirects:    redirects,
+			Markdown:     markdown,
+			Tokens:       tokenCount,
+			Cookies:      withoutValues(cookies),
+			Changes:      changes,
+		}, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("could not encode page: %v", err)
+		}
+		return string(encoded), runErr
+	}
+
+	result := fmt.Sprintf("==========================\n%sTokens: %s\n==========================\n\n%s", pageHeader(baseURL, response), tokens.describe(tokenCount), markdown)
+	if config.FrontMatter {
+		frontMatter, err := newFrontMatter(baseURL, response, title, lang, markdown, tokenCount).render()
+		if err != nil {
+			return "", err
+		}
+		result = frontMatter + markdown
+	}
+	if config.Headers {
+		result += formatSection("RESPONSE HEADERS", formatResponse(response))
+	}
+	if config.Redirects {
+		result += formatSection("REDIRECTS", formatRedirects(redirects))
+	}
+	if config.DiffPrev {
+		result += formatSection("CHANGES", changes)
+	}
+	if config.DumpCookies {
+		result += formatSection("COOKIES", formatCookies(cookies))
+	}
+	return result, runErr
+}
+
+// documentTitleAndLang reads the <title> and the lang attribute of the root
+// element from a page's HTML
+func documentTitleAndLang(content string) (string, string) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", ""
+	}
+	title, lang := "", ""
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Html:
+				lang = getAttr(n, "lang")
+			case atom.Title:
+				if title == "" {
+					title = strings.Join(strings.Fields(rawText(n)), " ")
+				}
+				return
+			case atom.Body:
+				// The title is in the head
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return title, lang
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+			http.Redirect(w, r, "/account", http.StatusFound)
+		case "/account":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("<html><head><title>Sign in</title></head><body><p>Not signed in</p></body></html>"))
+				return
+			}
+			w.Write([]byte(`<html lang="en"><head><title>Account</title></head><body><h1>Welcome back</h1></body></html>`))
+		default:
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4"))
+		}
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	config.HTTPOnly = true
+	config.FailOnStatus = true
+
+	config.URL = server.URL + "/account"
+	if _, err := fetchHTTP(config); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Fatalf("Expected a 401 before signing in, got %v", err)
+	}
+
+	// The cookie set on the way is kept in the profile's jar for later runs
+	config.URL = server.URL + "/login"
+	result, err := fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Welcome back", "Final URL: " + server.URL + "/account", "Status: 200 OK"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+
+	config.URL = server.URL + "/account"
+	config.FrontMatter = true
+	if result, err = fetchHTTP(config); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "title: Account") || !strings.Contains(result, "lang: en") {
+		t.Errorf("Expected the title and language in the front matter:\n%s", result)
+	}
+
+	config.URL = server.URL + "/report.pdf"
+	if _, err := fetchHTTP(config); err == nil || !strings.Contains(err.Error(), "only reads HTML pages") {
+		t.Errorf("Expected a PDF to be refused, got %v", err)
+	}
+}
+
+func TestCookieJarMatching(t *testing.T) {
+	jar := &cookieJar{}
+	jar.add(
+		Cookie{Name: "host", Value: "1", Domain: "example.com", Path: "/"},
+		Cookie{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		Cookie{Name: "admin", Value: "3", Domain: "example.com", Path: "/admin"},
+		Cookie{Name: "secure", Value: "4", Domain: "example.com", Path: "/", Secure: true},
+		Cookie{Name: "expired", Value: "5", Domain: "example.com", Path: "/", Expiry: 1},
+		Cookie{Name: "other", Value: "6", Domain: "example.org", Path: "/"},
+	)
+
+	names := func(rawURL string) string {
+		request, _ := http.NewRequest("GET", rawURL, nil)
+		var found []string
+		for _, cookie := range jar.Cookies(request.URL) {
+			found = append(found, cookie.Name)
+		}
+		return strings.Join(found, ",")
+	}
+	tests := map[string]string{
+		"http://example.com/":            "host,domain",
+		"https://example.com/admin/user": "host,domain,admin,secure",
+		"http://example.com/administer":  "host,domain",
+		"http://www.example.com/":        "domain",
+		"http://example.org/":            "other",
+	}
+	for rawURL, want := range tests {
+		if got := names(rawURL); got != want {
+			t.Errorf("Cookies for %s = %q, expected %q", rawURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// interruptGatherTimeout bounds how long an interrupted run waits for the
+// browser to hand over the page, as it may be busy loading it
+const interruptGatherTimeout = 5 * time.Second
+
+// handleInterrupt watches for SIGINT and SIGTERM while the run has its
+// browser open. An interrupted run hands what it gathered, the page as it is
+// now and its console output, to config.OnInterrupt after closing the
+// browser. The returned function stops watching once the run is over.
+func handleInterrupt(wd selenium.WebDriver, config Config, baseURL string, stop func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done, exited := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		var sig os.Signal
+		select {
+		case <-done:
+			close(exited)
+			return
+		case sig = <-signals:
+		}
+		logf("Interrupted, closing the browser...\n")
+
+		type gathered struct {
+			markdown string
+			console  []ConsoleEntry
+		}
+		page := make(chan gathered, 1)
+		go func() {
+			var g gathered
+			if content, err := pageSource(wd, config); err == nil {
+				g.markdown, _, _ = outputMarkdown(config, content)
+			}
+			g.console = filterConsole(collectConsoleMessages(wd), config.ConsoleLevel)
+			page <- g
+		}()
+		var g gathered
+		select {
+		case g = <-page:
+		case <-time.After(interruptGatherTimeout):
+			logf("Warning: The browser didn't hand over the page within %s, leaving it out\n", interruptGatherTimeout)
+		}
+
+		stop()
+		config.OnInterrupt(formatInterrupted(config, baseURL, g.markdown, g.console), sig)
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		// An interrupt being handled ends the process
+		<-exited
+	}
+}
+
+// formatInterrupted renders the partial result of an interrupted run,
+// marked as such
+func formatInterrupted(config Config, baseURL, markdown string, console []ConsoleEntry) string {
+	if config.Format == "json" {
+		encoded, err := json.MarshalIndent(PageResult{
+			URL:         baseURL,
+			Markdown:    markdown,
+			Console:     console,
+			Interrupted: true,
+		}, "", "  ")
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+
+	result := fmt.Sprintf("==========================\n%s\n==========================\n\n%s", baseURL, markdown)
+	if len(console) > 0 {
+		lines := make([]string, len(console))
+		for i, message := range console {
+			lines[i] = message.String()
+		}
+		result += formatSection("CONSOLE OUTPUT", strings.Join(lines, "\n")+"\n")
+	}
+	return result + formatSection("RUN INTERRUPTED", "The run was stopped before it finished, the page is as it was at that point\n")
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFormatInterrupted(t *testing.T) {
+	config := newConfig()
+	console := []ConsoleEntry{{Level: "log", Text: "loading"}}
+
+	result := formatInterrupted(config, "https://example.com", "# Partial", console)
+	for _, want := range []string{"https://example.com", "# Partial", "CONSOLE OUTPUT", "loading", "RUN INTERRUPTED"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+
+	config.Format = "json"
+	var page PageResult
+	if err := json.Unmarshal([]byte(formatInterrupted(config, "https://example.com", "# Partial", console)), &page); err != nil {
+		t.Fatal(err)
+	}
+	if !page.Interrupted || page.Markdown != "# Partial" || len(page.Console) != 1 {
+		t.Errorf("Expected an interrupted page with its markdown and console, got %+v", page)
+	}
+}
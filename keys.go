@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tebeka/selenium"
+)
+
+// namedKeys maps the key names accepted by --keys to WebDriver key codes
+var namedKeys = map[string]string{
+	"meta":       selenium.MetaKey,
+	"cmd":        selenium.MetaKey,
+	"command":    selenium.MetaKey,
+	"control":    selenium.ControlKey,
+	"ctrl":       selenium.ControlKey,
+	"alt":        selenium.AltKey,
+	"option":     selenium.AltKey,
+	"shift":      selenium.ShiftKey,
+	"enter":      selenium.EnterKey,
+	"return":     selenium.ReturnKey,
+	"escape":     selenium.EscapeKey,
+	"esc":        selenium.EscapeKey,
+	"tab":        selenium.TabKey,
+	"space":      selenium.SpaceKey,
+	"backspace":  selenium.BackspaceKey,
+	"delete":     selenium.DeleteKey,
+	"home":       selenium.HomeKey,
+	"end":        selenium.EndKey,
+	"pageup":     selenium.PageUpKey,
+	"pagedown":   selenium.PageDownKey,
+	"arrowup":    selenium.UpArrowKey,
+	"up":         selenium.UpArrowKey,
+	"arrowdown":  selenium.DownArrowKey,
+	"down":       selenium.DownArrowKey,
+	"arrowleft":  selenium.LeftArrowKey,
+	"left":       selenium.LeftArrowKey,
+	"arrowright": selenium.RightArrowKey,
+	"right":      selenium.RightArrowKey,
+	"f1":         selenium.F1Key,
+	"f2":         selenium.F2Key,
+	"f3":         selenium.F3Key,
+	"f4":         selenium.F4Key,
+	"f5":         selenium.F5Key,
+	"f6":         selenium.F6Key,
+	"f7":         selenium.F7Key,
+	"f8":         selenium.F8Key,
+	"f9":         selenium.F9Key,
+	"f10":        selenium.F10Key,
+	"f11":        selenium.F11Key,
+	"f12":        selenium.F12Key,
+}
+
+// parseKeyChord turns a chord like "Meta+K" or "Ctrl+Shift+P" into the keys
+// to hold down in order. Single characters are sent as typed, except that
+// letters are lowercased unless Shift is part of the chord, matching the key
+// a user would press.
+func parseKeyChord(chord string) ([]string, error) {
+	parts := strings.Split(chord, "+")
+	// "Ctrl++" means Ctrl and the plus key
+	if strings.HasSuffix(chord, "++") {
+		parts = append(strings.Split(strings.TrimSuffix(chord, "++"), "+"), "+")
+	}
+
+	shift := false
+	for _, part := range parts {
+		if strings.EqualFold(strings.TrimSpace(part), "shift") {
+			shift = true
+		}
+	}
+
+	var keys []string
+	for _, part := range parts {
+		name := strings.TrimSpace(part)
+		if key, ok := namedKeys[strings.ToLower(name)]; ok {
+			keys = append(keys, key)
+			continue
+		}
+		if utf8.RuneCountInString(name) != 1 {
+			return nil, fmt.Errorf("unknown key %q in %q", name, chord)
+		}
+		if !shift {
+			name = strings.ToLower(name)
+		}
+		keys = append(keys, name)
+	}
+	return keys, nil
+}
+
+// pressKeyChord presses the keys in order and releases them in reverse,
+// targeting whatever has focus, so global shortcuts reach the page
+func pressKeyChord(wd selenium.WebDriver, keys []string) error {
+	var actions []map[string]interface{}
+	for _, key := range keys {
+		actions = append(actions, map[string]interface{}{"type": "keyDown", "value": key})
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		actions = append(actions, map[string]interface{}{"type": "keyUp", "value": keys[i]})
+	}
+
+	err := webDriverCommand(wd, http.MethodPost, "/actions", map[string]interface{}{
+		"actions": []interface{}{
+			map[string]interface{}{
+				"type":    "key",
+				"id":      "keyboard",
+				"actions": actions,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return webDriverCommand(wd, http.MethodDelete, "/actions", nil)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tebeka/selenium"
+)
+
+func TestParseKeyChord(t *testing.T) {
+	cases := []struct {
+		chord    string
+		expected []string
+	}{
+		{"Meta+K", []string{selenium.MetaKey, "k"}},
+		{"Ctrl+Shift+P", []string{selenium.ControlKey, selenium.ShiftKey, "P"}},
+		{"Escape", []string{selenium.EscapeKey}},
+		{"/", []string{"/"}},
+		{"Ctrl++", []string{selenium.ControlKey, "+"}},
+	}
+
+	for _, tc := range cases {
+		keys, err := parseKeyChord(tc.chord)
+		if err != nil {
+			t.Errorf("parseKeyChord(%q) returned error: %v", tc.chord, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, tc.expected) {
+			t.Errorf("parseKeyChord(%q) = %q, expected %q", tc.chord, keys, tc.expected)
+		}
+	}
+
+	if _, err := parseKeyChord("Hyper+K"); err == nil {
+		t.Errorf("Expected an error for an unknown key name")
+	}
+}
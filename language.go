@@ -0,0 +1,69 @@
+package main
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/tebeka/selenium"
+)
+
+// PageLanguage is how the browser decoded the page and what language and
+// writing direction it declares
+type PageLanguage struct {
+	Charset string `json:"charset,omitempty"`
+	Lang    string `json:"lang,omitempty"`
+	Dir     string `json:"dir,omitempty"`
+}
+
+// detectLanguageJS reads the encoding Firefox settled on (from the header,
+// a <meta> tag or by sniffing the bytes), the language from lang attributes
+// or Content-Language, and the direction the root element is laid out in
+const detectLanguageJS = `
+	var contentLanguage = document.querySelector('meta[http-equiv="content-language" i]');
+	var lang = document.documentElement.lang || (document.body && document.body.lang) ||
+		(contentLanguage ? contentLanguage.content.split(',')[0].trim() : '');
+	return {
+		charset: document.characterSet || '',
+		lang: lang,
+		dir: getComputedStyle(document.body || document.documentElement).direction || ''
+	};
+`
+
+// detectLanguage describes the page's encoding, language and direction,
+// leaving fields it can't read empty
+func detectLanguage(wd selenium.WebDriver) PageLanguage {
+	raw, err := wd.ExecuteScript(detectLanguageJS, nil)
+	if err != nil {
+		return PageLanguage{}
+	}
+	entry, _ := raw.(map[string]interface{})
+	language := PageLanguage{}
+	language.Charset, _ = entry["charset"].(string)
+	language.Lang, _ = entry["lang"].(string)
+	language.Dir, _ = entry["dir"].(string)
+	return language
+}
+
+var (
+	metaCharset     = regexp.MustCompile(`(?i)(<meta\s[^>]*charset\s*=\s*["']?)[\w.:-]+`)
+	metaContentType = regexp.MustCompile(`(?i)(<meta\s[^>]*content\s*=\s*["']?[^"'>]*charset=)[\w.:-]+`)
+)
+
+// declareUTF8 rewrites the page's charset declarations, which name the
+// encoding it was served in, to the UTF-8 that page source is returned in
+func declareUTF8(content string) string {
+	content = metaCharset.ReplaceAllString(content, "${1}utf-8")
+	return metaContentType.ReplaceAllString(content, "${1}utf-8")
+}
+
+// truncateUTF8 cuts text to at most n bytes without splitting a character
+func truncateUTF8(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	// Back up to the start of the character the cut falls in
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	text := "日本語" // three bytes per character
+	for n, expected := range map[int]string{0: "", 2: "", 3: "日", 5: "日", 6: "日本", 9: "日本語", 20: "日本語"} {
+		got := truncateUTF8(text, n)
+		if got != expected || !utf8.ValidString(got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q, expected %q", text, n, got, expected)
+		}
+	}
+}
+
+func TestDeclareUTF8(t *testing.T) {
+	tests := []struct{ html, expected string }{
+		{`<meta charset="windows-1252">`, `<meta charset="utf-8">`},
+		{`<META CHARSET=Shift_JIS>`, `<META CHARSET=utf-8>`},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">`, `<meta http-equiv="Content-Type" content="text/html; charset=utf-8">`},
+		{`<meta name="description" content="All about charsets">`, `<meta name="description" content="All about charsets">`},
+	}
+	for _, test := range tests {
+		if got := declareUTF8(test.html); got != test.expected {
+			t.Errorf("declareUTF8(%s) = %s, expected %s", test.html, got, test.expected)
+		}
+	}
+}
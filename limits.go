@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memorySize matches --max-memory values: bytes, or K, M, G or T (powers of
+// 1024) as systemd takes them
+var memorySize = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+
+// limitCommand returns the command to run a WebDriver server with under
+// --max-memory and --max-cpu, which also hold for the browser it launches.
+// On Linux it is started in a systemd scope of its own with MemoryMax and
+// CPUQuota. Without systemd the server runs as it is and limitProcess caps
+// its memory instead, while a CPU quota can't be enforced and is an error.
+func limitCommand(config Config, name string, args []string) (string, []string, error) {
+	if config.MaxMemory == "" && config.MaxCPU == 0 {
+		return name, args, nil
+	}
+	if !systemdScopes() {
+		if config.MaxCPU > 0 {
+			return "", nil, fmt.Errorf("--max-cpu needs systemd-run on Linux")
+		}
+		if !processLimits {
+			return "", nil, fmt.Errorf("--max-memory needs Linux")
+		}
+		return name, args, nil
+	}
+
+	limited := scopeArgs()
+	if config.MaxMemory != "" {
+		// Without swap the limit would just move to swap
+		limited = append(limited, "-p", "MemoryMax="+config.MaxMemory, "-p", "MemorySwapMax=0")
+	}
+	if config.MaxCPU > 0 {
+		limited = append(limited, "-p", fmt.Sprintf("CPUQuota=%d%%", config.MaxCPU))
+	}
+	limited = append(limited, "--", name)
+	return "systemd-run", append(limited, args...), nil
+}
+
+// limitProcess caps the address space of a WebDriver server started without
+// a systemd scope to --max-memory, with RLIMIT_AS. The browser it launches
+// inherits the limit, which holds for each process rather than all of them.
+func limitProcess(config Config, pid int) error {
+	if config.MaxMemory == "" || systemdScopes() {
+		return nil
+	}
+	size, err := memoryBytes(config.MaxMemory)
+	if err != nil {
+		return err
+	}
+	return setMemoryLimit(pid, size)
+}
+
+// scopeArgs starts a transient scope in the user's systemd instance, or the
+// system's when running as root. systemd-run execs the command in place, so
+// stopping the driver stops the scope.
+func scopeArgs() []string {
+	if os.Geteuid() == 0 {
+		return []string{"--scope", "--quiet", "--collect"}
+	}
+	return []string{"--user", "--scope", "--quiet", "--collect"}
+}
+
+var (
+	scopesOnce sync.Once
+	scopesOK   bool
+)
+
+// systemdScopes reports whether systemd-run can start scopes here, e.g. not
+// in a container without systemd or a session without a user manager
+func systemdScopes() bool {
+	scopesOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		path, err := exec.LookPath("systemd-run")
+		if err != nil {
+			return
+		}
+		scopesOK = exec.Command(path, append(scopeArgs(), "true")...).Run() == nil
+	})
+	return scopesOK
+}
+
+// parseMemorySize checks a --max-memory value, e.g. 2G or 512M
+func parseMemorySize(value string) (string, error) {
+	size := strings.ToUpper(value)
+	if !memorySize.MatchString(size) || strings.Trim(size, "0KMGT") == "" {
+		return "", fmt.Errorf("--max-memory expects a size such as 512M or 2G, got %q", value)
+	}
+	return size, nil
+}
+
+// memoryBytes converts a --max-memory value to bytes
+func memoryBytes(size string) (uint64, error) {
+	shift := strings.IndexByte("KMGT", size[len(size)-1])
+	number, err := strconv.ParseUint(strings.TrimRight(size, "KMGT"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad --max-memory %q: %v", size, err)
+	}
+	return number << (10 * (shift + 1)), nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// processLimits tells whether limitProcess can cap a process here
+const processLimits = true
+
+// setMemoryLimit sets RLIMIT_AS of the process pid, which need not be this one
+func setMemoryLimit(pid int, size uint64) error {
+	limit := syscall.Rlimit{Cur: size, Max: size}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), syscall.RLIMIT_AS, uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
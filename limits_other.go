@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// processLimits tells whether limitProcess can cap a process here
+const processLimits = false
+
+func setMemoryLimit(pid int, size uint64) error {
+	return fmt.Errorf("--max-memory needs Linux")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLimitCommand(t *testing.T) {
+	name, args, err := limitCommand(Config{}, "geckodriver", []string{"--port", "4444"})
+	if err != nil || name != "geckodriver" || !slices.Equal(args, []string{"--port", "4444"}) {
+		t.Errorf("Expected the driver to run as it is without limits, got %s %v, %v", name, args, err)
+	}
+
+	config, err := parseArgs([]string{"example.com", "--max-memory", "2g", "--max-cpu", "150"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxMemory != "2G" || config.MaxCPU != 150 {
+		t.Fatalf("Limits parsed incorrectly: %q, %d", config.MaxMemory, config.MaxCPU)
+	}
+	name, args, err = limitCommand(config, "geckodriver", []string{"--port", "4444"})
+	if systemdScopes() {
+		if err != nil || name != "systemd-run" || !slices.Contains(args, "MemoryMax=2G") || !slices.Contains(args, "CPUQuota=150%") || args[len(args)-3] != "geckodriver" {
+			t.Errorf("Expected the driver in a limited scope, got %s %v, %v", name, args, err)
+		}
+		return
+	}
+
+	// Without systemd a CPU quota can't be enforced, memory is limited per process
+	if err == nil {
+		t.Errorf("Expected --max-cpu to fail without systemd, got %s %v", name, args)
+	}
+	config.MaxCPU = 0
+	name, args, err = limitCommand(config, "geckodriver", []string{"--port", "4444"})
+	if processLimits && (err != nil || name != "geckodriver") {
+		t.Errorf("Expected the driver to run as it is without systemd, got %s %v, %v", name, args, err)
+	}
+}
+
+func TestLimitProcess(t *testing.T) {
+	if runtime.GOOS != "linux" || systemdScopes() {
+		t.Skip("Processes are only limited one by one on Linux without systemd")
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	if err := limitProcess(Config{MaxMemory: "512M"}, cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", cmd.Process.Pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(limits), "\n") {
+		if strings.HasPrefix(line, "Max address space") {
+			if fields := strings.Fields(line); fields[3] != "536870912" || fields[4] != "536870912" {
+				t.Errorf("Expected a 512M address space limit, got %q", line)
+			}
+			return
+		}
+	}
+	t.Errorf("No address space limit in:\n%s", limits)
+}
+
+func TestMemoryBytes(t *testing.T) {
+	for size, expected := range map[string]uint64{"512": 512, "4K": 4 << 10, "512M": 512 << 20, "2G": 2 << 30, "1T": 1 << 40} {
+		if got, err := memoryBytes(size); err != nil || got != expected {
+			t.Errorf("memoryBytes(%q) = %d, %v, expected %d", size, got, err, expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// ChangedRegion is a DOM container patched by the server during the interaction phase
+type ChangedRegion struct {
+	ID      string
+	Content string
+}
+
+// trackLiveViewPatches installs a MutationObserver that records which id'd
+// containers LiveView patches. Class and loading-state churn is ignored so only
+// real content updates are reported.
+func trackLiveViewPatches(wd selenium.WebDriver) error {
+	_, err := wd.ExecuteScript(`
+		if (!window.__phxPatchedRegions) {
+			window.__phxPatchedRegions = {};
+			var observer = new MutationObserver(function(mutations) {
+				mutations.forEach(function(mutation) {
+					var node = mutation.target;
+					var el = node.nodeType === Node.ELEMENT_NODE ? node : node.parentElement;
+					var region = el && el.closest('[id]');
+					if (region) {
+						window.__phxPatchedRegions[region.id] = true;
+					}
+				});
+			});
+			observer.observe(document.body, {
+				childList: true,
+				characterData: true,
+				subtree: true,
+				attributes: true,
+				attributeFilter: ['value', 'checked', 'selected', 'src', 'href', 'hidden', 'disabled']
+			});
+		}
+	`, nil)
+	return err
+}
+
+// collectChangedRegions returns the outermost patched containers with their current content
+func collectChangedRegions(wd selenium.WebDriver) ([]ChangedRegion, error) {
+	raw, err := wd.ExecuteScript(`
+		var ids = Object.keys(window.__phxPatchedRegions || {});
+		var elements = ids.map(function(id) { return document.getElementById(id); })
+			.filter(function(el) { return el !== null; });
+		// Only report the outermost patched container of each subtree
+		return elements.filter(function(el) {
+			return !elements.some(function(other) { return other !== el && other.contains(el); });
+		}).map(function(el) {
+			return { id: el.id, html: el.outerHTML };
+		});
+	`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []ChangedRegion
+	if items, ok := raw.([]interface{}); ok {
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := entry["id"].(string)
+			html, _ := entry["html"].(string)
+
+			text, err := htmlToMarkdown(html)
+			if err != nil {
+				text = html
+			}
+			regions = append(regions, ChangedRegion{ID: id, Content: text})
+		}
+	}
+	return regions, nil
+}
+
+func formatChangedRegions(regions []ChangedRegion) string {
+	if len(regions) == 0 {
+		return "No LiveView regions changed\n"
+	}
+
+	var b strings.Builder
+	for _, region := range regions {
+		fmt.Fprintf(&b, "--- #%s ---\n%s\n", region.ID, region.Content)
+	}
+	return b.String()
+}
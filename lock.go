@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// profileLock is a profile's lockfile, profiles/<name>.lock, held with flock
+// by the web process running a browser on the profile. The kernel drops the
+// lock when that process exits, so a crashed run never leaves it taken. The
+// file records the holder's pid and the directory its browser uses (a
+// temporary one for encrypted profiles) for the next holder and web cleanup.
+type profileLock struct {
+	file *os.File
+}
+
+// lockProfile takes the lock for config.Profile, waiting up to
+// --lock-timeout for a run holding it to finish. With --steal-lock the
+// holder is stopped instead. Browsers an earlier holder left running on the
+// profile are stopped once the lock is taken.
+func lockProfile(config Config) (*profileLock, error) {
+	dir, err := engineDir(config.Engine)
+	if err != nil {
+		return nil, err
+	}
+	profilesDir := filepath.Join(dir, "profiles")
+	if err := os.MkdirAll(profilesDir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create profiles directory: %v", err)
+	}
+	path := filepath.Join(profilesDir, config.Profile+".lock")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open profile lock: %v", err)
+	}
+
+	timeout := config.LockTimeout
+	if timeout == 0 {
+		timeout = DEFAULT_LOCK_TIMEOUT
+	}
+	deadline := time.Now().Add(timeout)
+	waiting := false
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("could not lock profile %q: %v", config.Profile, err)
+		}
+
+		pid, _ := readProfileLock(path)
+		if config.StealLock && pid > 0 {
+			logf("Warning: Stopping the run holding profile %q (pid %d) to take it over\n", config.Profile, pid)
+			stopProcess(pid)
+			// Don't stop whoever takes the lock next
+			config.StealLock = false
+			continue
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("profile %q is in use by another run (pid %d), wait longer with --lock-timeout or take it over with --steal-lock", config.Profile, pid)
+		}
+		if !waiting {
+			logf("Profile %q is in use by another run (pid %d), waiting for it...\n", config.Profile, pid)
+			waiting = true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	reclaimProfile(profilesDir, config.Profile, false)
+	return &profileLock{file: file}, nil
+}
+
+// reclaimProfile stops browsers left running on a profile whose lock the
+// caller holds, and removes a decrypted copy of it left behind, returning how
+// many of those there were. With dryRun they are only reported.
+func reclaimProfile(profilesDir, profile string, dryRun bool) int {
+	_, previousDir := readProfileLock(filepath.Join(profilesDir, profile+".lock"))
+	dirs := []string{filepath.Join(profilesDir, profile)}
+	if previousDir != "" && previousDir != dirs[0] {
+		dirs = append(dirs, previousDir)
+	}
+	found := stopOrphans(dirs, profile, dryRun)
+
+	if len(dirs) > 1 && isProfileTempDir(previousDir) {
+		if _, err := os.Stat(previousDir); err == nil {
+			found++
+			if dryRun {
+				fmt.Printf("Would remove the decrypted copy of profile %q left by an earlier run (%s)\n", profile, previousDir)
+			} else {
+				logf("Warning: Removing the decrypted copy of profile %q left by an earlier run, its changes since are lost\n", profile)
+				os.RemoveAll(previousDir)
+			}
+		}
+	}
+	return found
+}
+
+// record notes the holder's pid and the directory its browser runs on
+func (l *profileLock) record(profileDir string) {
+	l.file.Truncate(0)
+	l.file.WriteAt([]byte(fmt.Sprintf("%d\n%s\n", os.Getpid(), profileDir)), 0)
+}
+
+// release lets the next run have the profile
+func (l *profileLock) release() {
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
+
+// readProfileLock returns the pid and profile directory a lockfile records
+func readProfileLock(path string) (int, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	lines := strings.SplitN(string(data), "\n", 3)
+	pid, _ := strconv.Atoi(strings.TrimSpace(lines[0]))
+	dir := ""
+	if len(lines) > 1 {
+		dir = strings.TrimSpace(lines[1])
+	}
+	return pid, dir
+}
+
+// isProfileTempDir reports whether dir is a decrypted profile copy made by
+// openProfile
+func isProfileTempDir(dir string) bool {
+	return filepath.Dir(dir) == filepath.Clean(os.TempDir()) && strings.HasPrefix(filepath.Base(dir), "web-profile-")
+}
+
+// process is a running process as listed by ps
+type process struct {
+	pid, ppid int
+	args      string
+}
+
+// listProcesses lists every process with its command line
+func listProcesses() ([]process, error) {
+	out, err := exec.Command("ps", "-A", "-ww", "-o", "pid=,ppid=,args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list processes: %v", err)
+	}
+	var processes []process
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		processes = append(processes, process{pid: pid, ppid: ppid, args: strings.Join(fields[2:], " ")})
+	}
+	return processes, nil
+}
+
+// stopOrphans stops browsers running on any of dirs, returning how many
+// there were. The caller holds the profile's lock, so no live run owns them.
+func stopOrphans(dirs []string, profile string, dryRun bool) int {
+	processes, err := listProcesses()
+	if err != nil {
+		logf("Warning: Could not look for browsers left running on profile %q: %v\n", profile, err)
+		return 0
+	}
+	stopped := 0
+	for _, p := range processes {
+		if p.pid == os.Getpid() || !usesProfileDir(p.args, dirs) {
+			continue
+		}
+		// Child processes go with the browser they belong to
+		if parentUsesProfileDir(p, processes, dirs) {
+			continue
+		}
+		stopped++
+		if dryRun {
+			fmt.Printf("Would stop a browser left running on profile %q (pid %d)\n", profile, p.pid)
+			continue
+		}
+		logf("Warning: Stopping a browser left running on profile %q by an earlier run (pid %d)\n", profile, p.pid)
+		stopProcess(p.pid)
+	}
+	return stopped
+}
+
+// usesProfileDir reports whether a command line runs a browser on one of
+// dirs: Firefox's -profile <dir> or Chromium's --user-data-dir=<dir>
+func usesProfileDir(args string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.Contains(args, "-profile "+dir) || strings.Contains(args, "--user-data-dir="+dir) {
+			rest := args[strings.Index(args, dir)+len(dir):]
+			// Not merely a profile whose name starts the same
+			if rest == "" || rest[0] == ' ' || rest[0] == '/' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parentUsesProfileDir(p process, processes []process, dirs []string) bool {
+	for _, parent := range processes {
+		if parent.pid == p.ppid {
+			return usesProfileDir(parent.args, dirs)
+		}
+	}
+	return false
+}
+
+// processAlive reports whether pid is a running process
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// stopProcess asks pid to exit, killing it if it is still running after 5s
+func stopProcess(pid int) {
+	if syscall.Kill(pid, syscall.SIGTERM) != nil {
+		return
+	}
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if !processAlive(pid) {
+			return
+		}
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+}
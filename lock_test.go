@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockProfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WEB_DATA_DIR", "")
+	config := Config{Engine: "firefox", Profile: "agent", LockTimeout: 300 * time.Millisecond}
+
+	lock, err := lockProfile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.record("/tmp/agent-profile")
+
+	if _, err := lockProfile(config); err == nil || !strings.Contains(err.Error(), "in use by another run") {
+		t.Fatalf("Expected the profile to be in use, got %v", err)
+	}
+	// Other profiles aren't held up
+	other, err := lockProfile(Config{Engine: "firefox", Profile: "other"})
+	if err != nil {
+		t.Fatalf("Unexpected error locking another profile: %v", err)
+	}
+	other.release()
+
+	lock.release()
+	again, err := lockProfile(config)
+	if err != nil {
+		t.Fatalf("Expected the released profile to be free: %v", err)
+	}
+	again.release()
+}
+
+func TestUsesProfileDir(t *testing.T) {
+	dirs := []string{"/home/u/.web-firefox/profiles/agent"}
+	tests := map[string]bool{
+		"/home/u/.web-firefox/firefox/firefox -marionette -profile /home/u/.web-firefox/profiles/agent -headless": true,
+		"/home/u/.web-firefox/firefox/firefox -profile /home/u/.web-firefox/profiles/agent":                       true,
+		"/home/u/.web-firefox/firefox/firefox -profile /home/u/.web-firefox/profiles/agent2":                      false,
+		"chrome --user-data-dir=/home/u/.web-firefox/profiles/agent --headless=new":                               true,
+		"/home/u/.web-firefox/firefox/firefox -contentproc -childID 1 tab":                                        false,
+	}
+	for args, want := range tests {
+		if got := usesProfileDir(args, dirs); got != want {
+			t.Errorf("usesProfileDir(%q) = %v, expected %v", args, got, want)
+		}
+	}
+}
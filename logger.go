@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// quiet leaves out progress messages and warnings, set from --quiet for each run
+var quiet bool
+
+// logf writes a progress message or warning to stderr, keeping stdout for the
+// content so it can be piped. Errors are written to stderr directly, so they
+// show even with --quiet.
+func logf(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -85,6 +92,22 @@ func startTestServer() {
 </html>`)
 		})
 
+		// LiveView page with separately addressable regions
+		mux.HandleFunc("/liveview-counter", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>LiveView Counter</title></head>
+<body>
+<div id="phx-root" data-phx-session="test-session" class="phx-connected">
+<h1>Counter</h1>
+<p id="count">0</p>
+<p id="footer">Static footer</p>
+</div>
+</body>
+</html>`)
+		})
+
 		// Regular page with button that triggers navigation
 		mux.HandleFunc("/button-click", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/html")
@@ -110,6 +133,416 @@ func startTestServer() {
 </html>`)
 		})
 
+		// Page that asks for confirmation before deleting
+		mux.HandleFunc("/dialog", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Dialog Test</title></head>
+<body>
+<h1 id="status">Pending</h1>
+<button id="delete" onclick="document.getElementById('status').textContent = confirm('Delete?') ? 'Deleted' : 'Kept'">Delete</button>
+</body>
+</html>`)
+		})
+
+		// Page with a link that opens in a new window
+		mux.HandleFunc("/popup", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Popup Test</title></head>
+<body>
+<h1>Opener Page</h1>
+<a id="open" href="/button-target" target="_blank">Open</a>
+</body>
+</html>`)
+		})
+
+		// Job status page that completes on the second request
+		var jobChecks int32
+		mux.HandleFunc("/job", func(w http.ResponseWriter, r *http.Request) {
+			status := "Running"
+			if atomic.AddInt32(&jobChecks, 1) > 1 {
+				status = "Completed"
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><title>Job Status</title></head>
+<body>
+<h1>Export: %s</h1>
+</body>
+</html>`, status)
+		})
+
+		// Page that embeds nested frames
+		mux.HandleFunc("/frames", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Frames Test</title></head>
+<body>
+<h1>Outer Page</h1>
+<iframe name="outer" src="/frames-inner"></iframe>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/frames-inner", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<body>
+<iframe id="widget" src="/button-target"></iframe>
+</body>
+</html>`)
+		})
+
+		// Page whose form input lives inside a web component's shadow root
+		mux.HandleFunc("/shadow", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Shadow DOM Test</title></head>
+<body>
+<form id="signup" onsubmit="event.preventDefault(); document.getElementById('result').textContent = 'Signed up ' + this.querySelector('email-field').shadowRoot.querySelector('input').value">
+<email-field></email-field>
+<button type="submit">Sign up</button>
+</form>
+<p id="result"></p>
+<script>
+customElements.define('email-field', class extends HTMLElement {
+  connectedCallback() {
+    this.attachShadow({mode: 'open'}).innerHTML = '<label>Email from shadow root</label><input name="email">';
+  }
+});
+</script>
+</body>
+</html>`)
+		})
+
+		// Page that renders its content after load
+		mux.HandleFunc("/async", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Async Test</title></head>
+<body>
+<div id="app">Loading...</div>
+<script>
+setTimeout(function() {
+  document.getElementById('app').innerHTML = '<ul id="items"><li>Rendered later</li></ul>';
+}, 1000);
+</script>
+</body>
+</html>`)
+		})
+
+		// SPA-style page that fetches its data after load
+		mux.HandleFunc("/spa", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>SPA Test</title></head>
+<body>
+<div id="app">Loading...</div>
+<script>
+window.addEventListener('load', function() {
+  setTimeout(function() {
+    fetch('/spa-data').then(function(r) { return r.text(); }).then(function(text) {
+      document.getElementById('app').textContent = text;
+    });
+  }, 200);
+});
+</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/spa-data", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(300 * time.Millisecond)
+			fmt.Fprint(w, "Data from XHR")
+		})
+
+		// Comment form with a textarea and a rich-text editor
+		mux.HandleFunc("/comment", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Comment Test</title></head>
+<body>
+<form id="comment-form" onsubmit="event.preventDefault(); document.getElementById('preview').textContent = 'Title: ' + this.title.value + ' Body: ' + document.getElementById('body').innerText">
+<textarea name="title"></textarea>
+<div id="body" contenteditable="true">Placeholder text</div>
+<button type="submit">Post</button>
+</form>
+<p id="preview"></p>
+</body>
+</html>`)
+		})
+
+		// Settings form with a radio group
+		mux.HandleFunc("/settings", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Settings Test</title></head>
+<body>
+<form id="settings" onsubmit="event.preventDefault(); document.getElementById('saved').textContent = 'Theme: ' + this.theme.value + ', tags: ' + Array.from(this.tags.selectedOptions, o => o.value).join(' ')">
+<label><input type="radio" name="theme" value="light" checked> Light</label>
+<label><input type="radio" name="theme" value="dark"> Dark</label>
+<select name="tags" multiple onchange="document.getElementById('changes').textContent = 'Changes: ' + (++window.changes)">
+<option value="go">Go</option><option value="elixir" selected>Elixir</option><option value="rust">Rust</option>
+</select>
+<p id="changes"></p>
+<button type="submit">Save</button>
+</form>
+<p id="saved"></p>
+<script>window.changes = 0;</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/contact", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Contact Test</title></head>
+<body>
+<form id="contact" onsubmit="event.preventDefault(); document.getElementById('sent').textContent = 'Sent ' + this.elements[0].value + ': ' + this.elements[1].value">
+<label for="f-x81">Email address *</label>
+<input id="f-x81" name="f_x81" type="email">
+<span id="msg-label">Message</span>
+<textarea name="f_x82" aria-labelledby="msg-label"></textarea>
+<button type="submit">Send</button>
+</form>
+<p id="sent"></p>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/csrf", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				if r.Header.Get("x-csrf-token") != "t0k3n" {
+					http.Error(w, "invalid CSRF token", http.StatusForbidden)
+					return
+				}
+				fmt.Fprint(w, "accepted")
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>CSRF Test</title><meta name="csrf-token" content="t0k3n"></head>
+<body><p id="status">Pending</p></body>
+</html>`)
+		})
+
+		mux.HandleFunc("/grid", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Grid Test</title></head>
+<body>
+<table><tr><td id="cell" ondblclick="this.textContent = 'Editing cell'">B2</td></tr></table>
+<div id="row" oncontextmenu="event.preventDefault(); document.getElementById('menu').textContent = 'Menu open'">Row 3</div>
+<p id="menu"></p>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/canvas", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Canvas Test</title><style>body { margin: 0 }</style></head>
+<body>
+<canvas id="board" width="400" height="300"></canvas>
+<p id="log"></p>
+<script>
+var board = document.getElementById('board'), log = document.getElementById('log'), start;
+board.addEventListener('mousedown', function(e) { start = e.offsetX + ',' + e.offsetY; });
+board.addEventListener('mouseup', function(e) { log.textContent += ' stroke ' + start + ' to ' + e.offsetX + ',' + e.offsetY; });
+</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/edit", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Edit Test</title></head>
+<body>
+<form id="post" onsubmit="event.preventDefault(); document.getElementById('saved').textContent = 'Saved [' + this.subtitle.value + '] [' + this.notes.value + ']'">
+<input name="subtitle" value="Old subtitle">
+<input name="notes" value="First draft">
+<button type="submit">Save</button>
+</form>
+<p id="saved"></p>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/palette", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Palette Test</title></head>
+<body>
+<p id="palette">Palette closed</p>
+<script>
+document.addEventListener('keydown', function(e) {
+	if (e.ctrlKey && e.key === 'k') { document.getElementById('palette').textContent = 'Palette open'; }
+});
+</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Products Test</title></head>
+<body>
+<div class="product"><h2>Lamp</h2><span class="price">$20</span><img src="/lamp.png"><span class="tag">home</span><span class="tag">light</span></div>
+<div class="product"><h2>Desk</h2><img src="/desk.png"></div>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/links", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Links Test</title></head>
+<body>
+<a href="#top">Top</a>
+<a href="/form"><img src="/icon.png" alt="Form icon"></a>
+<a href="/form#fields">Form</a>
+<a href="https://example.com/" rel="nofollow">Example</a>
+<a href="javascript:void(0)">Nothing</a>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>Launch Day</title>
+<meta name="description" content="We shipped it">
+<link rel="canonical" href="/article">
+<meta property="og:title" content="Launch Day">
+<meta property="og:image" content="https://example.com/a.png">
+<meta property="og:image" content="https://example.com/b.png">
+<meta name="twitter:card" content="summary_large_image">
+<script type="application/ld+json">{"@type": "Article", "headline": "Launch Day"}</script>
+<script type="application/ld+json">{not json</script>
+</head>
+<body><h1>Launch Day</h1></body>
+</html>`)
+		})
+
+		mux.HandleFunc("/search-results", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Search</title></head>
+<body>
+<div class="result"><a href="/article">Launch Day</a><p class="snippet">We shipped it</p></div>
+<div class="result"><a href="/">Test Page</a><p class="snippet">Welcome</p></div>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Not Found</title></head>
+<body><h1>Page not found</h1></body>
+</html>`)
+		})
+
+		mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Broken</title></head>
+<body>
+<img src="/missing">
+<script>fetch('/missing', { method: 'POST' }).then(function() { window.fetched = true; });</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "session_id", Value: "abc123", Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Signed In</title></head>
+<body><p>Welcome back</p></body>
+</html>`)
+		})
+
+		mux.HandleFunc("/chrome", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Chrome</title></head>
+<body>
+<a href="#main">Skip to content</a>
+<header><nav><a href="/">Home</a> <a href="/docs">Docs</a></nav></header>
+<main id="main">
+<article><header><h1>Release Notes</h1></header><p>Version 2 is out.</p><div class="share">Share this</div></article>
+</main>
+<aside>Related posts</aside>
+<footer>Copyright Example</footer>
+<div class="cookie-banner" style="position: fixed; bottom: 0">We use cookies <button>Accept</button></div>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/latin1", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			// "Café crème" in ISO-8859-1
+			w.Write([]byte("<!DOCTYPE html>\n<html lang=\"fr\">\n<head><meta charset=\"iso-8859-1\"><title>Menu</title></head>\n<body><p>Caf\xe9 cr\xe8me</p></body>\n</html>"))
+		})
+
+		mux.HandleFunc("/rtl", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html lang="ar" dir="rtl">
+<head><title>مرحبا</title></head>
+<body><h1>مرحبا بالعالم</h1><p>日本語のテキスト</p></body>
+</html>`)
+		})
+
+		mux.HandleFunc("/styled", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head><title>Styled</title><link rel="stylesheet" href="/styled.css"></head>
+<body>
+<h1 id="heading">Static</h1>
+<input name="q">
+<script>document.getElementById('heading').textContent = 'Rendered'; document.querySelector('input').value = 'typed';</script>
+</body>
+</html>`)
+		})
+
+		mux.HandleFunc("/styled.css", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `h1 { color: rebeccapurple }`)
+		})
+
 		// Start server on port 9999
 		go http.ListenAndServe(":9999", mux)
 		testServerURL = "http://localhost:9999"
@@ -119,34 +552,20 @@ func startTestServer() {
 	})
 }
 
-// setupTest ensures the binary is built and available for testing
+// setupTest builds the binary from the tree under test, once per test run,
+// so the tests never exercise a stale build
 func setupTest(t *testing.T) {
 	if !initialized {
-		// Detect platform and set binary name
-		platform := strings.ToLower(runtime.GOOS)
-		arch := runtime.GOARCH
-		if arch == "amd64" {
-			arch = "amd64"
-		}
-		testBinary = fmt.Sprintf("web-%s-%s", platform, arch)
-
-		// Build the project if binary doesn't exist
-		if _, err := os.Stat(testBinary); os.IsNotExist(err) {
-			t.Logf("Building project...")
-			cmd := exec.Command("./build.sh")
-			if output, err := cmd.CombinedOutput(); err != nil {
-				t.Fatalf("Failed to build project: %v\nOutput: %s", err, output)
-			}
+		dir, err := os.MkdirTemp("", "web-test-")
+		if err != nil {
+			t.Fatalf("Failed to create build directory: %v", err)
 		}
+		testBinary = filepath.Join(dir, "web")
 
-		// Verify binary exists and is executable
-		if _, err := os.Stat(testBinary); err != nil {
-			t.Fatalf("Binary %s not found after build", testBinary)
-		}
-
-		// Make executable
-		if err := os.Chmod(testBinary, 0755); err != nil {
-			t.Fatalf("Failed to make binary executable: %v", err)
+		t.Logf("Building project...")
+		cmd := exec.Command("go", "build", "-o", testBinary, ".")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to build project: %v\nOutput: %s", err, output)
 		}
 
 		// Generate unique test profile name
@@ -162,18 +581,26 @@ func setupTest(t *testing.T) {
 	startTestServer()
 }
 
+// TestMain removes the binary setupTest built once the tests are done
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testBinary != "" {
+		os.RemoveAll(filepath.Dir(testBinary))
+	}
+	os.Exit(code)
+}
+
 // runWeb executes the web binary with given arguments and returns stdout, stderr, and error
 func runWeb(args ...string) (string, string, error) {
-	cmd := exec.Command("./"+testBinary, args...)
+	cmd := exec.Command(testBinary, args...)
 	cmd.Env = os.Environ()
 	
+	// Progress messages and warnings go to stderr, only the content to stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	stdout, err := cmd.Output()
-	stderr := ""
-	if exitError, ok := err.(*exec.ExitError); ok {
-		stderr = string(exitError.Stderr)
-	}
 	
-	return string(stdout), stderr, err
+	return string(stdout), stderr.String(), err
 }
 
 func TestBasicScraping(t *testing.T) {
@@ -238,6 +665,44 @@ func TestJavaScriptExecution(t *testing.T) {
 	}
 }
 
+func TestConsoleLevel(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL, "--format", "json", "--console-level", "warn",
+		"--js", "console.log('chatty'); console.warn('careful');")
+	if err != nil {
+		t.Fatalf("Console level test failed: %v\nStderr: %s", err, stderr)
+	}
+	var page PageResult
+	if err := json.Unmarshal([]byte(stdout), &page); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	if len(page.Console) == 0 || page.Console[0].Level != "warn" || page.Console[0].Text != "careful" || page.Console[0].Time.IsZero() {
+		t.Fatalf("Expected the warning first. Got: %+v", page.Console)
+	}
+	for _, entry := range page.Console {
+		if entry.Text == "chatty" {
+			t.Errorf("Expected log messages to be filtered out. Got: %+v", page.Console)
+		}
+	}
+}
+
+func TestPageErrors(t *testing.T) {
+	setupTest(t)
+
+	stdout, _, err := runWeb(testServerURL, "--fail-on-page-error",
+		"--js", "setTimeout(function() { null.boom; }, 0); Promise.reject(new Error('no data'));",
+		"--wait-for", "window.__pageErrors.length === 2")
+	if err == nil {
+		t.Fatalf("Expected a non-zero exit for uncaught errors. Got:\n%s", stdout)
+	}
+	for _, expected := range []string{"PAGE ERRORS:", "Uncaught TypeError", "Uncaught (in promise) Error: no data"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in output. Got:\n%s", expected, stdout)
+		}
+	}
+}
+
 func TestScreenshotFunctionality(t *testing.T) {
 	setupTest(t)
 	
@@ -268,6 +733,30 @@ func TestScreenshotFunctionality(t *testing.T) {
 	}
 }
 
+func TestScreenshotOptions(t *testing.T) {
+	setupTest(t)
+
+	screenshotFile := filepath.Join(t.TempDir(), "clip.jpg")
+	_, stderr, err := runWeb(testServerURL, "--screenshot", screenshotFile, "--clip", "0,0,200,100",
+		"--screenshot-scale", "2", "--screenshot-quality", "70")
+	if err != nil {
+		t.Fatalf("Screenshot options failed: %v\nStderr: %s", err, stderr)
+	}
+	file, err := os.Open(screenshotFile)
+	if err != nil {
+		t.Fatalf("Screenshot file not created: %v", err)
+	}
+	defer file.Close()
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("Could not decode screenshot: %v", err)
+	}
+	// The clip is in CSS pixels, the image in device pixels
+	if format != "jpeg" || config.Width != 400 || config.Height != 200 {
+		t.Errorf("Expected a 400x200 JPEG, got a %dx%d %s", config.Width, config.Height, format)
+	}
+}
+
 func TestProfileSessionPersistence(t *testing.T) {
 	setupTest(t)
 	
@@ -527,8 +1016,8 @@ func TestLiveViewNavigationWithJavaScript(t *testing.T) {
 	}
 
 	// Check for navigation messages - LiveView page should use LiveView navigation logic
-	if !strings.Contains(stdout, "Waiting for Phoenix LiveView navigation") {
-		t.Logf("Output: %s", stdout)
+	if !strings.Contains(stderr, "Waiting for Phoenix LiveView navigation") {
+		t.Logf("Output: %s", stderr)
 		t.Errorf("Expected 'Waiting for Phoenix LiveView navigation' message")
 	}
 
@@ -553,13 +1042,13 @@ func TestRegularPageNavigationWithJavaScript(t *testing.T) {
 	}
 
 	// Check for navigation messages (should use generic navigation, not LiveView)
-	if !strings.Contains(stdout, "Waiting for page navigation") {
-		t.Logf("Output: %s", stdout)
+	if !strings.Contains(stderr, "Waiting for page navigation") {
+		t.Logf("Output: %s", stderr)
 		t.Errorf("Expected 'Waiting for page navigation' message")
 	}
 
-	if !strings.Contains(stdout, "Navigation detected") || !strings.Contains(stdout, "Page load completed") {
-		t.Logf("Output: %s", stdout)
+	if !strings.Contains(stderr, "Navigation detected") || !strings.Contains(stderr, "Page load completed") {
+		t.Logf("Output: %s", stderr)
 		t.Errorf("Expected navigation completion messages")
 	}
 
@@ -573,7 +1062,7 @@ func TestRegularPageNavigationWithJavaScript(t *testing.T) {
 func TestLiveViewDetectionAndEventSetup(t *testing.T) {
 	setupTest(t)
 
-	stdout, stderr, err := runWeb(
+	_, stderr, err := runWeb(
 		testServerURL+"/liveview",
 		"--truncate-after", "300",
 	)
@@ -582,12 +1071,12 @@ func TestLiveViewDetectionAndEventSetup(t *testing.T) {
 	}
 
 	// Should detect LiveView and wait for connection
-	if !strings.Contains(stdout, "Detected Phoenix LiveView page") {
-		t.Errorf("LiveView page detection failed. Expected 'Detected Phoenix LiveView page'. Got: %s", stdout)
+	if !strings.Contains(stderr, "Detected Phoenix LiveView page") {
+		t.Errorf("LiveView page detection failed. Expected 'Detected Phoenix LiveView page'. Got: %s", stderr)
 	}
 
-	if !strings.Contains(stdout, "Phoenix LiveView connected") {
-		t.Errorf("LiveView connection message not found. Got: %s", stdout)
+	if !strings.Contains(stderr, "Phoenix LiveView connected") {
+		t.Errorf("LiveView connection message not found. Got: %s", stderr)
 	}
 }
 
@@ -610,4 +1099,961 @@ func TestNonLiveViewPageDoesNotTriggerLiveViewLogic(t *testing.T) {
 	if strings.Contains(stdout, "Phoenix LiveView connected") {
 		t.Errorf("Regular page should not show LiveView connection message. Got: %s", stdout)
 	}
-}
\ No newline at end of file
+
+	if strings.Contains(stderr, "Detected Phoenix LiveView page") || strings.Contains(stderr, "Phoenix LiveView connected") {
+		t.Errorf("Regular page should not report LiveView on stderr either. Got: %s", stderr)
+	}
+}
+func TestLiveViewChangedRegions(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/liveview-counter",
+		"--changed-regions",
+		"--js", `document.getElementById('count').textContent = '42';`,
+	)
+	if err != nil {
+		t.Fatalf("Changed regions test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "CHANGED REGIONS:") || !strings.Contains(stdout, "--- #count ---\n42") {
+		t.Errorf("Expected #count to be reported as changed. Got: %s", stdout)
+	}
+
+	if strings.Contains(stdout, "--- #footer ---") {
+		t.Errorf("Unchanged region reported as changed. Got: %s", stdout)
+	}
+}
+
+func TestDialogAccept(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/dialog",
+		"--dialog", "accept",
+		"--js", "document.getElementById('delete').click()",
+	)
+	if err != nil {
+		t.Fatalf("Dialog test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Deleted") {
+		t.Errorf("Expected confirm() to be accepted. Got: %s", stdout)
+	}
+
+	if !strings.Contains(stdout, "DIALOGS:") || !strings.Contains(stdout, "[confirm] Delete? -> accepted") {
+		t.Errorf("Expected dialog to be reported. Got: %s", stdout)
+	}
+}
+
+
+func TestFollowPopup(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/popup",
+		"--js", "document.getElementById('open').click()",
+		"--follow-popup",
+	)
+	if err != nil {
+		t.Fatalf("Popup test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Button Click Navigation Successful") {
+		t.Errorf("Expected popup content to be captured. Got: %s", stdout)
+	}
+
+	if !strings.Contains(stdout, "POPUPS:") || !strings.Contains(stdout, "/button-target (Button Target) [followed]") {
+		t.Errorf("Expected followed popup to be listed. Got: %s", stdout)
+	}
+}
+
+func TestPollUntilText(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/job",
+		"--poll-until-text", "Completed",
+		"--poll-interval", "1s",
+		"--poll-timeout", "30s",
+	)
+	if err != nil {
+		t.Fatalf("Poll test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Export: Completed") {
+		t.Errorf("Expected final job state to be captured. Got: %s", stdout)
+	}
+}
+
+func TestNestedFrame(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/frames", "--frame", "outer > /button-target")
+	if err != nil {
+		t.Fatalf("Frame test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Button Click Navigation Successful") {
+		t.Errorf("Expected nested frame content. Got: %s", stdout)
+	}
+
+	if strings.Contains(stdout, "Outer Page") {
+		t.Errorf("Expected only the frame to be extracted. Got: %s", stdout)
+	}
+}
+
+func TestDeepShadowDOM(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/shadow",
+		"--deep",
+		"--form", "signup",
+		"--input", "email", "--value", "foo@bar.com",
+	)
+	if err != nil {
+		t.Fatalf("Shadow DOM test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Signed up foo@bar.com") {
+		t.Errorf("Expected input inside shadow root to be filled. Got: %s", stdout)
+	}
+
+	if !strings.Contains(stdout, "Email from shadow root") {
+		t.Errorf("Expected shadow root content in output. Got: %s", stdout)
+	}
+}
+
+func TestWaitForSelectorAndText(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/async", "--wait-for-selector", "#items", "--wait-for-text", "Rendered later")
+	if err != nil {
+		t.Fatalf("Wait test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Rendered later") {
+		t.Errorf("Expected asynchronously rendered content. Got: %s", stdout)
+	}
+
+	_, _, err = runWeb(testServerURL+"/async", "--wait-for-selector", "#never", "--wait-timeout", "2s")
+	if err == nil {
+		t.Errorf("Expected a missing selector to fail the run")
+	}
+}
+
+func TestWaitUntilNetworkIdle(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/spa", "--wait-until", "networkidle")
+	if err != nil {
+		t.Fatalf("Network idle test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Data from XHR") {
+		t.Errorf("Expected content loaded by XHR. Got: %s", stdout)
+	}
+}
+
+func TestCompileURLPattern(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		url      string
+		expected bool
+	}{
+		{"*/dashboard*", "http://localhost:4000/dashboard?tab=1", true},
+		{"*/dashboard*", "http://localhost:4000/users/log-in", false},
+		{"http://localhost:4000/posts/*", "http://localhost:4000/posts/42", true},
+		{"/posts/\\d+$/", "http://localhost:4000/posts/42", true},
+		{"/posts/\\d+$/", "http://localhost:4000/posts/new", false},
+	}
+
+	for _, tc := range cases {
+		pattern, err := compileURLPattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("compileURLPattern(%q) failed: %v", tc.pattern, err)
+		}
+		if got := pattern.MatchString(tc.url); got != tc.expected {
+			t.Errorf("%q matching %q = %v, expected %v", tc.pattern, tc.url, got, tc.expected)
+		}
+	}
+}
+
+func TestWaitForFunction(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/async",
+		"--wait-for", "document.querySelectorAll('#items li').length > 0",
+		"--wait-interval", "250ms",
+	)
+	if err != nil {
+		t.Fatalf("Wait for function test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Rendered later") {
+		t.Errorf("Expected content rendered before the predicate became true. Got: %s", stdout)
+	}
+}
+
+func TestTabWalk(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/form", "--focus", "input[name='username']", "--tab-walk", "2")
+	if err != nil {
+		t.Fatalf("Tab walk test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "FOCUS ORDER:") || !strings.Contains(stdout, "start: input[name=\"username\"]") {
+		t.Errorf("Expected focus order starting at the username input. Got: %s", stdout)
+	}
+
+	if !strings.Contains(stdout, "tab 2: ") {
+		t.Errorf("Expected two Tab presses to be recorded. Got: %s", stdout)
+	}
+}
+
+func TestFormTextareaAndContentEditable(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(
+		testServerURL+"/comment",
+		"--form", "comment-form",
+		"--input", "title", "--value", "Hello",
+		"--input", "body", "--value", "Rich text",
+	)
+	if err != nil {
+		t.Fatalf("Comment form test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Title: Hello Body: Rich text") {
+		t.Errorf("Expected textarea and contenteditable to be filled. Got: %s", stdout)
+	}
+}
+
+func TestFormRadioGroup(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/settings", "--form", "settings", "--input", "theme", "--value", "dark")
+	if err != nil {
+		t.Fatalf("Radio form test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Theme: dark") {
+		t.Errorf("Expected the dark radio to be selected. Got: %s", stdout)
+	}
+}
+
+func TestFormInputLabel(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/contact", "--form", "contact",
+		"--input-label", "Email address", "--value", "foo@bar.com",
+		"--input-label", "message", "--value", "Hello")
+	if err != nil {
+		t.Fatalf("Label form test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Sent foo@bar.com: Hello") {
+		t.Errorf("Expected fields to be filled by label. Got: %s", stdout)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/csrf", "--csrf",
+		"--js", "fetch('/csrf', {method: 'POST'}).then(r => r.text()).then(t => document.getElementById('status').textContent = t)",
+		"--wait-for-text", "accepted")
+	if err != nil {
+		t.Fatalf("CSRF test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "accepted") {
+		t.Errorf("Expected the POST to carry the CSRF token. Got: %s", stdout)
+	}
+}
+
+func TestDoubleAndRightClick(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/grid", "--dblclick", "#cell", "--right-click", "#row")
+	if err != nil {
+		t.Fatalf("Click actions test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Editing cell") {
+		t.Errorf("Expected dblclick to start editing. Got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Menu open") {
+		t.Errorf("Expected right-click to open the context menu. Got: %s", stdout)
+	}
+}
+
+func TestMouseCoordinates(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/canvas", "--mouse-click", "20,30", "--mouse-drag", "100,100 300,250")
+	if err != nil {
+		t.Fatalf("Mouse coordinates test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "stroke 20,30 to 20,30") {
+		t.Errorf("Expected a click at 20,30. Got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "stroke 100,100 to 300,250") {
+		t.Errorf("Expected a drag from 100,100 to 300,250. Got: %s", stdout)
+	}
+}
+
+func TestFormMultiSelect(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/settings", "--form", "settings", "--input", "tags", "--value", "go, Rust")
+	if err != nil {
+		t.Fatalf("Multi-select form test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "tags: go rust") {
+		t.Errorf("Expected go and rust to be the only selected tags. Got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Changes: 1") {
+		t.Errorf("Expected a single change event. Got: %s", stdout)
+	}
+}
+
+func TestFormClearAndAppend(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/edit", "--form", "post", "--clear", "subtitle",
+		"--fill-mode", "append", "--input", "notes", "--value", ", revised")
+	if err != nil {
+		t.Fatalf("Clear and append test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Saved [] [First draft, revised]") {
+		t.Errorf("Expected subtitle cleared and notes appended to. Got: %s", stdout)
+	}
+}
+
+func TestKeyChord(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/palette", "--keys", "Ctrl+K")
+	if err != nil {
+		t.Fatalf("Key chord test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Palette open") {
+		t.Errorf("Expected Ctrl+K to open the palette. Got: %s", stdout)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/settings", "--assert-title", "Settings", "--assert-selector", "#saved", "--assert-text", "Dark")
+	if err != nil {
+		t.Fatalf("Expected assertions to pass: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "PASS: 3 of 3 assertions passed") {
+		t.Errorf("Expected a passing verdict. Got: %s", stdout)
+	}
+
+	stdout, stderr, err = runWeb(testServerURL+"/settings", "--assert-title", "Settings", "--assert-selector", "#missing")
+	if err == nil {
+		t.Fatalf("Expected a failed assertion to exit non-zero")
+	}
+	if !strings.Contains(stdout, "FAIL selector #missing") || !strings.Contains(stderr, "1 of 2 assertion(s) failed: selector #missing") {
+		t.Errorf("Expected the failed assertion to be named.\nStdout: %s\nStderr: %s", stdout, stderr)
+	}
+}
+
+func TestExtractSelector(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/", "--extract", "#content")
+	if err != nil {
+		t.Fatalf("Extract test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Test content here") {
+		t.Errorf("Expected the extracted region. Got: %s", stdout)
+	}
+	if strings.Contains(stdout, "This is a test page") {
+		t.Errorf("Expected content outside the region to be left out. Got: %s", stdout)
+	}
+
+	_, stderr, err = runWeb(testServerURL+"/", "--extract", "#missing")
+	if err == nil || !strings.Contains(stderr, "--extract: no elements match #missing") {
+		t.Errorf("Expected an error for a selector that matches nothing. Stderr: %s", stderr)
+	}
+}
+
+func TestExtractSchema(t *testing.T) {
+	setupTest(t)
+
+	schema := filepath.Join(t.TempDir(), "products.json")
+	os.WriteFile(schema, []byte(`{"_items": ".product", "name": "h2", "price": ".price@text", "image": "img@src", "tags": [".tag"]}`), 0644)
+
+	stdout, stderr, err := runWeb(testServerURL+"/products", "--extract-schema", schema)
+	if err != nil {
+		t.Fatalf("Schema extraction test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	var products []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &products); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	if len(products) != 2 || products[0]["name"] != "Lamp" || products[0]["price"] != "$20" || products[1]["price"] != nil {
+		t.Errorf("Unexpected products: %+v", products)
+	}
+	if products[0]["image"] != testServerURL+"/lamp.png" {
+		t.Errorf("Expected an absolute image URL, got %v", products[0]["image"])
+	}
+	if tags, _ := products[0]["tags"].([]interface{}); len(tags) != 2 {
+		t.Errorf("Expected both tags, got %v", products[0]["tags"])
+	}
+}
+
+func TestLinks(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/links", "--links", "--json")
+	if err != nil {
+		t.Fatalf("Links test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	var links []Link
+	if err := json.Unmarshal([]byte(stdout), &links); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	expected := []Link{
+		{Text: "Form icon", Href: testServerURL + "/form", Internal: true},
+		{Text: "Example", Href: "https://example.com/", Rel: "nofollow"},
+	}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("links = %+v, expected %+v", links, expected)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/article", "--meta")
+	if err != nil {
+		t.Fatalf("Meta test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	// The invalid JSON-LD block is reported on stderr, leaving the JSON alone
+	if !strings.Contains(stderr, "Warning: Skipping invalid JSON-LD block 2") {
+		t.Errorf("Expected invalid JSON-LD warning. Got:\n%s", stderr)
+	}
+	var meta PageMeta
+	if err := json.Unmarshal([]byte(stdout[strings.Index(stdout, "{"):]), &meta); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	expected := PageMeta{
+		Title:       "Launch Day",
+		Description: "We shipped it",
+		Canonical:   testServerURL + "/article",
+		Lang:        "en",
+		Dir:         "ltr",
+		Charset:     "UTF-8",
+		OpenGraph: map[string]interface{}{
+			"title": "Launch Day",
+			"image": []interface{}{"https://example.com/a.png", "https://example.com/b.png"},
+		},
+		Twitter: map[string]interface{}{"card": "summary_large_image"},
+		JSONLD:  []interface{}{map[string]interface{}{"@type": "Article", "headline": "Launch Day"}},
+	}
+	if !reflect.DeepEqual(meta, expected) {
+		t.Errorf("meta = %+v, expected %+v", meta, expected)
+	}
+}
+
+func TestListForms(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/contact", "--list-forms")
+	if err != nil {
+		t.Fatalf("List forms test failed: %v\nStderr: %s", err, stderr)
+	}
+
+	var forms []FormInfo
+	if err := json.Unmarshal([]byte(stdout), &forms); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	expected := []FormInfo{{
+		ID:     "contact",
+		Action: testServerURL + "/contact",
+		Method: "GET",
+		Fields: []FormField{
+			{Name: "f_x81", ID: "f-x81", Type: "email", Label: "Email address"},
+			{Name: "f_x82", Type: "textarea", Label: "Message"},
+		},
+		Buttons: []FormButton{{Text: "Send", Type: "submit"}},
+	}}
+	if !reflect.DeepEqual(forms, expected) {
+		t.Errorf("forms = %+v, expected %+v", forms, expected)
+	}
+}
+
+func TestElements(t *testing.T) {
+	setupTest(t)
+
+	screenshotFile := fmt.Sprintf("test-elements-%d.png", time.Now().UnixNano())
+	defer os.Remove(screenshotFile)
+
+	stdout, stderr, err := runWeb(testServerURL+"/contact", "--elements", "--json", "--screenshot", screenshotFile)
+	if err != nil {
+		t.Fatalf("Elements test failed: %v\nStderr: %s", err, stderr)
+	}
+	if _, err := os.Stat(screenshotFile); err != nil {
+		t.Errorf("Expected annotated screenshot: %v", err)
+	}
+
+	var elements []Element
+	if err := json.Unmarshal([]byte(stdout[strings.Index(stdout, "["):]), &elements); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	expected := []struct{ role, text, selector string }{
+		{"textbox", "Email address *", "#f-x81"},
+		{"textbox", "Message", `textarea[name="f_x82"]`},
+		{"button", "Send", "#contact > button"},
+	}
+	if len(elements) != len(expected) {
+		t.Fatalf("Expected %d elements, got %+v", len(expected), elements)
+	}
+	for i, element := range elements {
+		if element.Index != i+1 || element.Role != expected[i].role || element.Text != expected[i].text || element.Selector != expected[i].selector {
+			t.Errorf("element %d = %+v, expected %+v", i+1, element, expected[i])
+		}
+		if element.Box.Width == 0 || !element.InViewport {
+			t.Errorf("element %d should be on screen: %+v", i+1, element)
+		}
+	}
+}
+
+func TestSavePage(t *testing.T) {
+	setupTest(t)
+
+	pageFile := fmt.Sprintf("test-page-%d.html", time.Now().UnixNano())
+	defer os.Remove(pageFile)
+
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--save-page", pageFile)
+	if err != nil {
+		t.Fatalf("Save page failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Page saved to "+pageFile) {
+		t.Errorf("Expected save message. Got:\n%s", stdout)
+	}
+
+	data, err := os.ReadFile(pageFile)
+	if err != nil {
+		t.Fatalf("Saved page not written: %v", err)
+	}
+	page := string(data)
+	for _, expected := range []string{">Rendered</h1>", `value="typed"`, "data:text/css;base64,"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected %s in saved page. Got:\n%s", expected, page)
+		}
+	}
+	if strings.Contains(page, "<script") {
+		t.Errorf("Saved page should not contain scripts. Got:\n%s", page)
+	}
+}
+
+func TestWARC(t *testing.T) {
+	setupTest(t)
+
+	warcFile := fmt.Sprintf("test-capture-%d.warc", time.Now().UnixNano())
+	defer os.Remove(warcFile)
+
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--warc", warcFile)
+	if err != nil {
+		t.Fatalf("WARC capture failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "WARC saved to "+warcFile) {
+		t.Errorf("Expected save message. Got:\n%s", stdout)
+	}
+
+	data, err := os.ReadFile(warcFile)
+	if err != nil {
+		t.Fatalf("WARC not written: %v", err)
+	}
+	warc := string(data)
+	for _, expected := range []string{
+		"WARC-Target-URI: " + testServerURL + "/styled\r\n",
+		"WARC-Target-URI: " + testServerURL + "/styled.css\r\n",
+		"h1 { color: rebeccapurple }",
+	} {
+		if !strings.Contains(warc, expected) {
+			t.Errorf("Expected %q in WARC", expected)
+		}
+	}
+}
+
+func TestArchiveDir(t *testing.T) {
+	setupTest(t)
+
+	dir := t.TempDir()
+	_, stderr, err := runWeb(testServerURL+"/styled", "--archive-dir", dir)
+	if err != nil {
+		t.Fatalf("Archive failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "with 1 asset(s)") {
+		t.Errorf("Expected asset count. Got:\n%s", stderr)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("index.html not written: %v", err)
+	}
+	if !strings.Contains(string(index), `href="assets/styled.css"`) {
+		t.Errorf("Expected stylesheet link rewritten to the local copy. Got:\n%s", index)
+	}
+	css, err := os.ReadFile(filepath.Join(dir, "assets", "styled.css"))
+	if err != nil || !strings.Contains(string(css), "rebeccapurple") {
+		t.Errorf("Expected stylesheet saved to assets, got %q (%v)", css, err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	setupTest(t)
+
+	// "-" keeps progress messages off stdout so the content can be piped
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--output", "-", "--screenshot", filepath.Join(t.TempDir(), "shot.png"))
+	if err != nil {
+		t.Fatalf("Output test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.HasPrefix(stdout, "==========================") || strings.Contains(stdout, "Screenshot saved") {
+		t.Errorf("Expected only the content on stdout. Got:\n%s", stdout)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "page.json")
+	if _, stderr, err = runWeb(testServerURL+"/styled", "--output", outputFile); err != nil {
+		t.Fatalf("Output to file failed: %v\nStderr: %s", err, stderr)
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Output file not written: %v", err)
+	}
+	var page PageResult
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("Expected JSON inferred from the extension: %v\nGot: %s", err, data)
+	}
+	if page.Title != "Styled" || !strings.Contains(page.Markdown, "# Rendered") {
+		t.Errorf("Unexpected page JSON: %+v", page)
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/missing", "--headers", "--fail-on-status")
+	if err == nil {
+		t.Fatalf("Expected a non-zero exit for a 404 page. Got:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "page returned HTTP 404 Not Found") {
+		t.Errorf("Expected status error. Got stderr:\n%s", stderr)
+	}
+	// The page is still printed
+	for _, expected := range []string{"Status: 404 Not Found", "# Page not found", "RESPONSE HEADERS", "Cache-Control: no-store"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in output. Got:\n%s", expected, stdout)
+		}
+	}
+
+	// Without the proxy the status still comes from the browser
+	stdout, stderr, err = runWeb(testServerURL, "--format", "json")
+	if err != nil {
+		t.Fatalf("Response test failed: %v\nStderr: %s", err, stderr)
+	}
+	var page PageResult
+	if err := json.Unmarshal([]byte(stdout), &page); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	if page.Response.Status != 200 || page.Response.ContentType != "text/html" {
+		t.Errorf("Unexpected response: %+v", page.Response)
+	}
+}
+
+func TestNetworkFailuresSection(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/broken", "--network-failures", "--wait-for", "window.fetched")
+	if err != nil {
+		t.Fatalf("Network failures test failed: %v\nStderr: %s", err, stderr)
+	}
+	for _, expected := range []string{
+		"NETWORK FAILURES:",
+		"GET " + testServerURL + "/missing 404 Not Found (image from " + testServerURL + "/broken)",
+		"POST " + testServerURL + "/missing 404 Not Found (fetch from " + testServerURL + "/broken)",
+	} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in output. Got:\n%s", expected, stdout)
+		}
+	}
+}
+
+func TestDumpCookies(t *testing.T) {
+	setupTest(t)
+
+	cookiesFile := filepath.Join(t.TempDir(), "cookies.json")
+	stdout, stderr, err := runWeb(testServerURL+"/session", "--dump-cookies="+cookiesFile)
+	if err != nil {
+		t.Fatalf("Dump cookies test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "COOKIES:") || !strings.Contains(stdout, "session_id (") || strings.Contains(stdout, "abc123") {
+		t.Errorf("Expected the cookie listed without its value. Got:\n%s", stdout)
+	}
+
+	data, err := os.ReadFile(cookiesFile)
+	if err != nil {
+		t.Fatalf("Cookies file not written: %v", err)
+	}
+	var cookies []Cookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		t.Fatalf("Expected JSON cookies: %v\nGot: %s", err, data)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "abc123" || !cookies[0].HTTPOnly || cookies[0].SameSite != "Lax" {
+		t.Errorf("Unexpected cookies: %+v", cookies)
+	}
+}
+
+func TestFrontMatterOutput(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--front-matter", "--output", "-")
+	if err != nil {
+		t.Fatalf("Front matter test failed: %v\nStderr: %s", err, stderr)
+	}
+	for _, expected := range []string{"---\nurl: " + testServerURL + "/styled\n", "title: Styled\n", "status: 200\n", "---\n\n# Rendered"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("Expected %q in output. Got:\n%s", expected, stdout)
+		}
+	}
+	if !strings.HasPrefix(stdout, "---\n") || strings.Contains(stdout, "==========================") {
+		t.Errorf("Expected front matter in place of the banner. Got:\n%s", stdout)
+	}
+}
+
+func TestStripBoilerplate(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/chrome", "--strip-boilerplate", "--remove", ".share", "--links")
+	if err != nil {
+		t.Fatalf("Strip boilerplate test failed: %v\nStderr: %s", err, stderr)
+	}
+	// Only the content is stripped, the page is put back together for --links
+	if !strings.Contains(stdout, testServerURL+"/docs") {
+		t.Errorf("Expected navigation links to be restored. Got:\n%s", stdout)
+	}
+
+	stdout, stderr, err = runWeb(testServerURL+"/chrome", "--strip-boilerplate", "--remove", ".share")
+	if err != nil {
+		t.Fatalf("Strip boilerplate test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "# Release Notes") || !strings.Contains(stdout, "Version 2 is out.") {
+		t.Errorf("Expected the article to be kept. Got:\n%s", stdout)
+	}
+	for _, removed := range []string{"Skip to content", "Docs", "Related posts", "Copyright", "We use cookies", "Share this"} {
+		if strings.Contains(stdout, removed) {
+			t.Errorf("Expected %q to be stripped. Got:\n%s", removed, stdout)
+		}
+	}
+
+	if _, stderr, err = runWeb(testServerURL+"/chrome", "--remove", "div["); err == nil || !strings.Contains(stderr, "--remove: invalid selector div[") {
+		t.Errorf("Expected invalid selector error, got %v\nStderr: %s", err, stderr)
+	}
+}
+
+func TestCharsetAndLanguage(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/latin1", "--format", "json")
+	if err != nil {
+		t.Fatalf("Charset test failed: %v\nStderr: %s", err, stderr)
+	}
+	var page PageResult
+	if err := json.Unmarshal([]byte(stdout), &page); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	if !strings.Contains(page.Markdown, "Café crème") || page.Charset != "ISO-8859-1" || page.Lang != "fr" {
+		t.Errorf("Expected the ISO-8859-1 page decoded. Got: %+v", page)
+	}
+
+	// Raw output is UTF-8, so it has to say so
+	stdout, stderr, err = runWeb(testServerURL+"/latin1", "--raw")
+	if err != nil {
+		t.Fatalf("Charset test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, `<meta charset="utf-8">`) || !strings.Contains(stdout, "Café crème") {
+		t.Errorf("Expected UTF-8 raw output. Got:\n%s", stdout)
+	}
+
+	stdout, stderr, err = runWeb(testServerURL+"/rtl", "--format", "json", "--truncate-after", "30")
+	if err != nil {
+		t.Fatalf("Language test failed: %v\nStderr: %s", err, stderr)
+	}
+	page = PageResult{}
+	if err := json.Unmarshal([]byte(stdout), &page); err != nil {
+		t.Fatalf("Expected JSON output: %v\nGot: %s", err, stdout)
+	}
+	if page.Lang != "ar" || page.Dir != "rtl" || !strings.HasPrefix(page.Markdown, "# مرحبا بالعالم") {
+		t.Errorf("Expected Arabic page details. Got: %+v", page)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--chunk", "100", "--chunk-index", "1")
+	if err != nil {
+		t.Fatalf("Chunk test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "<!-- chunk 1 of 1 -->\n\n# Rendered") {
+		t.Errorf("Expected the first chunk. Got:\n%s", stdout)
+	}
+
+	_, stderr, err = runWeb(testServerURL+"/styled", "--chunk", "100", "--chunk-index", "2")
+	if err == nil || !strings.Contains(stderr, "--chunk-index 2 is out of range") {
+		t.Errorf("Expected out of range error. Stderr: %s", stderr)
+	}
+}
+
+func TestTruncateTokens(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL, "--truncate-tokens", "5", "--tokenizer", "estimate")
+	if err != nil {
+		t.Fatalf("Token truncation failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "Tokens: ~") || !strings.Contains(stdout, "(output truncated after 5 tokens") {
+		t.Errorf("Expected token count header and truncation notice. Got:\n%s", stdout)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/article", "--grep", "Launch")
+	if err != nil {
+		t.Fatalf("Grep test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "1: # Launch Day") {
+		t.Errorf("Expected the matching line. Got:\n%s", stdout)
+	}
+}
+
+func TestDiffDOM(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/styled", "--js", "document.getElementById('heading').textContent = 'Changed'", "--diff-dom")
+	if err != nil {
+		t.Fatalf("DOM diff test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "DOM DIFF:") || !strings.Contains(stdout, "-# Rendered\n+# Changed") {
+		t.Errorf("Expected the heading change in the DOM diff. Got:\n%s", stdout)
+	}
+}
+
+func TestSearchJSON(t *testing.T) {
+	setupTest(t)
+	searchEngines["local"] = SearchEngine{
+		URL:             testServerURL + "/search-results?q=%s",
+		ResultSelector:  ".result",
+		TitleSelector:   "a",
+		SnippetSelector: ".snippet",
+	}
+	defer delete(searchEngines, "local")
+
+	// The fetched page warns about its broken JSON-LD, which must stay out of stdout
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(read)
+		output <- data
+	}()
+	code := runSearch([]string{"launch", "--engine", "local", "--fetch", "1", "--json", "--profile", testProfile})
+	os.Stdout = stdout
+	write.Close()
+	data := <-output
+	if code != 0 {
+		t.Fatalf("Search exited with %d. Stdout:\n%s", code, data)
+	}
+
+	var results []SearchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("Expected stdout to be JSON: %v\n%s", err, data)
+	}
+	if len(results) != 2 || results[0].Title != "Launch Day" || results[0].URL != testServerURL+"/article" {
+		t.Fatalf("Expected both results. Got: %+v", results)
+	}
+	if !strings.Contains(results[0].Content, "# Launch Day") || results[1].Content != "" {
+		t.Errorf("Expected the content of the first result only. Got: %+v", results)
+	}
+}
+
+func TestProgressMessagesOnStderr(t *testing.T) {
+	setupTest(t)
+
+	stdout, stderr, err := runWeb(testServerURL+"/liveview", "--truncate-after", "300")
+	if err != nil {
+		t.Fatalf("Progress message test failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "LiveView") {
+		t.Errorf("Expected the page content on stdout. Got:\n%s", stdout)
+	}
+	for _, message := range []string{"Detected Phoenix LiveView page", "Phoenix LiveView connected"} {
+		if strings.Contains(stdout, message) {
+			t.Errorf("Expected %q to stay out of stdout. Got:\n%s", message, stdout)
+		}
+		if !strings.Contains(stderr, message) {
+			t.Errorf("Expected %q on stderr. Got:\n%s", message, stderr)
+		}
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	setupTest(t)
+
+	// --http-only warns that it reads the Latin-1 page as UTF-8
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<p>Caf\xe9</p>"))
+	}))
+	defer server.Close()
+
+	loud, stderr, err := runWeb(server.URL, "--http-only")
+	if err != nil {
+		t.Fatalf("Latin-1 run failed: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stderr, "Warning: The page is encoded as iso-8859-1") || strings.Contains(loud, "Warning") {
+		t.Errorf("Expected the warning on stderr only.\nStdout: %s\nStderr: %s", loud, stderr)
+	}
+
+	stdout, stderr, err := runWeb(server.URL, "--http-only", "--quiet")
+	if err != nil {
+		t.Fatalf("Quiet run failed: %v\nStderr: %s", err, stderr)
+	}
+	if stdout != loud {
+		t.Errorf("Expected --quiet to leave stdout alone.\nWithout: %s\nWith: %s", loud, stdout)
+	}
+	if stderr != "" {
+		t.Errorf("Expected --quiet to leave out progress messages and warnings. Got:\n%s", stderr)
+	}
+
+	// Errors still show
+	_, stderr, err = runWeb("http://localhost:1", "--http-only", "--quiet")
+	if err == nil || !strings.Contains(stderr, "Error") {
+		t.Errorf("Expected the error on stderr with --quiet. Got: %v\n%s", err, stderr)
+	}
+}
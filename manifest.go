@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// Manifest records the provenance of a run for --manifest
+type Manifest struct {
+	URL        string           `json:"url"`
+	FinalURL   string           `json:"final_url,omitempty"`
+	Profile    string           `json:"profile"`
+	Args       []string         `json:"args"`
+	Browser    string           `json:"browser,omitempty"`
+	Version    string           `json:"browser_version,omitempty"`
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	DurationMS int64            `json:"duration_ms"`
+	Timings    []ManifestTiming `json:"timings"`
+	// Retries counts the attempts --retries made over
+	Retries int            `json:"retries,omitempty"`
+	Files   []ManifestFile `json:"files"`
+	Output  *ManifestFile  `json:"output,omitempty"`
+	Error   string         `json:"error,omitempty"`
+
+	lastMark time.Time
+}
+
+// ManifestTiming is the wall time spent in one phase of the run
+type ManifestTiming struct {
+	Phase      string `json:"phase"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// ManifestFile describes an artifact written by the run
+type ManifestFile struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path,omitempty"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// sensitiveName matches form field and flag names whose values must not be written to disk
+var sensitiveName = regexp.MustCompile(`(?i)pass|secret|token|key|auth|session|cookie|credential|otp|pin`)
+
+// secretFlags always have their value masked regardless of name
+var secretFlags = map[string]bool{}
+
+func newManifest(config Config, args []string) *Manifest {
+	now := time.Now()
+	return &Manifest{
+		URL:       ensureProtocol(config.URL),
+		Profile:   config.Profile,
+		Args:      maskArgs(args),
+		StartedAt: now,
+		lastMark:  now,
+	}
+}
+
+// mark records the time since the previous mark as the duration of phase
+func (m *Manifest) mark(phase string) {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	m.Timings = append(m.Timings, ManifestTiming{Phase: phase, DurationMS: now.Sub(m.lastMark).Milliseconds()})
+	m.lastMark = now
+}
+
+// retried counts a failed attempt that --retries made again
+func (m *Manifest) retried() {
+	if m == nil {
+		return
+	}
+	m.Retries++
+}
+
+// addFile registers an artifact to be hashed when the manifest is written
+func (m *Manifest) addFile(kind, path string) {
+	if m == nil {
+		return
+	}
+	m.Files = append(m.Files, ManifestFile{Kind: kind, Path: path})
+}
+
+// recordBrowser stores the browser name and version reported by the WebDriver session
+func (m *Manifest) recordBrowser(wd selenium.WebDriver) {
+	if m == nil {
+		return
+	}
+	caps, err := wd.Capabilities()
+	if err != nil {
+		return
+	}
+	m.Browser, _ = caps["browserName"].(string)
+	m.Version, _ = caps["browserVersion"].(string)
+}
+
+// write finalizes timings, hashes every artifact and saves the manifest as JSON
+func (m *Manifest) write(path, output string, runErr error) error {
+	m.FinishedAt = time.Now()
+	m.DurationMS = m.FinishedAt.Sub(m.StartedAt).Milliseconds()
+	if runErr != nil {
+		m.Error = runErr.Error()
+	}
+
+	for i, file := range m.Files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		m.Files[i].Bytes = int64(len(data))
+		m.Files[i].SHA256 = hex.EncodeToString(sum[:])
+	}
+
+	sum := sha256.Sum256([]byte(output))
+	m.Output = &ManifestFile{Kind: "stdout", Bytes: int64(len(output)), SHA256: hex.EncodeToString(sum[:])}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write manifest: %v", err)
+	}
+	return nil
+}
+
+// maskArgs copies the command line with secrets replaced: values of sensitive
+// form inputs, values of secret flags and passwords embedded in URLs
+func maskArgs(args []string) []string {
+	masked := make([]string, len(args))
+	sensitiveInput := false
+
+	maskValue := func(flag, value string) string {
+		switch {
+		case flag == "--input" || flag == "--input-label":
+			sensitiveInput = sensitiveName.MatchString(value)
+		case flag == "--var":
+			if name, _, ok := strings.Cut(value, "="); ok && sensitiveName.MatchString(name) {
+				return name + "=********"
+			}
+		case flag == "--value" && sensitiveInput, secretFlags[flag]:
+			return "********"
+		}
+		return maskURLPassword(value)
+	}
+
+	for i, arg := range args {
+		if name, value, inline := strings.Cut(arg, "="); inline && strings.HasPrefix(name, "--") {
+			masked[i] = name + "=" + maskValue(name, value)
+		} else if i > 0 && strings.HasPrefix(args[i-1], "--") && !strings.Contains(args[i-1], "=") {
+			masked[i] = maskValue(args[i-1], arg)
+		} else {
+			masked[i] = maskURLPassword(arg)
+		}
+	}
+
+	return masked
+}
+
+func maskURLPassword(arg string) string {
+	u, err := url.Parse(arg)
+	if err != nil || u.User == nil {
+		return arg
+	}
+	return u.Redacted()
+}
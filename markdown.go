@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	whitespaceRun = regexp.MustCompile(`[ \t\r\n\f]+`)
+	languageClass = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#.-]+)`)
+)
+
+// blockElements start their own paragraph; everything else flows inline
+var blockElements = map[atom.Atom]bool{
+	atom.Html: true, atom.Body: true, atom.Main: true, atom.Article: true, atom.Section: true,
+	atom.Header: true, atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Div: true,
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Pre: true, atom.Blockquote: true,
+	atom.Table: true, atom.Hr: true, atom.Form: true, atom.Fieldset: true, atom.Figure: true,
+	atom.Figcaption: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Address: true,
+	atom.Details: true, atom.Summary: true, atom.Legend: true,
+}
+
+// skippedElements never contribute text
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Object: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true,
+}
+
+// htmlToMarkdown converts an HTML document or fragment to markdown, keeping
+// heading levels, [text](url) links, pipe tables and fenced code blocks
+func htmlToMarkdown(content string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(renderBlocks(doc, "\n\n")), nil
+}
+
+// renderBlocks renders the children of n, grouping runs of inline content into
+// paragraphs and joining paragraphs and child blocks with separator
+func renderBlocks(n *html.Node, separator string) string {
+	var blocks []string
+	var inline strings.Builder
+
+	flush := func() {
+		if paragraph := tidyParagraph(inline.String()); paragraph != "" {
+			blocks = append(blocks, paragraph)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (skippedElements[child.DataAtom] || isHidden(child)) {
+			continue
+		}
+		if child.Type == html.ElementNode && blockElements[child.DataAtom] {
+			flush()
+			if block := renderBlock(child); block != "" {
+				blocks = append(blocks, block)
+			}
+			continue
+		}
+		inline.WriteString(renderInline(child))
+	}
+	flush()
+
+	return strings.Join(blocks, separator)
+}
+
+func renderBlock(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := tidyParagraph(strings.ReplaceAll(inlineText(n), "\n", " "))
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.Ul, atom.Ol:
+		return renderList(n)
+	case atom.Pre:
+		return renderCode(n)
+	case atom.Blockquote:
+		return prefixLines(renderBlocks(n, "\n\n"), "> ")
+	case atom.Table:
+		return renderTable(n)
+	case atom.Hr:
+		return "---"
+	default:
+		return renderBlocks(n, "\n\n")
+	}
+}
+
+func renderList(n *html.Node) string {
+	number := 1
+	if start, err := strconv.Atoi(getAttr(n, "start")); err == nil {
+		number = start
+	}
+
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || isHidden(li) {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		content := renderBlocks(li, "\n")
+		if li.DataAtom != atom.Li {
+			// Stray content such as a nested list directly in the list
+			if content != "" {
+				items = append(items, prefixLines(content, "  "))
+			}
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.TrimPrefix(prefixLines(content, indent), indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func renderCode(n *html.Node) string {
+	code := strings.Trim(rawText(n), "\n")
+	if code == "" {
+		return ""
+	}
+
+	language := codeLanguage(n)
+	for child := n.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.DataAtom == atom.Code {
+			language = codeLanguage(child)
+		}
+	}
+
+	// The fence must be longer than any backtick run in the code
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *html.Node) string {
+	if lang := getAttr(n, "data-lang"); lang != "" {
+		return lang
+	}
+	if match := languageClass.FindStringSubmatch(getAttr(n, "class")); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func renderTable(n *html.Node) string {
+	var rows [][]string
+	headerRow := false
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(child)
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+						continue
+					}
+					if len(rows) == 0 && (cell.DataAtom == atom.Th || n.DataAtom == atom.Thead) {
+						headerRow = true
+					}
+					text := tidyParagraph(strings.ReplaceAll(inlineText(cell), "\n", " "))
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	// Markdown tables need a header, so headerless tables get an empty one
+	if !headerRow {
+		rows = append([][]string{make([]string, columns)}, rows...)
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", columns) + "|\n")
+		}
+	}
+
+	if caption := findChild(n, atom.Caption); caption != nil {
+		if text := tidyParagraph(inlineText(caption)); text != "" {
+			return text + "\n\n" + strings.TrimRight(b.String(), "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderInline renders n as inline markdown, with whitespace collapsed
+func renderInline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return whitespaceRun.ReplaceAllString(n.Data, " ")
+	}
+	if n.Type != html.ElementNode || skippedElements[n.DataAtom] || isHidden(n) {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Img:
+		alt := strings.TrimSpace(getAttr(n, "alt"))
+		src := getAttr(n, "src")
+		if alt == "" {
+			return ""
+		}
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return alt
+		}
+		return fmt.Sprintf("![%s](%s)", alt, src)
+	case atom.A:
+		text := inlineText(n)
+		href := strings.TrimSpace(getAttr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") || strings.TrimSpace(text) == "" {
+			return text
+		}
+		return wrapInline(strings.ReplaceAll(text, "\n", " "), "[", "]("+href+")")
+	case atom.Strong, atom.B:
+		return wrapInline(inlineText(n), "**", "**")
+	case atom.Em, atom.I:
+		return wrapInline(inlineText(n), "_", "_")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(inlineText(n), "~~", "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := whitespaceRun.ReplaceAllString(rawText(n), " ")
+		if strings.Contains(code, "`") {
+			return wrapInline(code, "`` ", " ``")
+		}
+		return wrapInline(code, "`", "`")
+	}
+
+	if blockElements[n.DataAtom] {
+		// A block inside inline content (e.g. a div in a link) still breaks the line
+		return "\n" + inlineText(n) + "\n"
+	}
+	return inlineText(n)
+}
+
+func inlineText(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(renderInline(child))
+	}
+	return b.String()
+}
+
+// wrapInline surrounds text with markers, keeping surrounding spaces outside
+// them since "** bold **" isn't emphasis in markdown
+func wrapInline(text, open, close string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	leading := text[:strings.Index(text, trimmed)]
+	trailing := text[len(leading)+len(trimmed):]
+	return leading + open + trimmed + close + trailing
+}
+
+// rawText is the text content of n with whitespace preserved, for code
+func rawText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom == atom.Br {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(rawText(child))
+	}
+	return b.String()
+}
+
+// tidyParagraph trims each line of collapsed inline content and drops empty ones
+func tidyParagraph(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(whitespaceRun.ReplaceAllString(line, " ")); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixLines(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isHidden(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == "hidden" || (attr.Key == "aria-hidden" && attr.Val == "true") {
+			return true
+		}
+	}
+	return false
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func findChild(n *html.Node, a atom.Atom) *html.Node {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom == a {
+			return child
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	cases := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			"headings and paragraphs",
+			`<h1>Title</h1><p>Some  <strong>bold</strong> and <em>italic </em>text.</p><h3>Details</h3><p>Line one<br>Line two</p>`,
+			"# Title\n\nSome **bold** and _italic_ text.\n\n### Details\n\nLine one\nLine two",
+		},
+		{
+			"links",
+			`<p>Read the <a href="https://hexdocs.pm/phoenix">Phoenix docs</a> or <a href="javascript:void(0)">this</a>.</p>`,
+			"Read the [Phoenix docs](https://hexdocs.pm/phoenix) or this.",
+		},
+		{
+			"lists",
+			`<ul><li>One</li><li>Two<ul><li>Nested</li></ul></li></ul><ol start="3"><li>Three</li><li>Four</li></ol>`,
+			"- One\n- Two\n  - Nested\n\n3. Three\n4. Four",
+		},
+		{
+			"code blocks",
+			"<p>Run <code>mix test</code>:</p><pre><code class=\"language-elixir\">defmodule Foo do\n  def bar, do: :ok\nend\n</code></pre>",
+			"Run `mix test`:\n\n```elixir\ndefmodule Foo do\n  def bar, do: :ok\nend\n```",
+		},
+		{
+			"tables",
+			`<table><thead><tr><th>Option</th><th>Default</th></tr></thead><tbody><tr><td><code>--raw</code></td><td>false</td></tr><tr><td>a|b</td></tr></tbody></table>`,
+			"| Option | Default |\n| --- | --- |\n| `--raw` | false |\n| a\\|b |  |",
+		},
+		{
+			"headerless tables",
+			`<table><tr><td>1</td><td>2</td></tr></table>`,
+			"|  |  |\n| --- | --- |\n| 1 | 2 |",
+		},
+		{
+			"blockquotes and skipped content",
+			`<head><title>Ignored</title></head><script>var x = 1;</script><blockquote><p>Quoted</p><p>Twice</p></blockquote><div hidden>Secret</div>`,
+			"> Quoted\n>\n> Twice",
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := htmlToMarkdown(tc.html)
+		if err != nil {
+			t.Errorf("%s: htmlToMarkdown returned error: %v", tc.name, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("%s: htmlToMarkdown() =\n%s\nexpected\n%s", tc.name, got, tc.expected)
+		}
+	}
+}
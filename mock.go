@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MockRule answers requests matching Method and Pattern with a fixture
+// instead of letting them reach the server
+type MockRule struct {
+	// Method is the request method to answer, or "" for any
+	Method string
+	// Pattern is a glob where * matches any run of characters: a path such
+	// as /api/users/*, matched against the path and query of any host, or a
+	// whole URL
+	Pattern     string
+	Status      int
+	Body        []byte
+	ContentType string
+	Headers     map[string]string
+}
+
+// mockEntry is a rule of a --mock-file
+type mockEntry struct {
+	Match   string            `yaml:"match"`
+	Status  int               `yaml:"status,omitempty"`
+	File    string            `yaml:"file,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// parseMock parses a --mock "[METHOD] pattern=>[status] [file]" rule, e.g.
+// "GET /api/users=>fixtures/users.json" or "POST /api/login=>401"
+func parseMock(value string) (MockRule, error) {
+	match, answer, ok := strings.Cut(value, "=>")
+	if !ok {
+		return MockRule{}, fmt.Errorf("--mock expects \"GET /api/users=>fixtures/users.json\", got %q", value)
+	}
+	rule, err := parseMockMatch(match)
+	if err != nil {
+		return MockRule{}, err
+	}
+
+	fields := strings.Fields(answer)
+	if len(fields) > 0 {
+		if status, err := strconv.Atoi(fields[0]); err == nil {
+			rule.Status = status
+			fields = fields[1:]
+		}
+	}
+	switch len(fields) {
+	case 0:
+		if strings.TrimSpace(answer) == "" {
+			return MockRule{}, fmt.Errorf("--mock %q: expected a status code or fixture file after =>", value)
+		}
+	case 1:
+		if err := rule.load(fields[0]); err != nil {
+			return MockRule{}, err
+		}
+	default:
+		return MockRule{}, fmt.Errorf("--mock %q: expected a status code and a fixture file after =>", value)
+	}
+	return rule, rule.validate()
+}
+
+// parseMockMatch parses the "[METHOD] pattern" of a rule
+func parseMockMatch(match string) (MockRule, error) {
+	rule := MockRule{Status: http.StatusOK}
+	fields := strings.Fields(match)
+	if len(fields) == 2 {
+		rule.Method = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return MockRule{}, fmt.Errorf("mock match %q: expected an optional method and a path or URL", match)
+	}
+	rule.Pattern = fields[0]
+	if !strings.HasPrefix(rule.Pattern, "/") && !strings.HasPrefix(rule.Pattern, "http://") && !strings.HasPrefix(rule.Pattern, "https://") {
+		return MockRule{}, fmt.Errorf("mock match %q: the pattern must be a path starting with / or a whole http(s) URL", match)
+	}
+	return rule, nil
+}
+
+// load reads a fixture file, its type told by its extension
+func (r *MockRule) load(path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read mock fixture: %v", err)
+	}
+	r.Body = body
+	r.ContentType = mime.TypeByExtension(filepath.Ext(path))
+	if r.ContentType == "" {
+		r.ContentType = "application/octet-stream"
+	}
+	return nil
+}
+
+func (r MockRule) validate() error {
+	if r.Status < 100 || r.Status > 599 {
+		return fmt.Errorf("mock %s: status %d is not an HTTP status code", r.Pattern, r.Status)
+	}
+	return nil
+}
+
+// readMockFile loads a --mock-file: a YAML or JSON list of rules, each with
+// a match ("GET /api/users"), and optionally a status, headers and a body,
+// inline or from a file relative to the mock file
+func readMockFile(path string) ([]MockRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mock file: %v", err)
+	}
+	var entries []mockEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse mock file %s: %v", path, err)
+	}
+
+	var rules []MockRule
+	for i, entry := range entries {
+		rule, err := parseMockMatch(entry.Match)
+		if err != nil {
+			return nil, fmt.Errorf("mock %d: %v", i+1, err)
+		}
+		if entry.Status != 0 {
+			rule.Status = entry.Status
+		}
+		switch {
+		case entry.File != "" && entry.Body != "":
+			return nil, fmt.Errorf("mock %d: give a file or a body, not both", i+1)
+		case entry.File != "":
+			file := entry.File
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(path), file)
+			}
+			if err := rule.load(file); err != nil {
+				return nil, fmt.Errorf("mock %d: %v", i+1, err)
+			}
+		case entry.Body != "":
+			rule.Body = []byte(entry.Body)
+			rule.ContentType = "text/plain; charset=utf-8"
+			if json := strings.TrimSpace(entry.Body); strings.HasPrefix(json, "{") || strings.HasPrefix(json, "[") {
+				rule.ContentType = "application/json"
+			}
+		}
+		rule.Headers = entry.Headers
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("mock %d: %v", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matches reports whether the rule answers a request for u, leaving out the
+// method
+func (r MockRule) matches(u *url.URL) bool {
+	target := u.String()
+	if strings.HasPrefix(r.Pattern, "/") {
+		target = u.EscapedPath()
+		if strings.Contains(r.Pattern, "?") {
+			target = u.RequestURI()
+		}
+	}
+	return globRegexp(r.Pattern).MatchString(target)
+}
+
+// isPreflight reports whether req is a CORS preflight, asking whether the
+// request it describes may be made
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// findMock returns the rule answering a request for u, if any. A CORS
+// preflight is answered for a rule that answers the request it asks about.
+func findMock(rules []MockRule, req *http.Request, u *url.URL) (MockRule, bool) {
+	method := req.Method
+	if isPreflight(req) {
+		method = req.Header.Get("Access-Control-Request-Method")
+	}
+	for _, rule := range rules {
+		if (rule.Method == "" || rule.Method == method) && rule.matches(u) {
+			return rule, true
+		}
+	}
+	return MockRule{}, false
+}
+
+// mockResponse is the rule's answer to req. Cross-origin requests are
+// allowed, as the real API would have to for the page to use it.
+func mockResponse(rule MockRule, req *http.Request) (*http.Response, []byte) {
+	header := http.Header{}
+	if origin := req.Header.Get("Origin"); origin != "" {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Set("Access-Control-Allow-Credentials", "true")
+		header.Set("Vary", "Origin")
+	}
+	if isPreflight(req) {
+		header.Set("Access-Control-Allow-Methods", req.Header.Get("Access-Control-Request-Method"))
+		if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			header.Set("Access-Control-Allow-Headers", headers)
+		}
+		return &http.Response{Status: "204 No Content", StatusCode: http.StatusNoContent, Header: header, Body: http.NoBody}, []byte{}
+	}
+
+	if rule.ContentType != "" {
+		header.Set("Content-Type", rule.ContentType)
+	}
+	for name, value := range rule.Headers {
+		header.Set(name, value)
+	}
+	body := rule.Body
+	if body == nil {
+		body = []byte{}
+	}
+	status := fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status))
+	return &http.Response{Status: status, StatusCode: rule.Status, Header: header, Body: http.NoBody}, body
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMock(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "users.json")
+	os.WriteFile(fixture, []byte(`[{"id":1}]`), 0644)
+
+	rule, err := parseMock("get /api/users/*=>" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Method != "GET" || rule.Status != 200 || string(rule.Body) != `[{"id":1}]` || rule.ContentType != "application/json" {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+	cases := map[string]bool{
+		"https://example.com/api/users/1":         true,
+		"http://localhost:4000/api/users/2?x=1":   true,
+		"https://example.com/api/users":           false,
+		"https://example.com/v2/api/users/1":      false,
+		"https://example.com/app?next=/api/users": false,
+	}
+	for input, expected := range cases {
+		u, _ := url.Parse(input)
+		if got := rule.matches(u); got != expected {
+			t.Errorf("matches(%q) = %v, expected %v", input, got, expected)
+		}
+	}
+
+	rule, err = parseMock("POST https://api.example.com/login=>401")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Status != 401 || rule.Body != nil {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+
+	for _, value := range []string{"/api/users", "/api=>", "api/users=>200", "/api=>999", "/api=>200 a.json b.json", "/api=>missing.json"} {
+		if _, err := parseMock(value); err == nil {
+			t.Errorf("Expected --mock %q to be rejected", value)
+		}
+	}
+}
+
+func TestReadMockFile(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "fixtures"), 0755)
+	os.WriteFile(filepath.Join(dir, "fixtures", "users.json"), []byte(`[]`), 0644)
+	path := filepath.Join(dir, "mocks.yml")
+	os.WriteFile(path, []byte(`
+- match: GET /api/users
+  file: fixtures/users.json
+- match: POST /api/login
+  status: 401
+  body: '{"error": "bad password"}'
+  headers:
+    X-Request-Id: abc
+`), 0644)
+
+	rules, err := readMockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %+v", rules)
+	}
+	if string(rules[0].Body) != "[]" || rules[0].Status != 200 {
+		t.Errorf("Unexpected rule %+v", rules[0])
+	}
+	if rules[1].Status != 401 || rules[1].ContentType != "application/json" || rules[1].Headers["X-Request-Id"] != "abc" {
+		t.Errorf("Unexpected rule %+v", rules[1])
+	}
+
+	os.WriteFile(path, []byte("- match: GET /api\n  file: nope.json\n"), 0644)
+	if _, err := readMockFile(path); err == nil {
+		t.Error("Expected a missing fixture to be rejected")
+	}
+}
+
+func TestProxyMock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "server")
+	}))
+	defer server.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.record(true)
+	proxy.mock([]MockRule{{Method: "GET", Pattern: "/api/*", Status: 201, Body: []byte(`{"ok":true}`), ContentType: "application/json"}})
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	req, _ := http.NewRequest("GET", server.URL+"/api/users", nil)
+	req.Header.Set("Origin", "http://app.example.test")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || string(body) != `{"ok":true}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the fixture, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Access-Control-Allow-Origin") != "http://app.example.test" {
+		t.Errorf("Expected the origin to be allowed, got %v", resp.Header)
+	}
+
+	// The preflight for a mocked request is answered too
+	req, _ = http.NewRequest("OPTIONS", server.URL+"/api/users", nil)
+	req.Header.Set("Origin", "http://app.example.test")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("OPTIONS through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 204 || resp.Header.Get("Access-Control-Allow-Methods") != "GET" {
+		t.Errorf("Expected the preflight to be allowed, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// Anything else still reaches the server
+	resp, err = client.Post(server.URL+"/api/users", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("POST through proxy failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "server" {
+		t.Errorf("Expected the POST to reach the server, got %q", body)
+	}
+
+	if exchanges := proxy.Exchanges(); len(exchanges) != 3 || exchanges[0].StatusCode != 201 || string(exchanges[0].ResponseBody) != `{"ok":true}` {
+		t.Errorf("Expected the mocked response to be recorded, got %+v", exchanges)
+	}
+}
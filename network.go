@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NetworkFailure is a request that errored or got a 4xx/5xx response
+type NetworkFailure struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Initiator is what kind of request it was (document, script, fetch...)
+	// and the page that made it
+	Initiator string `json:"initiator,omitempty"`
+}
+
+// networkFailures picks the failed requests out of the recorded traffic, in
+// the order they were made
+func networkFailures(exchanges []*Exchange) []NetworkFailure {
+	var failures []NetworkFailure
+	for _, exchange := range exchanges {
+		if exchange.Err == nil && exchange.StatusCode < 400 {
+			continue
+		}
+		failure := NetworkFailure{
+			Method:    exchange.Method,
+			URL:       exchange.URL,
+			Status:    exchange.StatusCode,
+			Initiator: initiator(exchange.RequestHeader),
+		}
+		if exchange.Err != nil {
+			failure.Error = exchange.Err.Error()
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// initiator describes who made a request from the headers the browser
+// sends: its destination (fetch and XHR are "empty") and referring page
+func initiator(header http.Header) string {
+	kind := header.Get("Sec-Fetch-Dest")
+	if kind == "empty" {
+		kind = "fetch"
+	}
+	referer := header.Get("Referer")
+	switch {
+	case kind != "" && referer != "":
+		return kind + " from " + referer
+	case referer != "":
+		return "from " + referer
+	default:
+		return kind
+	}
+}
+
+func formatNetworkFailures(failures []NetworkFailure) string {
+	var b strings.Builder
+	for _, failure := range failures {
+		outcome := failure.Error
+		if failure.Status > 0 {
+			outcome = fmt.Sprintf("%d %s", failure.Status, http.StatusText(failure.Status))
+		}
+		fmt.Fprintf(&b, "%s %s %s", failure.Method, failure.URL, outcome)
+		if failure.Initiator != "" {
+			fmt.Fprintf(&b, " (%s)", failure.Initiator)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
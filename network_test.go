@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestNetworkFailures(t *testing.T) {
+	header := func(dest, referer string) http.Header {
+		h := http.Header{}
+		if dest != "" {
+			h.Set("Sec-Fetch-Dest", dest)
+		}
+		if referer != "" {
+			h.Set("Referer", referer)
+		}
+		return h
+	}
+	exchanges := []*Exchange{
+		{Method: "GET", URL: "https://example.com/", RequestHeader: header("document", ""), StatusCode: 200},
+		{Method: "GET", URL: "https://example.com/app.css", RequestHeader: header("style", "https://example.com/"), StatusCode: 404},
+		{Method: "POST", URL: "https://example.com/api", RequestHeader: header("empty", "https://example.com/"), StatusCode: 500},
+		{Method: "GET", URL: "https://cdn.invalid/lib.js", RequestHeader: header("", ""), Err: errors.New("no such host")},
+		{Method: "GET", URL: "https://example.com/slow", RequestHeader: header("empty", "")},
+	}
+
+	got := formatNetworkFailures(networkFailures(exchanges))
+	expected := `GET https://example.com/app.css 404 Not Found (style from https://example.com/)
+POST https://example.com/api 500 Internal Server Error (fetch from https://example.com/)
+GET https://cdn.invalid/lib.js no such host
+`
+	if got != expected {
+		t.Errorf("network failures =\n%s\nexpected\n%s", got, expected)
+	}
+}
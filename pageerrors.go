@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// pageErrorsJS records exceptions the page doesn't catch and promise
+// rejections it doesn't handle. Like console capture it is installed by
+// preparePage, so errors thrown while the document is still parsing are missed.
+const pageErrorsJS = `
+	if (!window.__pageErrors) {
+		window.__pageErrors = [];
+		var describe = function(error) {
+			if (error instanceof Error) {
+				return { message: error.toString(), stack: error.stack || '' };
+			}
+			try { return { message: JSON.stringify(error), stack: '' }; }
+			catch (e) { return { message: String(error), stack: '' }; }
+		};
+		window.addEventListener('error', function(e) {
+			// Resources failing to load bubble up as error events without a message
+			if (!e.message && !e.error) { return; }
+			var error = e.error ? describe(e.error) : { message: e.message, stack: '' };
+			window.__pageErrors.push({
+				message: 'Uncaught ' + error.message,
+				stack: error.stack,
+				source: e.filename || '',
+				line: e.lineno || 0,
+				column: e.colno || 0,
+				time: Date.now()
+			});
+		});
+		window.addEventListener('unhandledrejection', function(e) {
+			var error = describe(e.reason);
+			window.__pageErrors.push({
+				message: 'Uncaught (in promise) ' + error.message,
+				stack: error.stack,
+				source: '',
+				line: 0,
+				column: 0,
+				time: Date.now()
+			});
+		});
+	}
+`
+
+// PageError is an exception the page threw and didn't catch
+type PageError struct {
+	Message string    `json:"message"`
+	Stack   string    `json:"stack,omitempty"`
+	Source  string    `json:"source,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Time    time.Time `json:"timestamp"`
+}
+
+// collectPageErrors returns the uncaught errors recorded since the page loaded
+func collectPageErrors(wd selenium.WebDriver) []PageError {
+	raw, err := wd.ExecuteScript("return window.__pageErrors || []", nil)
+	if err != nil {
+		logf("Warning: Could not collect page errors: %v\n", err)
+		return nil
+	}
+	entries, _ := raw.([]interface{})
+
+	var pageErrors []PageError
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		message, _ := fields["message"].(string)
+		stack, _ := fields["stack"].(string)
+		source, _ := fields["source"].(string)
+		line, _ := fields["line"].(float64)
+		column, _ := fields["column"].(float64)
+		timestamp, _ := fields["time"].(float64)
+		pageErrors = append(pageErrors, PageError{
+			Message: message,
+			Stack:   strings.TrimSpace(stack),
+			Source:  source,
+			Line:    int(line),
+			Column:  int(column),
+			Time:    time.UnixMilli(int64(timestamp)),
+		})
+	}
+	return pageErrors
+}
+
+// formatPageErrors lists each error with where it was thrown and its stack,
+// one frame per indented line
+func formatPageErrors(pageErrors []PageError) string {
+	var b strings.Builder
+	for _, pageError := range pageErrors {
+		b.WriteString(pageError.Message)
+		if pageError.Source != "" && pageError.Line > 0 {
+			fmt.Fprintf(&b, " (%s:%d:%d)", pageError.Source, pageError.Line, pageError.Column)
+		}
+		b.WriteString("\n")
+		for _, frame := range strings.Split(pageError.Stack, "\n") {
+			if frame = strings.TrimSpace(frame); frame != "" {
+				fmt.Fprintf(&b, "    %s\n", frame)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestFormatPageErrors(t *testing.T) {
+	got := formatPageErrors([]PageError{
+		{
+			Message: "Uncaught TypeError: user is undefined",
+			Stack:   "render@https://example.com/app.js:40:11\nmount@https://example.com/app.js:12:3",
+			Source:  "https://example.com/app.js",
+			Line:    40,
+			Column:  11,
+		},
+		{Message: "Uncaught (in promise) \"timeout\""},
+	})
+	expected := `Uncaught TypeError: user is undefined (https://example.com/app.js:40:11)
+    render@https://example.com/app.js:40:11
+    mount@https://example.com/app.js:12:3
+Uncaught (in promise) "timeout"
+`
+	if got != expected {
+		t.Errorf("formatPageErrors =\n%s\nexpected\n%s", got, expected)
+	}
+}
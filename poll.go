@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+const (
+	DEFAULT_POLL_INTERVAL = 5 * time.Second
+	DEFAULT_POLL_TIMEOUT  = 10 * time.Minute
+
+	// Polling faster than this hammers job status endpoints for no benefit
+	MIN_POLL_INTERVAL = time.Second
+)
+
+// pollUntilText waits until the page text contains text, checking every
+// interval. Regular pages are reloaded between checks; LiveView pages are left
+// connected since the server pushes status updates to them. It returns whether
+// the page is (still) a LiveView page once polling ends.
+func pollUntilText(wd selenium.WebDriver, config Config, isLiveView bool) (bool, error) {
+	deadline := time.Now().Add(config.PollTimeout)
+
+	for attempt := 1; ; attempt++ {
+		body, err := wd.ExecuteScript("return document.body ? document.body.innerText : ''", nil)
+		if err == nil && strings.Contains(fmt.Sprint(body), config.PollText) {
+			logf("Found %q after %d check(s)\n", config.PollText, attempt)
+			return isLiveView, nil
+		}
+
+		if time.Now().Add(config.PollInterval).After(deadline) {
+			return isLiveView, fmt.Errorf("%q did not appear within %s", config.PollText, config.PollTimeout)
+		}
+
+		logf("Waiting for %q (check %d), next check in %s...\n", config.PollText, attempt, config.PollInterval)
+		time.Sleep(config.PollInterval)
+
+		if !isLiveView {
+			if err := wd.Refresh(); err != nil {
+				return isLiveView, fmt.Errorf("could not reload page: %v", err)
+			}
+			isLiveView = preparePage(wd, config)
+		}
+	}
+}
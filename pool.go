@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// workerPool holds the daemon's browsers, each in a `web daemon --worker`
+// process of its own: a run switches its process's directory, environment
+// and output, so a process can only serve one run at a time. Up to
+// cap(slots) runs are served at once, later ones wait for a browser to free up.
+type workerPool struct {
+	config      Config
+	socketPath  string
+	idleTimeout time.Duration
+	slots       chan struct{}
+	// recycleAfter is passed on to the workers, see runWorker
+	recycleAfter int
+
+	mu      sync.Mutex
+	freed   *sync.Cond
+	workers map[int]*worker
+	idle    []*worker
+	closed  bool
+	// sessions maps each --session name to the browser holding its page
+	sessions map[string]*worker
+}
+
+// worker is one browser of the pool. The first uses the daemon's profile, the
+// others a copy of it taken when they start and removed when they stop, so
+// what runs on them change in the profile isn't kept.
+type worker struct {
+	index    int
+	socket   string
+	stdin    io.Closer
+	done     chan struct{}
+	lastUsed time.Time
+}
+
+func newWorkerPool(config Config, socketPath string, size int, idleTimeout time.Duration) *workerPool {
+	p := &workerPool{
+		config:      config,
+		socketPath:  socketPath,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+		workers:     map[int]*worker{},
+		sessions:    map[string]*worker{},
+	}
+	p.freed = sync.NewCond(&p.mu)
+	return p
+}
+
+// serve hands the request on conn to a free browser, waiting for one when
+// all are busy, and relays its answer
+func (p *workerPool) serve(conn net.Conn, closeDaemon func()) {
+	defer conn.Close()
+
+	var request daemonRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		return
+	}
+	encoder := json.NewEncoder(conn)
+	fail := func(err error) {
+		code := 1
+		encoder.Encode(daemonReply{Stderr: fmt.Sprintf("Error: %v\n", err)})
+		encoder.Encode(daemonReply{Exit: &code})
+	}
+
+	if request.Stop {
+		code := 0
+		encoder.Encode(daemonReply{Exit: &code})
+		closeDaemon()
+		return
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		encoder.Encode(daemonReply{Stderr: fmt.Sprintf("All %d of the daemon's browsers are busy, waiting...\n", cap(p.slots))})
+		p.slots <- struct{}{}
+	}
+	defer func() { <-p.slots }()
+
+	w, err := p.acquire(requestSession(request.Args))
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer p.release(w)
+
+	workerConn, err := net.Dial("unix", w.socket)
+	if err != nil {
+		fail(fmt.Errorf("lost the daemon's browser %d: %v", w.index, err))
+		return
+	}
+	defer workerConn.Close()
+	if err := json.NewEncoder(workerConn).Encode(request); err != nil {
+		fail(fmt.Errorf("lost the daemon's browser %d: %v", w.index, err))
+		return
+	}
+	io.Copy(conn, workerConn)
+}
+
+// acquire takes an idle browser, or starts one when there is none. A
+// --session's runs go to the browser holding its page, waiting for it if busy.
+func (p *workerPool) acquire(session string) (*worker, error) {
+	p.mu.Lock()
+	for {
+		if p.closed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("the daemon is stopping")
+		}
+		w := p.sessions[session]
+		if w == nil {
+			break
+		}
+		if w.exited() {
+			delete(p.sessions, session)
+			break
+		}
+		if i := slices.Index(p.idle, w); i >= 0 {
+			p.idle = slices.Delete(p.idle, i, i+1)
+			p.mu.Unlock()
+			return w, nil
+		}
+		p.freed.Wait()
+	}
+
+	// Prefer the first browser, it keeps what runs change in the profile
+	p.idle = slices.DeleteFunc(p.idle, (*worker).exited)
+	if len(p.idle) > 0 {
+		first := 0
+		for i, w := range p.idle {
+			if w.index < p.idle[first].index {
+				first = i
+			}
+		}
+		w := p.idle[first]
+		p.idle = slices.Delete(p.idle, first, first+1)
+		if session != "" {
+			p.sessions[session] = w
+		}
+		p.mu.Unlock()
+		return w, nil
+	}
+
+	index := 0
+	for p.workers[index] != nil {
+		index++
+	}
+	w := &worker{index: index, socket: fmt.Sprintf("%s.%d", p.socketPath, index)}
+	p.workers[index] = w
+	if session != "" {
+		p.sessions[session] = w
+	}
+	p.mu.Unlock()
+
+	if err := p.start(w); err != nil {
+		p.mu.Lock()
+		if p.workers[index] == w {
+			delete(p.workers, index)
+		}
+		p.mu.Unlock()
+		return nil, err
+	}
+	return w, nil
+}
+
+// release returns a browser to the pool once a run is done with it
+func (p *workerPool) release(w *worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w.exited() {
+		return
+	}
+	w.lastUsed = time.Now()
+	p.idle = append(p.idle, w)
+	p.freed.Broadcast()
+}
+
+// start runs the worker process and waits until its browser is open
+func (p *workerPool) start(w *worker) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not find the web executable: %v", err)
+	}
+
+	profile := p.config.Profile
+	profileCopy := ""
+	if w.index > 0 && p.config.Engine != "webkit" {
+		profile = fmt.Sprintf("%s.worker-%d", p.config.Profile, w.index)
+		if profileCopy, err = copyProfile(p.config.Engine, p.config.Profile, profile); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"daemon", "--worker", w.socket, "--profile", profile, "--engine", p.config.Engine}
+	if p.config.EncryptProfile {
+		args = append(args, "--encrypt-profile")
+	}
+	if p.config.BrowserPath != "" {
+		args = append(args, "--browser-path", p.config.BrowserPath)
+	}
+	if p.recycleAfter > 0 {
+		args = append(args, "--recycle-after", strconv.Itoa(p.recycleAfter))
+	}
+	if p.config.MaxMemory != "" {
+		args = append(args, "--max-memory", p.config.MaxMemory)
+	}
+	if p.config.MaxCPU > 0 {
+		args = append(args, "--max-cpu", strconv.Itoa(p.config.MaxCPU))
+	}
+	cmd := exec.Command(exe, args...)
+	// Workers only log, the content comes back over their sockets
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		if profileCopy != "" {
+			os.RemoveAll(profileCopy)
+		}
+		return fmt.Errorf("could not start browser %d: %v", w.index, err)
+	}
+
+	p.mu.Lock()
+	w.stdin = stdin
+	w.done = make(chan struct{})
+	if p.closed {
+		stdin.Close()
+	}
+	p.mu.Unlock()
+
+	go func() {
+		cmd.Wait()
+		if profileCopy != "" {
+			os.RemoveAll(profileCopy)
+		}
+		close(w.done)
+		p.mu.Lock()
+		if p.workers[w.index] == w {
+			delete(p.workers, w.index)
+		}
+		p.freed.Broadcast()
+		p.mu.Unlock()
+	}()
+
+	// The worker listens once its browser is open
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		select {
+		case <-w.done:
+			return fmt.Errorf("browser %d exited while starting", w.index)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.Dial("unix", w.socket); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	w.stop()
+	return fmt.Errorf("browser %d did not start within 2 minutes", w.index)
+}
+
+// evict stops browsers left idle for longer than the idle timeout, freeing
+// their memory until runs pick up again
+func (p *workerPool) evict(shutdown chan struct{}) {
+	ticker := time.NewTicker(min(p.idleTimeout, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		var stale []*worker
+		p.idle = slices.DeleteFunc(p.idle, func(w *worker) bool {
+			// Keep browsers holding --session pages for their next run
+			for _, holder := range p.sessions {
+				if holder == w {
+					return false
+				}
+			}
+			if time.Since(w.lastUsed) >= p.idleTimeout {
+				stale = append(stale, w)
+				return true
+			}
+			return false
+		})
+		p.mu.Unlock()
+
+		for _, w := range stale {
+			logf("Closing browser %d, idle for %s\n", w.index, p.idleTimeout)
+			w.stop()
+		}
+	}
+}
+
+// close stops every browser, letting runs in progress finish first
+func (p *workerPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.freed.Broadcast()
+	var running []*worker
+	for _, w := range p.workers {
+		if w.done != nil {
+			running = append(running, w)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range running {
+		w.stdin.Close()
+	}
+	for _, w := range running {
+		<-w.done
+	}
+}
+
+// stop asks the worker to close its browser and waits for it to exit
+func (w *worker) stop() {
+	w.stdin.Close()
+	<-w.done
+}
+
+// exited reports whether the worker process has ended
+func (w *worker) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// copyProfile copies an engine's profile to a fresh profile named name, and
+// returns its directory. A profile that doesn't exist yet copies as empty.
+func copyProfile(engine, profile, name string) (string, error) {
+	dir, err := engineDir(engine)
+	if err != nil {
+		return "", err
+	}
+	src := filepath.Join(dir, "profiles", profile)
+	dst := filepath.Join(dir, "profiles", name)
+	os.RemoveAll(dst)
+	if _, err := os.Stat(src); err != nil {
+		return dst, nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return "", fmt.Errorf("could not copy profile %q: %v", profile, err)
+	}
+	return dst, nil
+}
+
+// requestSession finds a run's --session name in its arguments
+func requestSession(args []string) string {
+	for i, arg := range args {
+		if arg == "--session" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if name, ok := strings.CutPrefix(arg, "--session="); ok {
+			return name
+		}
+	}
+	return ""
+}
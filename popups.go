@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// Popup is a window opened by the page (window.open, target=_blank, OAuth flows)
+type Popup struct {
+	Handle string
+	URL    string
+	Title  string
+}
+
+// detectPopups returns the windows that aren't mainHandle. When wait is set it
+// gives a popup that is still being opened that long to appear. The session is
+// switched back to mainHandle afterwards.
+func detectPopups(wd selenium.WebDriver, mainHandle string, wait time.Duration) ([]Popup, error) {
+	var handles []string
+	err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		var err error
+		handles, err = wd.WindowHandles()
+		return err == nil && len(handles) > 1, nil
+	}, wait)
+	if err != nil && handles == nil {
+		return nil, fmt.Errorf("could not list windows: %v", err)
+	}
+
+	var popups []Popup
+	for _, handle := range handles {
+		if handle == mainHandle {
+			continue
+		}
+		if err := wd.SwitchWindow(handle); err != nil {
+			continue
+		}
+		popup := Popup{Handle: handle}
+		popup.URL, _ = wd.CurrentURL()
+		popup.Title, _ = wd.Title()
+		popups = append(popups, popup)
+	}
+
+	// Switching windows resets the frame selection, so only switch back if we left
+	if len(popups) == 0 {
+		return nil, nil
+	}
+	if err := wd.SwitchWindow(mainHandle); err != nil {
+		return popups, fmt.Errorf("could not switch back to main window: %v", err)
+	}
+	return popups, nil
+}
+
+// followPopup switches the session to popup and prepares it like a regular page,
+// refreshing its URL and title once it has loaded
+func followPopup(wd selenium.WebDriver, config Config, popup *Popup) (bool, error) {
+	if err := wd.SwitchWindow(popup.Handle); err != nil {
+		return false, fmt.Errorf("could not switch to popup: %v", err)
+	}
+
+	// Popups usually start at about:blank before loading their target
+	waitForFunction(wd, "return document.readyState === 'complete' && location.href !== 'about:blank'", config.NavTimeout)
+
+	popup.URL, _ = wd.CurrentURL()
+	popup.Title, _ = wd.Title()
+	logf("Following popup: %s\n", popup.URL)
+	return preparePage(wd, config), nil
+}
+
+func formatPopups(popups []Popup, followed string) string {
+	var b strings.Builder
+	for _, popup := range popups {
+		fmt.Fprintf(&b, "%s", popup.URL)
+		if popup.Title != "" {
+			fmt.Fprintf(&b, " (%s)", popup.Title)
+		}
+		if popup.Handle == followed {
+			b.WriteString(" [followed]")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// Encrypted profiles are stored as <name>.enc next to the plaintext profile directories
+	encryptedProfileMagic = "WEBPROF1"
+	profileKeyIterations  = 600000
+	profileSaltSize       = 16
+)
+
+// openProfile returns the browser profile directory for config.Profile and a
+// function to call once the browser has exited. Plaintext profiles are used in
+// place. Encrypted profiles are decrypted into a private temporary directory,
+// and close re-encrypts it and removes the plaintext copy. The profile is
+// locked against other runs until close.
+func openProfile(config Config) (string, func() error, error) {
+	lock, err := lockProfile(config)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, closeProfile, err := openProfileDir(config)
+	if err != nil {
+		lock.release()
+		return "", nil, err
+	}
+	lock.record(dir)
+	return dir, func() error {
+		defer lock.release()
+		return closeProfile()
+	}, nil
+}
+
+// openProfileDir prepares the profile directory for openProfile
+func openProfileDir(config Config) (string, func() error, error) {
+	// Each engine keeps its own profiles, their formats aren't compatible
+	dir, err := engineDir(config.Engine)
+	if err != nil {
+		return "", nil, err
+	}
+
+	profilesDir := filepath.Join(dir, "profiles")
+	profileDir := filepath.Join(profilesDir, config.Profile)
+	encryptedPath := profileDir + ".enc"
+
+	// Profiles hold live session cookies, keep them private to the user
+	if err := os.MkdirAll(profilesDir, 0700); err != nil {
+		return "", nil, fmt.Errorf("could not create profiles directory: %v", err)
+	}
+	os.Chmod(profilesDir, 0700)
+
+	_, statErr := os.Stat(encryptedPath)
+	encrypted := statErr == nil
+
+	if !config.EncryptProfile {
+		if encrypted {
+			return "", nil, fmt.Errorf("profile %q is encrypted, run with --encrypt-profile", config.Profile)
+		}
+		os.MkdirAll(profileDir, 0700)
+		os.Chmod(profileDir, 0700)
+		return profileDir, func() error { return nil }, nil
+	}
+
+	passphrase, err := profilePassphrase(config.Profile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	workDir, err := os.MkdirTemp("", "web-profile-")
+	if err != nil {
+		return "", nil, fmt.Errorf("could not create profile directory: %v", err)
+	}
+
+	if encrypted {
+		if err := unsealProfile(encryptedPath, workDir, passphrase); err != nil {
+			os.RemoveAll(workDir)
+			return "", nil, fmt.Errorf("could not decrypt profile %q: %v", config.Profile, err)
+		}
+	} else if _, err := os.Stat(profileDir); err == nil {
+		// Migrate an existing plaintext profile, it is removed once sealed
+		if err := copyDir(profileDir, workDir); err != nil {
+			os.RemoveAll(workDir)
+			return "", nil, fmt.Errorf("could not read profile %q: %v", config.Profile, err)
+		}
+	}
+
+	closeProfile := func() error {
+		defer os.RemoveAll(workDir)
+		if err := sealProfile(workDir, encryptedPath, passphrase); err != nil {
+			return fmt.Errorf("could not encrypt profile %q: %v", config.Profile, err)
+		}
+		return os.RemoveAll(profileDir)
+	}
+
+	return workDir, closeProfile, nil
+}
+
+// profilePassphrase reads the passphrase from WEB_PROFILE_PASSPHRASE, falling
+// back to the OS keychain (macOS Keychain or libsecret's secret-tool on Linux)
+func profilePassphrase(profile string) (string, error) {
+	if passphrase := os.Getenv("WEB_PROFILE_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", "web-profile", "-a", profile, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", "web-profile", "profile", profile)
+	}
+	if cmd != nil {
+		if output, err := cmd.Output(); err == nil {
+			if passphrase := strings.TrimRight(string(output), "\r\n"); passphrase != "" {
+				return passphrase, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("--encrypt-profile needs a passphrase: set WEB_PROFILE_PASSPHRASE or store one in the OS keychain (service \"web-profile\", account %q)", profile)
+}
+
+// sealProfile archives dir and writes it to path encrypted with AES-256-GCM,
+// using a key derived from passphrase with PBKDF2
+func sealProfile(dir, path, passphrase string) error {
+	var archive bytes.Buffer
+	if err := tarDir(dir, &archive); err != nil {
+		return err
+	}
+	sealed, err := seal(archive.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so an interrupted run never leaves a truncated profile
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, sealed, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// unsealProfile decrypts the profile at path into dir
+func unsealProfile(path, dir, passphrase string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	archive, err := unseal(data, passphrase)
+	if err != nil {
+		return err
+	}
+	return untarDir(bytes.NewReader(archive), dir)
+}
+
+// seal encrypts data with AES-256-GCM, using a key derived from passphrase
+// with PBKDF2, behind a header of the magic, the salt and the nonce
+func seal(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, profileSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := profileCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(encryptedProfileMagic)
+	out.Write(salt)
+	out.Write(nonce)
+	out.Write(gcm.Seal(nil, nonce, data, []byte(encryptedProfileMagic)))
+	return out.Bytes(), nil
+}
+
+// isSealed reports whether data was encrypted by seal
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedProfileMagic))
+}
+
+// unseal decrypts what seal encrypted
+func unseal(data []byte, passphrase string) ([]byte, error) {
+	header := len(encryptedProfileMagic) + profileSaltSize
+	if len(data) < header || !isSealed(data) {
+		return nil, fmt.Errorf("not an encrypted profile")
+	}
+	salt := data[len(encryptedProfileMagic):header]
+
+	gcm, err := profileCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < header+gcm.NonceSize() {
+		return nil, fmt.Errorf("not an encrypted profile")
+	}
+	nonce := data[header : header+gcm.NonceSize()]
+
+	plaintext, err := gcm.Open(nil, nonce, data[header+gcm.NonceSize():], []byte(encryptedProfileMagic))
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted file")
+	}
+	return plaintext, nil
+}
+
+func profileCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, profileKeyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// tarDir writes the regular files and directories under dir as a gzipped tar.
+// Lock files and sockets Firefox leaves behind are skipped.
+func tarDir(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || !(info.Mode().IsRegular() || info.IsDir()) {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func untarDir(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in profile archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			file.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// copyDir copies the regular files and directories under src into dst
+func copyDir(src, dst string) error {
+	var archive bytes.Buffer
+	if err := tarDir(src, &archive); err != nil {
+		return err
+	}
+	return untarDir(&archive, dst)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSealProfileRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "storage", "default"), 0700)
+	os.WriteFile(filepath.Join(src, "cookies.sqlite"), []byte("session=abc123"), 0600)
+	os.WriteFile(filepath.Join(src, "storage", "default", "ls.sqlite"), []byte("local storage"), 0600)
+
+	path := filepath.Join(t.TempDir(), "default.enc")
+	if err := sealProfile(src, path, "correct horse"); err != nil {
+		t.Fatalf("Failed to seal profile: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "session=abc123") {
+		t.Errorf("Encrypted profile contains plaintext cookies")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Encrypted profile permissions = %v, expected 0600", info.Mode().Perm())
+	}
+
+	dst := t.TempDir()
+	if err := unsealProfile(path, dst, "correct horse"); err != nil {
+		t.Fatalf("Failed to unseal profile: %v", err)
+	}
+	cookies, _ := os.ReadFile(filepath.Join(dst, "cookies.sqlite"))
+	storage, _ := os.ReadFile(filepath.Join(dst, "storage", "default", "ls.sqlite"))
+	if string(cookies) != "session=abc123" || string(storage) != "local storage" {
+		t.Errorf("Unsealed profile differs: cookies=%q storage=%q", cookies, storage)
+	}
+
+	if err := unsealProfile(path, t.TempDir(), "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Expected wrong passphrase error, got: %v", err)
+	}
+}
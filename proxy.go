@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hopHeaders apply to a single connection and are not forwarded
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Exchange is a request the browser made through the proxy and its response
+type Exchange struct {
+	Method         string
+	URL            string
+	Proto          string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	Status         string
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	RemoteAddr     string
+	Started        time.Time
+	Duration       time.Duration
+	Err            error
+}
+
+// networkProxy is a local HTTP proxy the browser is pointed at when a run
+// needs to see its traffic. HTTPS is intercepted with certificates signed by
+// a throwaway CA, which the browser is told to accept.
+type networkProxy struct {
+	listener  net.Listener
+	ca        *x509.Certificate
+	caKey     *ecdsa.PrivateKey
+	transport *http.Transport
+
+	mu        sync.Mutex
+	certs     map[string]*tls.Certificate
+	exchanges []*Exchange
+	headers   []headerRule
+	upstream  *upstreamProxy
+	blocked   []string
+	blocks    int
+	rewrites  []RewriteRule
+	resolves  []ResolveRule
+	mocks     []MockRule
+	replaying *replaySession
+
+	// What is kept of the traffic. Unless a recorder asks for more, only the
+	// page's document requests, for its status, headers and redirects, and
+	// bodies pass through without being held in memory.
+	keepAll    bool
+	keepBodies bool
+
+	// The redirects of the page's navigation so far, for --max-redirects
+	maxRedirects int
+	redirects    int
+	nextHop      string
+	decodable    bool
+}
+
+// headerRule is a header the proxy adds to requests to a domain and its
+// subdomains
+type headerRule struct {
+	domain, name, value string
+}
+
+// needsProxy reports whether a run's traffic goes through the local proxy,
+// to be recorded or changed on the way
+func needsProxy(config Config) bool {
+	return config.WARCPath != "" || config.HARPath != "" || config.Headers || config.NetworkFailures || config.CaptureAPI || config.CaptureGraphQL ||
+		config.Bearer != "" || config.ProxyURL != nil || len(config.Block) > 0 || len(config.Rewrites) > 0 || len(config.Resolves) > 0 ||
+		len(config.Mocks) > 0 || config.RecordSession != "" || config.Replay != nil ||
+		config.Redirects || config.MaxRedirects > 0 || config.NoFollowRedirects
+}
+
+func startProxy() (*networkProxy, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate proxy CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "web proxy CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not create proxy CA: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("could not create proxy CA: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not start proxy: %v", err)
+	}
+
+	p := &networkProxy{
+		listener: listener,
+		ca:       ca,
+		caKey:    caKey,
+		transport: &http.Transport{
+			// Bodies are passed through as the server encoded them
+			DisableCompression:  true,
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		certs:        map[string]*tls.Certificate{},
+		maxRedirects: -1,
+	}
+	go http.Serve(listener, p)
+	return p, nil
+}
+
+// Port is the port the proxy listens on, on 127.0.0.1
+func (p *networkProxy) Port() int {
+	return p.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (p *networkProxy) Close() {
+	p.listener.Close()
+	p.transport.CloseIdleConnections()
+}
+
+// useUpstream sends the traffic on through a --proxy
+func (p *networkProxy) useUpstream(upstream *upstreamProxy) {
+	p.upstream = upstream
+	p.transport.Proxy = upstream.proxyFor
+}
+
+// block turns away requests of the --block types
+func (p *networkProxy) block(types []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocked = append(p.blocked, types...)
+}
+
+// Blocked is how many requests block turned away
+func (p *networkProxy) Blocked() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blocks
+}
+
+// isBlocked reports whether req is of a blocked type, counting it if so
+func (p *networkProxy) isBlocked(req *http.Request) bool {
+	kind := resourceType(req)
+	if kind == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !slices.Contains(p.blocked, kind) {
+		return false
+	}
+	p.blocks++
+	return true
+}
+
+// resolve connects to the addresses the rules give for their hosts
+func (p *networkProxy) resolve(rules []ResolveRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resolves = append(p.resolves, rules...)
+	p.transport.DialContext = resolvingDialer(p.resolves)
+}
+
+// limitEncodings asks servers for the encodings decodeBody can read, which
+// the browser reads as well, so recorded bodies can be shown
+func (p *networkProxy) limitEncodings() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decodable = true
+}
+
+// rewrite sends requests matching the rules elsewhere
+func (p *networkProxy) rewrite(rules []RewriteRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rewrites = append(p.rewrites, rules...)
+}
+
+// rewritten is where a request for u goes after the rewrite rules
+func (p *networkProxy) rewritten(u *url.URL) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return rewriteURL(p.rewrites, u)
+}
+
+// mock answers requests matching the rules with their fixtures
+func (p *networkProxy) mock(rules []MockRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mocks = append(p.mocks, rules...)
+}
+
+// mocked returns the mock rule answering a request for u, if any
+func (p *networkProxy) mocked(req *http.Request, u *url.URL) (MockRule, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return findMock(p.mocks, req, u)
+}
+
+// replay answers every request from a recorded session instead of the
+// network
+func (p *networkProxy) replay(session *replaySession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replaying = session
+}
+
+// record keeps every exchange, with its request and response bodies when
+// bodies is set, for the recorders that report the page's traffic
+func (p *networkProxy) record(bodies bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keepAll = true
+	p.keepBodies = p.keepBodies || bodies
+}
+
+// limitRedirects stops the browser following more than max redirects in a
+// row when it navigates, so the page is the redirect it stopped at
+func (p *networkProxy) limitRedirects(max int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxRedirects = max
+}
+
+// followsRedirect reports whether the browser may follow the response to a
+// document request, counting the redirects that led to it
+func (p *networkProxy) followsRedirect(exchange *Exchange, resp *http.Response) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxRedirects < 0 || !isDocumentRequest(exchange.RequestHeader) {
+		return true
+	}
+	if exchange.URL != p.nextHop {
+		p.redirects = 0
+	}
+	p.nextHop = redirectLocation(exchange.URL, resp.StatusCode, resp.Header)
+	if p.nextHop == "" {
+		return true
+	}
+	if p.redirects >= p.maxRedirects {
+		p.nextHop = ""
+		return false
+	}
+	p.redirects++
+	return true
+}
+
+// setHeader adds the header to requests to domain and its subdomains,
+// replacing the value the browser sent
+func (p *networkProxy) setHeader(domain, name, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.headers = append(p.headers, headerRule{domain, name, value})
+}
+
+// addHeaders applies the setHeader headers meant for hostname
+func (p *networkProxy) addHeaders(header http.Header, hostname string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, rule := range p.headers {
+		if hostname == rule.domain || strings.HasSuffix(hostname, "."+rule.domain) {
+			header.Set(rule.name, rule.value)
+		}
+	}
+}
+
+// Exchanges returns the completed exchanges in the order they started
+func (p *networkProxy) Exchanges() []*Exchange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var done []*Exchange
+	for _, exchange := range p.exchanges {
+		if exchange.Duration > 0 || exchange.Err != nil {
+			done = append(done, exchange)
+		}
+	}
+	return done
+}
+
+func (p *networkProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+			return
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+		go p.serveTunnel(conn, r.Host)
+		return
+	}
+
+	// Plain HTTP requests arrive with an absolute URL
+	if isUpgrade(r) {
+		p.tunnelUpgrade(w, r, r.URL.Scheme, r.URL.Host)
+		return
+	}
+	resp, body, err := p.forward(r, r.URL.Scheme, r.URL.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	if body != nil {
+		w.Write(body)
+	} else {
+		streamBody(w, resp.Body)
+	}
+	resp.Body.Close()
+}
+
+// serveTunnel handles the connection behind a CONNECT, which carries TLS
+// for https or plain HTTP for ws:// and other unencrypted traffic
+func (p *networkProxy) serveTunnel(conn net.Conn, host string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+
+	scheme := "http"
+	var client net.Conn = &peekedConn{Conn: conn, reader: reader}
+	if first[0] == 0x16 {
+		// A TLS handshake record
+		scheme = "https"
+		hostname, _, _ := net.SplitHostPort(host)
+		tlsConn := tls.Server(client, &tls.Config{
+			NextProtos: []string{"http/1.1"},
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if hello.ServerName != "" {
+					return p.certificate(hello.ServerName)
+				}
+				return p.certificate(hostname)
+			},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		client = tlsConn
+		reader = bufio.NewReader(tlsConn)
+	}
+
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		if isUpgrade(req) {
+			p.tunnelUpgradeConn(client, reader, req, scheme, host)
+			return
+		}
+
+		resp, body, err := p.forward(req, scheme, host)
+		if err != nil {
+			resp = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			}
+			body = []byte(err.Error())
+		}
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+		resp.Request = req
+		if body != nil {
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			resp.ContentLength = int64(len(body))
+			resp.TransferEncoding = nil
+		} else if isStream(resp) {
+			// Streamed responses have no length, so the connection ends with them
+			resp.Close = true
+		}
+		err = resp.Write(client)
+		resp.Body.Close()
+		if err != nil || resp.Close || req.Close {
+			return
+		}
+	}
+}
+
+// forward sends req upstream and records the exchange. The response body is
+// read into memory when the exchange's bodies are kept, or when it replaces
+// the body itself. Otherwise, and for open-ended streams, it is left unread
+// and returned with a nil body slice. Blocked requests get an empty response
+// without leaving the machine, and aren't recorded. Mocked requests are
+// answered from their fixture and recorded as if the server had sent it,
+// as are all requests when replaying a session.
+// Rewritten requests are recorded under the URL the browser asked for.
+func (p *networkProxy) forward(req *http.Request, scheme, host string) (*http.Response, []byte, error) {
+	if p.isBlocked(req) {
+		req.Body.Close()
+		return &http.Response{StatusCode: http.StatusNoContent, Header: http.Header{}, Body: http.NoBody}, []byte{}, nil
+	}
+	p.mu.Lock()
+	keepAll, keepBodies, replaying := p.keepAll, p.keepBodies, p.replaying
+	p.mu.Unlock()
+
+	exchange := &Exchange{Method: req.Method, Proto: "HTTP/1.1", Started: time.Now()}
+	// Replays are matched on the request body, so it is read for them too
+	var requestBody []byte
+	if keepBodies || replaying != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.URL.Scheme = scheme
+	if scheme == "https" {
+		out.URL.Host = strings.TrimSuffix(host, ":443")
+	} else {
+		out.URL.Host = strings.TrimSuffix(host, ":80")
+	}
+	out.Host = ""
+	for _, header := range hopHeaders {
+		out.Header.Del(header)
+	}
+	p.addHeaders(out.Header, out.URL.Hostname())
+	p.mu.Lock()
+	if p.decodable && out.Header.Get("Accept-Encoding") != "" {
+		out.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	p.mu.Unlock()
+	exchange.URL = out.URL.String()
+	rule, mocked := p.mocked(req, out.URL)
+	out.URL = p.rewritten(out.URL)
+	if requestBody != nil {
+		out.Body = http.NoBody
+		out.ContentLength = int64(len(requestBody))
+		if len(requestBody) > 0 {
+			out.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+	}
+	out = out.WithContext(httptrace.WithClientTrace(out.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			exchange.RemoteAddr = info.Conn.RemoteAddr().String()
+		},
+	}))
+
+	exchange.RequestHeader = out.Header.Clone()
+	if keepBodies {
+		exchange.RequestBody = requestBody
+	}
+	if keepAll || isDocumentRequest(exchange.RequestHeader) {
+		p.mu.Lock()
+		p.exchanges = append(p.exchanges, exchange)
+		p.mu.Unlock()
+	}
+
+	if mocked {
+		if requestBody == nil {
+			req.Body.Close()
+		}
+		resp, body := mockResponse(rule, out)
+		p.finish(exchange, resp, body, nil)
+		return resp, body, nil
+	}
+	if replaying != nil {
+		resp, body, err := replaying.replay(exchange.Method, exchange.URL, requestBody)
+		p.finish(exchange, resp, body, err)
+		return resp, body, err
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.finish(exchange, nil, nil, err)
+		return nil, nil, err
+	}
+	for _, header := range hopHeaders {
+		resp.Header.Del(header)
+	}
+
+	var body []byte
+	if keepBodies && !isStream(resp) {
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			p.finish(exchange, nil, nil, err)
+			return nil, nil, err
+		}
+	}
+	p.finish(exchange, resp, body, nil)
+	// Without its Location the browser shows the redirect as the page
+	if !p.followsRedirect(exchange, resp) {
+		if body == nil {
+			resp.Body.Close()
+		}
+		location := resp.Header.Get("Location")
+		resp.Header.Del("Location")
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Set("Content-Type", "text/html; charset=utf-8")
+		body = []byte(fmt.Sprintf("<title>%s</title><p>Redirect to <a href=\"%s\">%s</a> not followed</p>\n",
+			resp.Status, html.EscapeString(location), html.EscapeString(location)))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return resp, body, nil
+}
+
+func (p *networkProxy) finish(exchange *Exchange, resp *http.Response, body []byte, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	exchange.Duration = max(time.Since(exchange.Started), time.Nanosecond)
+	exchange.Err = err
+	if resp != nil {
+		exchange.Status = resp.Status
+		exchange.StatusCode = resp.StatusCode
+		exchange.ResponseHeader = resp.Header.Clone()
+		exchange.ResponseBody = body
+	}
+}
+
+// tunnelUpgrade relays a WebSocket (or other Upgrade) request made over the
+// plain proxy connection
+func (p *networkProxy) tunnelUpgrade(w http.ResponseWriter, r *http.Request, scheme, host string) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "upgrade not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	p.tunnelUpgradeConn(conn, buffered.Reader, r, scheme, host)
+}
+
+// tunnelUpgradeConn passes req to the server, or where the rewrite rules send
+// it, with the setHeader headers meant for it, and then copies bytes
+// in both directions until either side closes. A replayed session has no
+// WebSockets to give, so they are refused.
+func (p *networkProxy) tunnelUpgradeConn(client net.Conn, clientReader *bufio.Reader, req *http.Request, scheme, host string) {
+	p.mu.Lock()
+	replaying := p.replaying != nil
+	p.mu.Unlock()
+	if replaying {
+		io.WriteString(client, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		return
+	}
+	req.Header.Del("Proxy-Connection")
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+	}
+	p.addHeaders(req.Header, hostname)
+	// Rewrite rules name hosts without their default port
+	requested := &url.URL{Scheme: scheme, Host: strings.TrimSuffix(host, ":80"), Path: req.URL.Path, RawQuery: req.URL.RawQuery}
+	if scheme == "https" {
+		requested.Host = strings.TrimSuffix(host, ":443")
+	}
+	target := p.rewritten(requested)
+	scheme, host = target.Scheme, target.Host
+	req.URL.Path, req.URL.RawQuery, req.Host = target.Path, target.RawQuery, target.Host
+
+	if !strings.Contains(host, ":") {
+		if scheme == "https" {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	hostname, _, _ = net.SplitHostPort(host)
+	var upstream net.Conn
+	if p.upstream != nil && !p.upstream.bypasses(host) {
+		upstream, err = p.upstream.dial(host)
+	} else {
+		upstream, err = net.Dial("tcp", resolveAddr(p.resolves, host))
+	}
+	if err != nil {
+		return
+	}
+	if scheme == "https" {
+		tlsConn := tls.Client(upstream, &tls.Config{ServerName: hostname, NextProtos: []string{"http/1.1"}})
+		if err := tlsConn.Handshake(); err != nil {
+			upstream.Close()
+			return
+		}
+		upstream = tlsConn
+	}
+	defer upstream.Close()
+
+	req.RequestURI = req.URL.RequestURI()
+	if err := req.Write(upstream); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, clientReader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// certificate returns a certificate for host signed by the proxy's CA
+func (p *networkProxy) certificate(host string) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cert, ok := p.certs[host]; ok {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{Certificate: [][]byte{der, p.ca.Raw}, PrivateKey: key}
+	p.certs[host] = cert
+	return cert, nil
+}
+
+func isUpgrade(req *http.Request) bool {
+	return req.Header.Get("Upgrade") != ""
+}
+
+// isStream reports whether a response is open-ended, like server-sent events
+func isStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+func streamBody(w http.ResponseWriter, body io.Reader) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// peekedConn is a connection whose first bytes were already buffered by reader
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// proxyPrefs points Firefox at the proxy for all traffic, including to
+// localhost, which it otherwise always connects to directly. Background
+// connectivity checks are turned off so they don't show up as page traffic.
+func proxyPrefs(port int) map[string]interface{} {
+	return map[string]interface{}{
+		"network.proxy.type":                      1,
+		"network.proxy.http":                      "127.0.0.1",
+		"network.proxy.http_port":                 port,
+		"network.proxy.ssl":                       "127.0.0.1",
+		"network.proxy.ssl_port":                  port,
+		"network.proxy.no_proxies_on":             "",
+		"network.proxy.allow_hijacking_localhost": true,
+		"network.captive-portal-service.enabled":  false,
+		"network.connectivity-service.enabled":    false,
+	}
+}
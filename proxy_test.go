@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProxyRecordsExchanges(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello from "+r.URL.Path)
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.record(true)
+	// Trust the test server's certificate upstream
+	proxy.transport.TLSClientConfig = secure.Client().Transport.(*http.Transport).TLSClientConfig
+
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	for _, target := range []string{plain.URL + "/plain", secure.URL + "/secure"} {
+		resp, err := client.Get(target)
+		if err != nil {
+			t.Fatalf("GET %s through proxy failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if expected := "hello from " + resp.Request.URL.Path; string(body) != expected {
+			t.Errorf("GET %s body = %q, expected %q", target, body, expected)
+		}
+	}
+
+	exchanges := proxy.Exchanges()
+	if len(exchanges) != 2 {
+		t.Fatalf("Expected 2 exchanges, got %d", len(exchanges))
+	}
+	if exchanges[0].URL != plain.URL+"/plain" || exchanges[1].URL != secure.URL+"/secure" {
+		t.Errorf("Recorded URLs = %s, %s", exchanges[0].URL, exchanges[1].URL)
+	}
+	if exchanges[1].StatusCode != 200 || string(exchanges[1].ResponseBody) != "hello from /secure" {
+		t.Errorf("HTTPS exchange recorded incorrectly: %+v", exchanges[1])
+	}
+}
+
+func TestProxySetHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	get := func() string {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("GET through proxy failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Other sites don't get the header
+	proxy.setHeader("example.com", "Authorization", "Bearer elsewhere")
+	if got := get(); got != "" {
+		t.Errorf("Expected no Authorization for another site, got %q", got)
+	}
+	proxy.setHeader("127.0.0.1", "Authorization", "Bearer token")
+	if got := get(); got != "Bearer token" {
+		t.Errorf("Authorization = %q, expected %q", got, "Bearer token")
+	}
+
+	for pageURL, expected := range map[string]string{
+		"https://www.example.com/app": "example.com",
+		"http://app.example.com:4000": "app.example.com",
+	} {
+		if got := bearerDomain(pageURL); got != expected {
+			t.Errorf("bearerDomain(%q) = %q, expected %q", pageURL, got, expected)
+		}
+	}
+}
+
+func TestProxyBlock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "served "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.record(false)
+	proxy.block([]string{"images", "stylesheets"})
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	cases := []struct {
+		path, dest string
+		blocked    bool
+	}{
+		{"/page", "document", false},
+		{"/logo", "image", true},
+		{"/app.css", "", true},
+		{"/font.woff2", "font", false},
+		// A script's fetch of an image-looking URL is left alone
+		{"/data.png", "empty", false},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("GET", server.URL+tc.path, nil)
+		if tc.dest != "" {
+			req.Header.Set("Sec-Fetch-Dest", tc.dest)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tc.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if blocked := resp.StatusCode == http.StatusNoContent && len(body) == 0; blocked != tc.blocked {
+			t.Errorf("GET %s (%s): status %d, body %q, expected blocked = %v", tc.path, tc.dest, resp.StatusCode, body, tc.blocked)
+		}
+	}
+	if proxy.Blocked() != 2 || len(proxy.Exchanges()) != 3 {
+		t.Errorf("Expected 2 blocked and 3 recorded requests, got %d and %d", proxy.Blocked(), len(proxy.Exchanges()))
+	}
+
+	if _, err := parseBlockTypes("images,scripts"); err == nil {
+		t.Errorf("Expected --block scripts to be rejected")
+	}
+}
+
+func TestProxyPassesBodiesThrough(t *testing.T) {
+	large := strings.Repeat("x", 4<<20)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded, _ := io.Copy(io.Discard, r.Body)
+		w.Header().Set("X-Uploaded", strconv.FormatInt(uploaded, 10))
+		io.WriteString(w, large)
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.transport.TLSClientConfig = secure.Client().Transport.(*http.Transport).TLSClientConfig
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	for _, target := range []string{plain.URL, secure.URL, secure.URL} {
+		req, _ := http.NewRequest("POST", target+"/upload", strings.NewReader(large))
+		if target == plain.URL {
+			req.Header.Set("Accept", "text/html")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("POST through proxy failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if len(body) != len(large) || resp.Header.Get("X-Uploaded") != strconv.Itoa(len(large)) {
+			t.Errorf("Expected %d bytes each way, got %d back and %s uploaded", len(large), len(body), resp.Header.Get("X-Uploaded"))
+		}
+	}
+
+	// Only the document is kept, for the page's status and headers, and
+	// without its bodies
+	exchanges := proxy.Exchanges()
+	if len(exchanges) != 1 || exchanges[0].StatusCode != 200 {
+		t.Fatalf("Expected only the document exchange, got %+v", exchanges)
+	}
+	if exchanges[0].RequestBody != nil || exchanges[0].ResponseBody != nil {
+		t.Errorf("Expected no bodies kept, got %d and %d bytes", len(exchanges[0].RequestBody), len(exchanges[0].ResponseBody))
+	}
+}
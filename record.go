@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"gopkg.in/yaml.v3"
+)
+
+// recordPollInterval is how often the recorder collects events and checks the URL
+const recordPollInterval = 200 * time.Millisecond
+
+// selectorForJS defines selectorFor, which builds the shortest stable
+// selector it can for an element: a unique id, a unique test id, name, label
+// or href, and failing those a tag path from the nearest element with an id
+const selectorForJS = `
+	function unique(selector) {
+		try { return document.querySelectorAll(selector).length === 1; }
+		catch (e) { return false; }
+	}
+	function selectorFor(el) {
+		if (el.id && unique('#' + CSS.escape(el.id))) {
+			return '#' + CSS.escape(el.id);
+		}
+		var tag = el.tagName.toLowerCase();
+		var attrs = ['data-testid', 'data-test', 'name', 'aria-label', 'href'];
+		for (var i = 0; i < attrs.length; i++) {
+			var value = el.getAttribute(attrs[i]);
+			var selector = tag + '[' + attrs[i] + '="' + (value || '').replace(/"/g, '\\"') + '"]';
+			if (value && unique(selector)) {
+				return selector;
+			}
+		}
+		var path = [];
+		for (var node = el; node && node.nodeType === 1 && node !== document.documentElement; node = node.parentElement) {
+			if (node !== el && node.id && unique('#' + CSS.escape(node.id))) {
+				path.unshift('#' + CSS.escape(node.id));
+				break;
+			}
+			var part = node.tagName.toLowerCase();
+			var siblings = node.parentElement ? Array.prototype.filter.call(node.parentElement.children, function(child) {
+				return child.tagName === node.tagName;
+			}) : [];
+			if (siblings.length > 1) {
+				part += ':nth-of-type(' + (siblings.indexOf(node) + 1) + ')';
+			}
+			path.unshift(part);
+		}
+		return path.join(' > ');
+	}
+`
+
+// recorderJS captures clicks, field changes and form submissions as script
+// steps. Events go to sessionStorage so ones fired right before a same-origin
+// navigation survive until the next poll.
+const recorderJS = `
+	if (window.__webRecorder) { return; }
+	window.__webRecorder = true;
+` + selectorForJS + `
+	function record(event) {
+		var log = JSON.parse(sessionStorage.getItem('__webRecorded') || '[]');
+		log.push(event);
+		sessionStorage.setItem('__webRecorded', JSON.stringify(log));
+	}
+	function isTextField(el) {
+		var tag = el.tagName.toLowerCase();
+		if (tag === 'textarea' || tag === 'select' || el.isContentEditable) {
+			return true;
+		}
+		return tag === 'input' && ['checkbox', 'radio', 'submit', 'button', 'reset', 'image', 'file'].indexOf(el.type) === -1;
+	}
+
+	var lastClicked = null;
+	document.addEventListener('click', function(e) {
+		var el = e.target.closest('a, button, input, label, summary, [role=button], [role=link], [role=tab], [role=menuitem], [onclick], [phx-click]') || e.target;
+		// Focusing a field is part of filling it, not a step of its own
+		if (isTextField(el)) {
+			return;
+		}
+		lastClicked = el;
+		record({ type: 'click', selector: selectorFor(el) });
+	}, true);
+	document.addEventListener('change', function(e) {
+		var el = e.target;
+		if (!isTextField(el)) {
+			return;
+		}
+		var value = el.isContentEditable ? el.innerText : el.value;
+		if (el.tagName.toLowerCase() === 'select' && el.multiple) {
+			value = Array.prototype.map.call(el.selectedOptions, function(option) { return option.value; }).join(',');
+		}
+		record({ type: 'fill', selector: selectorFor(el), value: value, secret: el.type === 'password' });
+	}, true);
+	document.addEventListener('input', function(e) {
+		// Contenteditable editors don't fire change
+		if (e.target.isContentEditable) {
+			record({ type: 'fill', selector: selectorFor(e.target), value: e.target.innerText });
+		}
+	}, true);
+	document.addEventListener('submit', function(e) {
+		// Pressing Enter in a field submits through the form's default button
+		if (e.submitter && e.submitter !== lastClicked) {
+			record({ type: 'click', selector: selectorFor(e.submitter) });
+		} else if (!e.submitter) {
+			record({ type: 'unreplayable', selector: selectorFor(e.target) });
+		}
+	}, true);
+`
+
+// Recording accumulates recorded events into script steps
+type Recording struct {
+	Steps []ScriptStep
+	// acted is set when a click since the last navigation may explain a URL change
+	acted   bool
+	secrets int
+}
+
+// add appends a recorded browser event. Repeated fills of the same field
+// collapse into the last value.
+func (rec *Recording) add(event map[string]interface{}) {
+	kind, _ := event["type"].(string)
+	selector, _ := event["selector"].(string)
+	value, _ := event["value"].(string)
+
+	switch kind {
+	case "click":
+		rec.Steps = append(rec.Steps, ScriptStep{Click: selector})
+		rec.acted = true
+	case "fill":
+		if secret, _ := event["secret"].(bool); secret {
+			rec.secrets++
+		}
+		if n := len(rec.Steps); n > 0 && rec.Steps[n-1].Fill == selector {
+			rec.Steps[n-1].Value = value
+			return
+		}
+		rec.Steps = append(rec.Steps, ScriptStep{Fill: selector, Value: value})
+	case "unreplayable":
+		logf("Warning: form %s was submitted without a submit button, which the script cannot replay\n", selector)
+		rec.acted = true
+	}
+}
+
+// navigated records a URL change, as a goto unless a click caused it
+func (rec *Recording) navigated(url string) {
+	if !rec.acted {
+		rec.Steps = append(rec.Steps, ScriptStep{Goto: url})
+	}
+	rec.acted = false
+}
+
+type RecordConfig struct {
+	URL            string
+	Output         string
+	Profile        string
+	EncryptProfile bool
+}
+
+// runRecord implements `web record <url>`, opening a visible browser and
+// turning what the user does in it into a --script file when the window is
+// closed (or on Ctrl+C). It returns the process exit code.
+func runRecord(args []string) int {
+	config := RecordConfig{Profile: "default"}
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printRecordHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--output", kind: flagOutput, target: &config.Output},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		dataDirFlag,
+		{name: "--encrypt-profile", kind: flagBool, target: &config.EncryptProfile},
+	}
+
+	err := parseFlags(args, defs, func(arg string) error {
+		if config.URL != "" {
+			return fmt.Errorf("unexpected argument %q (URL is already %q)", arg, config.URL)
+		}
+		config.URL = arg
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web record --help' for usage.\n", err)
+		return 1
+	}
+
+	if config.URL == "" {
+		printRecordHelp()
+		return 1
+	}
+
+	ensureBrowser(Config{})
+
+	browserConfig := newConfig()
+	browserConfig.Profile = config.Profile
+	browserConfig.EncryptProfile = config.EncryptProfile
+	browserConfig.Headed = true
+	stop, wd, err := startBrowser(browserConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+	defer stop()
+
+	start := ensureProtocol(config.URL)
+	if err := navigate(wd, browserConfig, start); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	logf("Recording. Use the browser, then close its window (or press Ctrl+C) to save the script.\n")
+	rec := &Recording{Steps: []ScriptStep{{Goto: start}}}
+	recordUntilClosed(wd, rec)
+
+	if rec.secrets > 0 {
+		logf("Warning: the script contains %d password value(s) in plain text\n", rec.secrets)
+	}
+
+	data, err := yaml.Marshal(rec.Steps)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding script: %v\n", err)
+		return 1
+	}
+	if config.Output == "" {
+		fmt.Print(string(data))
+		return 0
+	}
+	if err := os.WriteFile(config.Output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing script: %v\n", err)
+		return 1
+	}
+	logf("Recorded %d step(s) to %s\n", len(rec.Steps), config.Output)
+	return 0
+}
+
+// recordUntilClosed polls the browser for recorded events and navigations
+// until the window is closed or the process is interrupted
+func recordUntilClosed(wd selenium.WebDriver, rec *Recording) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	lastURL, _ := wd.CurrentURL()
+	ticker := time.NewTicker(recordPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			collectRecordedEvents(wd, rec)
+			return
+		case <-ticker.C:
+		}
+
+		// The session ends when the user closes the window
+		currentURL, err := wd.CurrentURL()
+		if err != nil {
+			return
+		}
+		collectRecordedEvents(wd, rec)
+		if currentURL != lastURL {
+			rec.navigated(currentURL)
+			lastURL = currentURL
+		}
+		if _, err := wd.ExecuteScript(recorderJS, nil); err != nil && strings.Contains(err.Error(), "no such window") {
+			return
+		}
+	}
+}
+
+// collectRecordedEvents drains the events recorded since the last poll
+func collectRecordedEvents(wd selenium.WebDriver, rec *Recording) {
+	raw, err := wd.ExecuteScript(`
+		var log = JSON.parse(sessionStorage.getItem('__webRecorded') || '[]');
+		sessionStorage.removeItem('__webRecorded');
+		return log;
+	`, nil)
+	if err != nil {
+		return
+	}
+	events, _ := raw.([]interface{})
+	for _, event := range events {
+		if entry, ok := event.(map[string]interface{}); ok {
+			rec.add(entry)
+		}
+	}
+}
+
+func printRecordHelp() {
+	fmt.Print(`web record - record browser actions into a script
+
+Usage: web record <url> [options]
+
+Opens a visible browser at <url> and records clicks, filled fields and
+navigations. Close the browser window (or press Ctrl+C) to finish; the steps
+are written in the --script format for replay with 'web --script'.
+
+Options:
+  --help                     Show this help message
+  --output <filepath>        Write the script to <filepath> instead of printing it
+  --profile <name>           Use or create named session profile (default: "default")
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --encrypt-profile          Keep the profile encrypted at rest (passphrase from WEB_PROFILE_PASSPHRASE or the OS keychain)
+
+Examples:
+  web record localhost:4000/users/log-in --output login.yaml
+  web --script login.yaml --profile mysite
+`)
+}
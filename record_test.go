@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordingSteps(t *testing.T) {
+	rec := &Recording{Steps: []ScriptStep{{Goto: "http://localhost:4000/login"}}}
+	rec.add(map[string]interface{}{"type": "fill", "selector": "#email", "value": "foo"})
+	rec.add(map[string]interface{}{"type": "fill", "selector": "#email", "value": "foo@bar.com"})
+	rec.add(map[string]interface{}{"type": "fill", "selector": "input[name=\"password\"]", "value": "secret", "secret": true})
+	rec.add(map[string]interface{}{"type": "click", "selector": "#login"})
+	// Caused by the click, so not a goto
+	rec.navigated("http://localhost:4000/dashboard")
+	// Typed into the address bar
+	rec.navigated("http://localhost:4000/settings")
+
+	expected := []ScriptStep{
+		{Goto: "http://localhost:4000/login"},
+		{Fill: "#email", Value: "foo@bar.com"},
+		{Fill: "input[name=\"password\"]", Value: "secret"},
+		{Click: "#login"},
+		{Goto: "http://localhost:4000/settings"},
+	}
+	if !reflect.DeepEqual(rec.Steps, expected) {
+		t.Errorf("Recorded steps = %+v, expected %+v", rec.Steps, expected)
+	}
+	if rec.secrets != 1 {
+		t.Errorf("Expected 1 recorded secret, got %d", rec.secrets)
+	}
+}
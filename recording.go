@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// recordingIndex is the file of a --record-session directory listing its
+// responses, whose bodies sit next to it as they came over the wire
+const recordingIndex = "index.json"
+
+// recordedExchange is a request of a recorded session and its response
+type recordedExchange struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// RequestSHA256 identifies the request body, for requests with one
+	RequestSHA256 string      `json:"request_sha256,omitempty"`
+	Status        int         `json:"status,omitempty"`
+	Header        http.Header `json:"header,omitempty"`
+	BodyFile      string      `json:"body_file,omitempty"`
+	// Error is why the request failed, which replaying it repeats
+	Error string `json:"error,omitempty"`
+
+	body []byte
+}
+
+// replaySession answers requests from a recorded session. Requests made
+// several times get their responses in the order they were recorded, the
+// last one again once those run out.
+type replaySession struct {
+	mu       sync.Mutex
+	recorded map[string][]*recordedExchange
+	served   map[string]int
+	missed   int
+}
+
+// bodySHA256 is the hex SHA-256 of a request body, or "" without one
+func bodySHA256(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeRecording saves the exchanges to dir for --replay-session. Streamed
+// responses, which the proxy relays without keeping, are left out.
+func writeRecording(dir string, exchanges []*Exchange) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create %s: %v", dir, err)
+	}
+	recorded := []recordedExchange{}
+	for _, exchange := range exchanges {
+		entry := recordedExchange{
+			Method:        exchange.Method,
+			URL:           exchange.URL,
+			RequestSHA256: bodySHA256(exchange.RequestBody),
+		}
+		if exchange.Err != nil {
+			entry.Error = exchange.Err.Error()
+		} else if exchange.ResponseBody == nil {
+			continue
+		} else {
+			entry.Status = exchange.StatusCode
+			entry.Header = exchange.ResponseHeader
+			if len(exchange.ResponseBody) > 0 {
+				entry.BodyFile = fmt.Sprintf("%04d.body", len(recorded)+1)
+				if err := os.WriteFile(filepath.Join(dir, entry.BodyFile), exchange.ResponseBody, 0644); err != nil {
+					return fmt.Errorf("could not write %s: %v", entry.BodyFile, err)
+				}
+			}
+		}
+		recorded = append(recorded, entry)
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode recording: %v", err)
+	}
+	path := filepath.Join(dir, recordingIndex)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
+
+// readRecording loads a session written by writeRecording
+func readRecording(dir string) (*replaySession, error) {
+	data, err := os.ReadFile(filepath.Join(dir, recordingIndex))
+	if err != nil {
+		return nil, fmt.Errorf("could not read recording: %v", err)
+	}
+	var recorded []*recordedExchange
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("could not parse recording %s: %v", dir, err)
+	}
+	session := &replaySession{recorded: map[string][]*recordedExchange{}, served: map[string]int{}}
+	for _, entry := range recorded {
+		if entry.BodyFile != "" {
+			if entry.body, err = os.ReadFile(filepath.Join(dir, filepath.Base(entry.BodyFile))); err != nil {
+				return nil, fmt.Errorf("could not read recording: %v", err)
+			}
+		}
+		key := entry.Method + " " + entry.URL
+		session.recorded[key] = append(session.recorded[key], entry)
+	}
+	return session, nil
+}
+
+// replay answers a request from the recording, preferring responses to a
+// request with the same body. Requests that weren't recorded fail, so the
+// run never reaches the network.
+func (s *replaySession) replay(method, url string, body []byte) (*http.Response, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + url
+	candidates := s.recorded[key]
+	if sum := bodySHA256(body); sum != "" {
+		var same []*recordedExchange
+		for _, entry := range candidates {
+			if entry.RequestSHA256 == sum {
+				same = append(same, entry)
+			}
+		}
+		if len(same) > 0 {
+			candidates, key = same, key+" "+sum
+		}
+	}
+	if len(candidates) == 0 {
+		s.missed++
+		return nil, nil, fmt.Errorf("%s %s is not in the recording", method, url)
+	}
+	entry := candidates[min(s.served[key], len(candidates)-1)]
+	s.served[key]++
+
+	if entry.Error != "" {
+		return nil, nil, errors.New(entry.Error)
+	}
+	header := entry.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	status := fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status))
+	return &http.Response{Status: status, StatusCode: entry.Status, Header: header, Body: http.NoBody}, append([]byte{}, entry.body...), nil
+}
+
+// Missed is how many requests weren't in the recording
+func (s *replaySession) Missed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.missed
+}
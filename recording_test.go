@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	exchanges := []*Exchange{
+		{Method: "GET", URL: "https://example.com/", StatusCode: 200, ResponseHeader: http.Header{"Content-Type": {"text/html"}}, ResponseBody: []byte("<h1>Hi</h1>")},
+		{Method: "GET", URL: "https://example.com/count", StatusCode: 200, ResponseHeader: http.Header{}, ResponseBody: []byte("1")},
+		{Method: "GET", URL: "https://example.com/count", StatusCode: 200, ResponseHeader: http.Header{}, ResponseBody: []byte("2")},
+		{Method: "POST", URL: "https://example.com/search", RequestBody: []byte("q=a"), StatusCode: 200, ResponseHeader: http.Header{}, ResponseBody: []byte("a")},
+		{Method: "POST", URL: "https://example.com/search", RequestBody: []byte("q=b"), StatusCode: 200, ResponseHeader: http.Header{}, ResponseBody: []byte("b")},
+		{Method: "GET", URL: "https://example.com/down", Err: errors.New("connection refused")},
+		{Method: "GET", URL: "https://example.com/events", StatusCode: 200, ResponseHeader: http.Header{}},
+		{Method: "GET", URL: "https://example.com/empty", StatusCode: 204, ResponseHeader: http.Header{}, ResponseBody: []byte{}},
+	}
+	if err := writeRecording(dir, exchanges); err != nil {
+		t.Fatal(err)
+	}
+	session, err := readRecording(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(method, url, requestBody, expected string) {
+		t.Helper()
+		_, body, err := session.replay(method, url, []byte(requestBody))
+		got := string(body)
+		if err != nil {
+			got = "error: " + err.Error()
+		} else if body == nil {
+			// A nil body is taken for a stream still to be read
+			got = "nil body"
+		}
+		if got != expected {
+			t.Errorf("replay(%s %s) = %q, expected %q", method, url, got, expected)
+		}
+	}
+	expect("GET", "https://example.com/", "", "<h1>Hi</h1>")
+	// Repeated requests are answered in order, then with the last response
+	expect("GET", "https://example.com/count", "", "1")
+	expect("GET", "https://example.com/count", "", "2")
+	expect("GET", "https://example.com/count", "", "2")
+	expect("POST", "https://example.com/search", "q=b", "b")
+	expect("POST", "https://example.com/search", "q=a", "a")
+	expect("GET", "https://example.com/down", "", "error: connection refused")
+	expect("GET", "https://example.com/empty", "", "")
+	// Streamed responses aren't recorded
+	expect("GET", "https://example.com/events", "", "error: GET https://example.com/events is not in the recording")
+	expect("GET", "https://example.com/other", "", "error: GET https://example.com/other is not in the recording")
+	if missed := session.Missed(); missed != 2 {
+		t.Errorf("Expected 2 missed requests, got %d", missed)
+	}
+
+	if _, err := readRecording(t.TempDir()); err == nil {
+		t.Error("Expected a directory without a recording to be rejected")
+	}
+}
+
+func TestProxyReplay(t *testing.T) {
+	dir := t.TempDir()
+	exchanges := []*Exchange{{Method: "GET", URL: "http://offline.example.test/api", StatusCode: 200, ResponseHeader: http.Header{"Content-Type": {"application/json"}}, ResponseBody: []byte(`{"ok":true}`)}}
+	if err := writeRecording(dir, exchanges); err != nil {
+		t.Fatal(err)
+	}
+	session, err := readRecording(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.replay(session)
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://offline.example.test/api")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != `{"ok":true}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the recorded response, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = client.Get("http://offline.example.test/missing")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || !strings.Contains(string(body), "not in the recording") {
+		t.Errorf("Expected a request not recorded to fail, got %d %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectHop is a response on the way to the page, and where it sent the
+// browser next
+type RedirectHop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	// Location is where a redirect pointed, resolved against URL
+	Location string `json:"location,omitempty"`
+	// Followed is false for a redirect --max-redirects or
+	// --no-follow-redirects stopped
+	Followed bool `json:"followed,omitempty"`
+}
+
+// isDocumentRequest reports whether the browser asked for a page to show
+// in the tab, rather than a frame, asset or fetch
+func isDocumentRequest(header http.Header) bool {
+	// Browsers only label requests with Sec-Fetch-Dest on secure origins
+	if dest := header.Get("Sec-Fetch-Dest"); dest != "" {
+		return dest == "document"
+	}
+	return strings.Contains(header.Get("Accept"), "text/html")
+}
+
+// isRedirect reports whether a response sends the browser on elsewhere
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectLocation is the URL a redirect response points to, or "" for
+// other responses
+func redirectLocation(requestURL string, status int, header http.Header) string {
+	location := header.Get("Location")
+	if !isRedirect(status) || location == "" {
+		return ""
+	}
+	base, err := url.Parse(requestURL)
+	if err != nil {
+		return ""
+	}
+	target, err := base.Parse(location)
+	if err != nil {
+		return ""
+	}
+	// Fragments aren't sent, and the request carries the earlier one
+	target.Fragment = ""
+	return target.String()
+}
+
+// redirectChain retraces the redirects that led to the page at pageURL from
+// the recorded traffic, ending with the page's own response. A page whose
+// redirect wasn't followed ends the chain with that redirect.
+func redirectChain(exchanges []*Exchange, pageURL string) []RedirectHop {
+	var documents []*Exchange
+	for _, exchange := range exchanges {
+		if exchange.StatusCode != 0 && isDocumentRequest(exchange.RequestHeader) {
+			documents = append(documents, exchange)
+		}
+	}
+	if u, err := url.Parse(pageURL); err == nil {
+		u.Fragment = ""
+		pageURL = u.String()
+	}
+
+	last := -1
+	for i, exchange := range documents {
+		if exchange.URL == pageURL {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	hop := func(exchange *Exchange) RedirectHop {
+		location := redirectLocation(exchange.URL, exchange.StatusCode, exchange.ResponseHeader)
+		return RedirectHop{URL: exchange.URL, Status: exchange.StatusCode, Location: location}
+	}
+	chain := []RedirectHop{hop(documents[last])}
+	for i := last - 1; i >= 0; i-- {
+		previous := hop(documents[i])
+		if previous.Location != chain[0].URL {
+			continue
+		}
+		previous.Followed = true
+		chain = append([]RedirectHop{previous}, chain...)
+	}
+	return chain
+}
+
+func formatRedirects(chain []RedirectHop) string {
+	if len(chain) == 0 {
+		return "No response recorded for the page\n"
+	}
+	var b strings.Builder
+	for _, hop := range chain {
+		fmt.Fprintf(&b, "%d %s", hop.Status, hop.URL)
+		if hop.Location != "" {
+			fmt.Fprintf(&b, " -> %s", hop.Location)
+			if !hop.Followed {
+				b.WriteString(" (not followed)")
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(chain) == 1 && chain[0].Location == "" {
+		b.WriteString("No redirects\n")
+	}
+	return b.String()
+}
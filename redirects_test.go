@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedirectChain(t *testing.T) {
+	document := http.Header{"Sec-Fetch-Dest": {"document"}}
+	exchanges := []*Exchange{
+		{URL: "http://example.com/", RequestHeader: document, StatusCode: 301, ResponseHeader: http.Header{"Location": {"https://example.com/"}}},
+		{URL: "https://example.com/", RequestHeader: document, StatusCode: 302, ResponseHeader: http.Header{"Location": {"/login?next=%2F"}}},
+		{URL: "https://example.com/app.js", RequestHeader: http.Header{"Sec-Fetch-Dest": {"script"}}, StatusCode: 200, ResponseHeader: http.Header{}},
+		{URL: "https://example.com/login?next=%2F", RequestHeader: document, StatusCode: 200, ResponseHeader: http.Header{}},
+	}
+	expected := []RedirectHop{
+		{URL: "http://example.com/", Status: 301, Location: "https://example.com/", Followed: true},
+		{URL: "https://example.com/", Status: 302, Location: "https://example.com/login?next=%2F", Followed: true},
+		{URL: "https://example.com/login?next=%2F", Status: 200},
+	}
+	chain := redirectChain(exchanges, "https://example.com/login?next=%2F#form")
+	if !reflect.DeepEqual(chain, expected) {
+		t.Errorf("Unexpected chain %+v", chain)
+	}
+	formatted := formatRedirects(chain)
+	for _, want := range []string{
+		"301 http://example.com/ -> https://example.com/\n",
+		"302 https://example.com/ -> https://example.com/login?next=%2F\n",
+		"200 https://example.com/login?next=%2F\n",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected %q in:\n%s", want, formatted)
+		}
+	}
+
+	// A redirect that wasn't followed is the page
+	chain = redirectChain(exchanges[:1], "http://example.com/")
+	if len(chain) != 1 || chain[0].Followed || !strings.Contains(formatRedirects(chain), "(not followed)") {
+		t.Errorf("Unexpected chain %+v", chain)
+	}
+	if got := formatRedirects(redirectChain(exchanges[3:], "https://example.com/login?next=%2F")); !strings.Contains(got, "No redirects") {
+		t.Errorf("Expected no redirects, got %q", got)
+	}
+}
+
+func TestProxyLimitRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		default:
+			io.WriteString(w, "page")
+		}
+	}))
+	defer server.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.limitRedirects(1)
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	req, _ := http.NewRequest("GET", server.URL+"/a", nil)
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	// The first redirect is followed, the second shown
+	if resp.StatusCode != http.StatusFound || resp.Request.URL.Path != "/b" || !strings.Contains(string(body), "not followed") {
+		t.Errorf("Expected to stop at the second redirect, got %d at %s: %q", resp.StatusCode, resp.Request.URL, body)
+	}
+	chain := redirectChain(proxy.Exchanges(), server.URL+"/b")
+	if len(chain) != 2 || !chain[0].Followed || chain[1].Followed || chain[1].Location != server.URL+"/c" {
+		t.Errorf("Unexpected chain %+v", chain)
+	}
+
+	// Other requests are left alone
+	resp, err = client.Get(server.URL + "/a")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "page" {
+		t.Errorf("Expected a fetch to follow every redirect, got %q", body)
+	}
+}
+
+func TestFetchHTTPRedirects(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		default:
+			io.WriteString(w, "<p>page</p>")
+		}
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	config.HTTPOnly = true
+	config.Redirects = true
+	config.URL = server.URL + "/a"
+	result, err := fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"301 " + server.URL + "/a -> " + server.URL + "/b\n", "302 " + server.URL + "/b -> " + server.URL + "/c\n", "200 " + server.URL + "/c\n"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+
+	config.NoFollowRedirects = true
+	if result, err = fetchHTTP(config); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "301 "+server.URL+"/a -> "+server.URL+"/b (not followed)") {
+		t.Errorf("Expected the first redirect not to be followed:\n%s", result)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// runRepl implements `web repl <url>`, keeping one browser session open and
+// executing commands read from stdin. It returns the process exit code.
+func runRepl(args []string) int {
+	config := newConfig()
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printReplHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		dataDirFlag,
+		{name: "--encrypt-profile", kind: flagBool, target: &config.EncryptProfile},
+		{name: "--engine", kind: flagString, apply: func(engine string) error {
+			if !slices.Contains(engines, engine) {
+				return fmt.Errorf("--engine must be one of %s, got %q", strings.Join(engines, ", "), engine)
+			}
+			config.Engine = engine
+			return nil
+		}},
+		{name: "--browser-path", kind: flagFile, target: &config.BrowserPath},
+		{name: "--headed", kind: flagBool, target: &config.Headed},
+		{name: "--truncate-after", kind: flagInt, target: &config.TruncateAfter},
+		{name: "--deep", kind: flagBool, target: &config.Deep},
+		{name: "--csrf", kind: flagBool, target: &config.CSRF},
+		{name: "--nav-timeout", kind: flagDuration, target: &config.NavTimeout},
+		{name: "--action-timeout", kind: flagDuration, target: &config.ActionTimeout},
+	}
+
+	err := parseFlags(args, defs, func(arg string) error {
+		if config.URL != "" {
+			return fmt.Errorf("unexpected argument %q (URL is already %q)", arg, config.URL)
+		}
+		config.URL = arg
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web repl --help' for usage.\n", err)
+		return 1
+	}
+
+	ensureBrowser(config)
+
+	stop, wd, err := startBrowser(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+	defer stop()
+
+	session := &replSession{config: config, wd: wd}
+	if config.URL != "" {
+		session.run("goto " + config.URL)
+	}
+
+	// Only show a prompt to humans; piped commands get clean output
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+		fmt.Println("Type 'help' for a list of commands, 'quit' to exit")
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("web> ")
+		}
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line != "" {
+			if !session.run(line) {
+				return 0
+			}
+		}
+		if err == io.EOF {
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+	}
+}
+
+type replSession struct {
+	config     Config
+	wd         selenium.WebDriver
+	isLiveView bool
+}
+
+// run executes a single REPL command and reports false when the session should end
+func (session *replSession) run(line string) bool {
+	command, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	wd := session.wd
+
+	var err error
+	switch command {
+	case "help":
+		printReplCommands()
+	case "quit", "exit":
+		return false
+	case "goto":
+		url := ensureProtocol(rest)
+		if err = navigate(wd, session.config, url); err == nil {
+			session.isLiveView = preparePage(wd, session.config)
+			fmt.Printf("Loaded %s\n", url)
+		}
+	case "url":
+		var url string
+		if url, err = wd.CurrentURL(); err == nil {
+			fmt.Println(url)
+		}
+	case "click":
+		if err = clickAndWait(wd, session.config, rest, &session.isLiveView); err == nil {
+			fmt.Printf("Clicked %s\n", rest)
+		}
+	case "fill":
+		args := splitCommandArgs(rest)
+		if len(args) != 2 {
+			err = fmt.Errorf("usage: fill <selector> <value>")
+		} else if err = fillField(wd, session.config, args[0], args[1]); err == nil {
+			fmt.Printf("Filled %s\n", args[0])
+		}
+	case "js":
+		// eval gives us the value of the last expression without requiring `return`
+		var result interface{}
+		if result, err = wd.ExecuteScript("return eval(arguments[0])", []interface{}{rest}); err == nil && result != nil {
+			encoded, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(encoded))
+		}
+	case "dump":
+		var output string
+		if rest != "" {
+			output, err = extractMarkdown(wd, session.config, rest)
+		} else {
+			var content string
+			if content, err = pageSource(wd, session.config); err == nil {
+				output, err = convertToMarkdown(content, session.config.TruncateAfter)
+			}
+		}
+		if err == nil {
+			fmt.Println(output)
+		}
+	case "html":
+		var content string
+		if content, err = wd.PageSource(); err == nil {
+			fmt.Println(content)
+		}
+	case "screenshot":
+		if rest == "" {
+			err = fmt.Errorf("usage: screenshot <filepath>")
+			break
+		}
+		var screenshot []byte
+		if screenshot, err = wd.Screenshot(); err == nil {
+			if err = os.WriteFile(rest, screenshot, 0644); err == nil {
+				fmt.Printf("Screenshot saved to %s\n", rest)
+			}
+		}
+	case "console":
+		// Handled below, every command prints new console output
+	default:
+		err = fmt.Errorf("unknown command %q (type 'help' for a list of commands)", command)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	session.printConsole()
+	return true
+}
+
+// printConsole prints and clears console messages logged since the last command
+func (session *replSession) printConsole() {
+	messages := collectConsoleMessages(session.wd)
+	session.wd.ExecuteScript("if (window.__consoleMessages) { window.__consoleMessages.length = 0; }", nil)
+	for _, msg := range messages {
+		fmt.Println(msg.String())
+	}
+}
+
+// splitCommandArgs splits on whitespace, honoring single and double quotes
+func splitCommandArgs(input string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range input {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args
+}
+
+func printReplCommands() {
+	fmt.Print(`Commands:
+  goto <url>                 Navigate to <url>
+  url                        Print the current URL
+  click <selector>           Click an element and wait for navigation or LiveView updates
+  fill <selector> <value>    Replace the value of an input (quote arguments containing spaces)
+  js <code>                  Execute JavaScript and print the value of the last expression
+  dump [selector]            Print the page (or matching elements) as markdown
+  html                       Print the raw page HTML
+  screenshot <filepath>      Save a screenshot
+  console                    Print console messages logged since the last command
+  help                       Show this list
+  quit                       Close the browser and exit
+`)
+}
+
+func printReplHelp() {
+	fmt.Printf(`web repl - interactive browsing session
+
+Usage: web repl [url] [options]
+
+Keeps a browser open and executes commands read from stdin, one per line.
+Console messages logged by each command are printed after its output.
+
+Options:
+  --help                     Show this help message
+  --profile <name>           Use or create named session profile (default: "default")
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --encrypt-profile          Keep the profile encrypted at rest (passphrase from WEB_PROFILE_PASSPHRASE or the OS keychain)
+  --engine <name>            Browser to render with: firefox (default), chromium or webkit
+  --browser-path <path>      Run this browser executable instead of downloading one
+  --headed                   Show the browser window
+  --truncate-after <number>  Truncate dump output after <number> characters (default: %d)
+  --deep                     Let selectors and dump reach into open shadow roots (web components)
+  --csrf                     Add the page's CSRF token to fetch/XHR requests and form submissions made by js
+  --nav-timeout <duration>   Maximum time for page loads and LiveView navigation (default: 30s)
+  --action-timeout <duration> Maximum time click and fill wait for their element (default: 10s)
+
+`, DEFAULT_TRUNCATE_AFTER)
+	printReplCommands()
+}
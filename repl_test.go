@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandArgs(t *testing.T) {
+	cases := map[string][]string{
+		`#email foo@bar.com`:                         {"#email", "foo@bar.com"},
+		`"#form input[name='q']" "phoenix liveview"`: {"#form input[name='q']", "phoenix liveview"},
+		`'#name'   ''`:                               {"#name", ""},
+		`  spaced   out  `:                           {"spaced", "out"},
+	}
+
+	for input, expected := range cases {
+		if got := splitCommandArgs(input); !reflect.DeepEqual(got, expected) {
+			t.Errorf("splitCommandArgs(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResolveRule connects to Address for Host on Port, like curl's --resolve,
+// instead of the address DNS gives. A Port of "*" is any port.
+type ResolveRule struct {
+	Host    string
+	Port    string
+	Address string
+}
+
+// parseResolve parses a --resolve host:port:address rule, with an IPv6
+// address in brackets
+func parseResolve(value string) (ResolveRule, error) {
+	invalid := fmt.Errorf("--resolve expects host:port:address, e.g. example.com:443:10.0.0.5, got %q", value)
+	host, rest, ok := strings.Cut(value, ":")
+	if !ok || host == "" {
+		return ResolveRule{}, invalid
+	}
+	port, address, ok := strings.Cut(rest, ":")
+	if !ok {
+		return ResolveRule{}, invalid
+	}
+	if n, err := strconv.Atoi(port); port != "*" && (err != nil || n < 1 || n > 65535) {
+		return ResolveRule{}, invalid
+	}
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if net.ParseIP(address) == nil {
+		return ResolveRule{}, invalid
+	}
+	return ResolveRule{Host: strings.ToLower(host), Port: port, Address: address}, nil
+}
+
+// resolveAddr is the address to connect to for addr, a host:port
+func resolveAddr(rules []ResolveRule, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	for _, rule := range rules {
+		if rule.Host == strings.ToLower(host) && (rule.Port == "*" || rule.Port == port) {
+			return net.JoinHostPort(rule.Address, port)
+		}
+	}
+	return addr
+}
+
+// resolvingDialer dials as net.Dialer does, connecting to the addresses the
+// rules give for their hosts. TLS still checks the certificate of the host.
+func resolvingDialer(rules []ResolveRule) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, resolveAddr(rules, addr))
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseResolve(t *testing.T) {
+	cases := map[string]ResolveRule{
+		"example.com:443:10.0.0.5":    {Host: "example.com", Port: "443", Address: "10.0.0.5"},
+		"Example.com:*:[2001:db8::1]": {Host: "example.com", Port: "*", Address: "2001:db8::1"},
+	}
+	for value, expected := range cases {
+		if rule, err := parseResolve(value); err != nil || rule != expected {
+			t.Errorf("parseResolve(%q) = %+v, %v, expected %+v", value, rule, err, expected)
+		}
+	}
+	for _, value := range []string{"example.com:443", "example.com:https:10.0.0.5", "example.com:443:backend.internal", ":443:10.0.0.5"} {
+		if _, err := parseResolve(value); err == nil {
+			t.Errorf("Expected --resolve %q to be rejected", value)
+		}
+	}
+
+	rules := []ResolveRule{{Host: "example.com", Port: "443", Address: "10.0.0.5"}, {Host: "api.example.com", Port: "*", Address: "::1"}}
+	for addr, expected := range map[string]string{
+		"example.com:443":     "10.0.0.5:443",
+		"example.com:80":      "example.com:80",
+		"api.example.com:444": "[::1]:444",
+		"cdn.example.com:443": "cdn.example.com:443",
+	} {
+		if got := resolveAddr(rules, addr); got != expected {
+			t.Errorf("resolveAddr(%q) = %q, expected %q", addr, got, expected)
+		}
+	}
+}
+
+func TestProxyResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backend for "+r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.resolve([]ResolveRule{{Host: "backend.example.test", Port: port, Address: "127.0.0.1"}})
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://backend.example.test:" + port + "/")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	// The server still sees the host the page asked for
+	if expected := "backend for backend.example.test:" + port; string(body) != expected {
+		t.Errorf("Got %q, expected %q", body, expected)
+	}
+}
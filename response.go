@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// relevantHeaders are the response headers reported for --headers: the ones
+// that say what the document is, how fresh it is and who served it
+var relevantHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Language", "Content-Disposition",
+	"Last-Modified", "ETag", "Cache-Control", "Expires", "Age", "Vary",
+	"Server", "X-Robots-Tag", "Retry-After", "Link",
+}
+
+// DocumentResponse is the HTTP response that delivered the final page
+type DocumentResponse struct {
+	URL         string            `json:"url"`
+	Status      int               `json:"status,omitempty"`
+	StatusText  string            `json:"status_text,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// documentResponse describes the response for the page now loaded. With the
+// proxy running it is read from the recorded traffic, headers included;
+// otherwise the status and content type come from the browser.
+func documentResponse(wd selenium.WebDriver, proxy *networkProxy) DocumentResponse {
+	finalURL, _ := wd.CurrentURL()
+	response := DocumentResponse{URL: finalURL}
+
+	if proxy != nil {
+		if exchange := documentExchange(proxy.Exchanges(), finalURL); exchange != nil {
+			response.Status = exchange.StatusCode
+			response.ContentType = exchange.ResponseHeader.Get("Content-Type")
+			response.Headers = map[string]string{}
+			for _, name := range relevantHeaders {
+				if values := exchange.ResponseHeader.Values(name); len(values) > 0 {
+					response.Headers[name] = strings.Join(values, ", ")
+				}
+			}
+		}
+	}
+
+	if response.Status == 0 {
+		raw, err := wd.ExecuteScript(`
+			// Inside --frame, the top document is the page
+			var page = window;
+			try { page = window.top; page.document; } catch (e) { page = window; }
+			var entry = page.performance.getEntriesByType('navigation')[0];
+			return { status: entry && entry.responseStatus || 0, contentType: page.document.contentType || '' };
+		`, nil)
+		if err == nil {
+			entry, _ := raw.(map[string]interface{})
+			status, _ := entry["status"].(float64)
+			response.Status = int(status)
+			if response.ContentType == "" {
+				response.ContentType, _ = entry["contentType"].(string)
+			}
+		}
+	}
+	response.StatusText = http.StatusText(response.Status)
+	return response
+}
+
+// documentExchange finds the last exchange that loaded pageURL as a
+// document, ignoring its fragment
+func documentExchange(exchanges []*Exchange, pageURL string) *Exchange {
+	target, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	target.Fragment = ""
+
+	var match *Exchange
+	for _, exchange := range exchanges {
+		if exchange.URL != target.String() || exchange.StatusCode == 0 {
+			continue
+		}
+		// Browsers only label requests with Sec-Fetch-Dest on secure origins
+		dest := exchange.RequestHeader.Get("Sec-Fetch-Dest")
+		if dest == "" || dest == "document" {
+			match = exchange
+		}
+	}
+	return match
+}
+
+func formatResponse(response DocumentResponse) string {
+	var b strings.Builder
+	if response.Status > 0 {
+		fmt.Fprintf(&b, "Status: %d %s\n", response.Status, response.StatusText)
+	}
+	if response.ContentType != "" {
+		fmt.Fprintf(&b, "Content-Type: %s\n", response.ContentType)
+	}
+	names := make([]string, 0, len(response.Headers))
+	for name := range response.Headers {
+		if name != "Content-Type" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, response.Headers[name])
+	}
+	return b.String()
+}
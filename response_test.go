@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDocumentExchange(t *testing.T) {
+	exchange := func(url, dest string, status int) *Exchange {
+		header := http.Header{}
+		if dest != "" {
+			header.Set("Sec-Fetch-Dest", dest)
+		}
+		return &Exchange{URL: url, RequestHeader: header, StatusCode: status}
+	}
+	redirect := exchange("https://example.com/docs", "document", 301)
+	page := exchange("https://example.com/docs/", "document", 404)
+	exchanges := []*Exchange{
+		redirect,
+		page,
+		exchange("https://example.com/docs/", "image", 200),
+		exchange("https://example.com/app.js", "script", 200),
+		exchange("https://example.com/docs/", "document", 0),
+	}
+
+	if got := documentExchange(exchanges, "https://example.com/docs/#intro"); got != page {
+		t.Errorf("documentExchange = %+v, expected the 404 document response", got)
+	}
+	if got := documentExchange(exchanges, "https://example.com/other"); got != nil {
+		t.Errorf("documentExchange = %+v, expected nil for a URL that wasn't loaded", got)
+	}
+	// Plain http requests carry no Sec-Fetch-Dest
+	plain := exchange("http://localhost:4000/", "", 200)
+	if got := documentExchange([]*Exchange{plain}, "http://localhost:4000/"); got != plain {
+		t.Errorf("documentExchange = %+v, expected the unlabelled response", got)
+	}
+}
+
+func TestFormatResponse(t *testing.T) {
+	got := formatResponse(DocumentResponse{
+		Status:      404,
+		StatusText:  "Not Found",
+		ContentType: "text/html; charset=utf-8",
+		Headers: map[string]string{
+			"Content-Type":  "text/html; charset=utf-8",
+			"Server":        "Cowboy",
+			"Cache-Control": "no-cache",
+		},
+	})
+	expected := "Status: 404 Not Found\nContent-Type: text/html; charset=utf-8\nCache-Control: no-cache\nServer: Cowboy\n"
+	if got != expected {
+		t.Errorf("formatResponse =\n%s\nexpected\n%s", got, expected)
+	}
+}
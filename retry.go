@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DEFAULT_RETRY_BACKOFF = time.Second
+
+// maxRetryDelay caps the wait between attempts, however many there are
+const maxRetryDelay = 30 * time.Second
+
+// transientErrors are what navigation errors say when the server or the
+// network may well do better on a second try
+var transientErrors = []string{
+	"timeout", "timed out", "connection reset", "connection refused", "econnreset", "econnrefused",
+	"neterror", "err_connection", "err_empty_response", "err_timed_out", "err_network_changed",
+	"broken pipe", "unexpected eof", ": eof",
+}
+
+// transientError reports whether a failed page load is worth retrying
+func transientError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, transient := range transientErrors {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// transientStatus reports whether a response is a gateway or server
+// briefly unable to answer, as dev servers give while they restart
+func transientStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// retryDelay is how long to wait before retry n (from 1), doubling the
+// backoff each time
+func retryDelay(backoff time.Duration, n int) time.Duration {
+	delay := backoff
+	for i := 1; i < n && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// retry runs attempt until it succeeds, fails for good or --retries runs
+// out, waiting with exponential backoff in between. attempt returns whether
+// another try may do better, and why this one failed.
+func retry(config Config, what string, attempt func() (bool, error)) error {
+	for n := 1; ; n++ {
+		transient, err := attempt()
+		if err == nil || !transient || n > config.Retries {
+			if err == nil && n > 1 {
+				logf("%s succeeded on attempt %d of %d\n", what, n, config.Retries+1)
+			}
+			return err
+		}
+		delay := retryDelay(config.RetryBackoff, n)
+		logf("Warning: %s failed (%v), retrying in %s (attempt %d of %d)\n", what, err, delay, n+1, config.Retries+1)
+		config.Manifest.retried()
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 6: 30 * time.Second, 60: 30 * time.Second}
+	for n, expected := range cases {
+		if got := retryDelay(time.Second, n); got != expected {
+			t.Errorf("retryDelay(1s, %d) = %s, expected %s", n, got, expected)
+		}
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	for message, expected := range map[string]bool{
+		"Reached error page: about:neterror?e=connectionFailure": true,
+		"read tcp 127.0.0.1:52114: connection reset by peer":     true,
+		"unknown error: net::ERR_CONNECTION_REFUSED":             true,
+		`Get "http://localhost:4000": EOF`:                       true,
+		"invalid argument: Malformed URL":                        false,
+	} {
+		if got := transientError(errors.New(message)); got != expected {
+			t.Errorf("transientError(%q) = %v, expected %v", message, got, expected)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	config := newConfig()
+	config.Retries = 2
+	config.RetryBackoff = time.Millisecond
+
+	attempts := 0
+	err := retry(config, "Loading", func() (bool, error) {
+		attempts++
+		if attempts < 3 {
+			return true, errors.New("connection refused")
+		}
+		return false, nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	err = retry(config, "Loading", func() (bool, error) {
+		attempts++
+		return true, errors.New("connection refused")
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("Expected failure after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	// Errors that won't go away aren't retried
+	attempts = 0
+	retry(config, "Loading", func() (bool, error) {
+		attempts++
+		return false, errors.New("malformed URL")
+	})
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestFetchHTTPRetries(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if requests < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<p>Restarting</p>"))
+			return
+		}
+		w.Write([]byte("<p>Ready</p>"))
+	}))
+	defer server.Close()
+
+	config := newConfig()
+	config.HTTPOnly = true
+	config.URL = server.URL
+	config.Retries = 2
+	config.RetryBackoff = time.Millisecond
+	result, err := fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || !strings.Contains(result, "Ready") {
+		t.Errorf("Expected the third attempt's page, got %d request(s):\n%s", requests, result)
+	}
+
+	// The last 502 is the page when the retries run out
+	requests = -10
+	result, err = fetchHTTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Restarting") || !strings.Contains(result, "502") {
+		t.Errorf("Expected the 502 page:\n%s", result)
+	}
+}
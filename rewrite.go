@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RewriteRule sends requests for URLs under From to the same path under To,
+// e.g. https://api.example.com to http://localhost:4001
+type RewriteRule struct {
+	From string
+	To   string
+}
+
+// parseRewrite parses a --rewrite "from=>to" rule
+func parseRewrite(value string) (RewriteRule, error) {
+	from, to, ok := strings.Cut(value, "=>")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	for _, side := range []string{from, to} {
+		if u, err := url.Parse(side); !ok || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return RewriteRule{}, fmt.Errorf("--rewrite expects \"https://api.example.com=>http://localhost:4001\", got %q", value)
+		}
+	}
+	return RewriteRule{From: from, To: to}, nil
+}
+
+// apply rewrites rawURL if it is under the rule's From: the same URL, or one
+// going on with a path, query or fragment, so https://api.example.com
+// doesn't catch https://api.example.com.evil.test
+func (r RewriteRule) apply(rawURL string) (string, bool) {
+	rest, ok := strings.CutPrefix(rawURL, r.From)
+	if !ok {
+		return "", false
+	}
+	if rest != "" && !strings.HasSuffix(r.From, "/") && !strings.ContainsAny(rest[:1], "/?#") {
+		return "", false
+	}
+	return r.To + rest, true
+}
+
+// rewriteURL applies the first rule that matches u, returning u unchanged
+// when none does
+func rewriteURL(rules []RewriteRule, u *url.URL) *url.URL {
+	for _, rule := range rules {
+		if rewritten, ok := rule.apply(u.String()); ok {
+			if parsed, err := url.Parse(rewritten); err == nil {
+				return parsed
+			}
+		}
+	}
+	return u
+}
+
+// rewriteTransport applies rewrite rules to --http-only requests
+type rewriteTransport struct {
+	rules []RewriteRule
+	next  http.RoundTripper
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := rewriteURL(t.rules, req.URL)
+	if target == req.URL {
+		return t.next.RoundTrip(req)
+	}
+	out := req.Clone(req.Context())
+	out.URL, out.Host = target, ""
+	return t.next.RoundTrip(out)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRewriteRule(t *testing.T) {
+	rule, err := parseRewrite("https://api.example.com => http://localhost:4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"https://api.example.com":                   "http://localhost:4001",
+		"https://api.example.com/v1/users?page=2":   "http://localhost:4001/v1/users?page=2",
+		"https://api.example.com.evil.test/v1":      "",
+		"http://api.example.com/v1":                 "",
+		"https://cdn.example.com/api.example.com/x": "",
+	}
+	for input, expected := range cases {
+		got, ok := rule.apply(input)
+		if ok != (expected != "") || got != expected {
+			t.Errorf("apply(%q) = %q, %v, expected %q", input, got, ok, expected)
+		}
+	}
+
+	for _, value := range []string{"https://api.example.com", "api.example.com=>localhost:4001", "=>http://localhost"} {
+		if _, err := parseRewrite(value); err == nil {
+			t.Errorf("Expected --rewrite %q to be rejected", value)
+		}
+	}
+}
+
+func TestProxyRewrite(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "local "+r.URL.RequestURI())
+	}))
+	defer local.Close()
+
+	proxy, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer proxy.Close()
+	proxy.record(true)
+	proxy.rewrite([]RewriteRule{{From: "http://api.example.test", To: local.URL + "/api"}})
+	proxyURL, _ := url.Parse("http://" + proxy.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://api.example.test/users?page=2")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "local /api/users?page=2" {
+		t.Errorf("Expected the request to reach the local server, got %q", body)
+	}
+	// Recorded as the page asked for it
+	if exchanges := proxy.Exchanges(); len(exchanges) != 1 || exchanges[0].URL != "http://api.example.test/users?page=2" {
+		t.Errorf("Unexpected exchanges %+v", exchanges)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the product token robots.txt groups can name to address
+// web, as "User-agent: web"
+const robotsAgent = "web"
+
+// robotsMaxAge is how long a fetched robots.txt is trusted, the most
+// RFC 9309 allows
+const robotsMaxAge = 24 * time.Hour
+
+// robotsMaxSize is how much of a robots.txt is read, as RFC 9309 has it
+const robotsMaxSize = 500 << 10
+
+// robotsRules are the rules of the robots.txt group that applies to web
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+	// disallowAll is set for a server that couldn't say, which RFC 9309
+	// takes to disallow everything
+	disallowAll bool
+}
+
+// parseRobots reads the group of a robots.txt for agent, or the * group
+// when none names it
+func parseRobots(body, agent string) *robotsRules {
+	agent = strings.ToLower(agent)
+	var named, wildcard *robotsRules
+	// The groups the current lines apply to, several when User-agent
+	// lines follow each other
+	var current []*robotsRules
+	inAgents := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			switch name := strings.ToLower(value); {
+			case name == agent:
+				if named == nil {
+					named = &robotsRules{}
+				}
+				current = append(current, named)
+			case name == "*":
+				if wildcard == nil {
+					wildcard = &robotsRules{}
+				}
+				current = append(current, wildcard)
+			}
+			continue
+		}
+		inAgents = false
+		for _, rules := range current {
+			switch key {
+			case "allow":
+				if value != "" {
+					rules.allow = append(rules.allow, value)
+				}
+			case "disallow":
+				// An empty Disallow allows everything
+				if value != "" {
+					rules.disallow = append(rules.disallow, value)
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if named != nil {
+		return named
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+	return &robotsRules{}
+}
+
+// allows reports whether the rules let web load the path (with its query).
+// The longest matching rule decides, Allow winning a tie.
+func (r *robotsRules) allows(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	longest := func(patterns []string) int {
+		best := -1
+		for _, pattern := range patterns {
+			if len(pattern) > best && robotsMatch(pattern, path) {
+				best = len(pattern)
+			}
+		}
+		return best
+	}
+	disallowed := longest(r.disallow)
+	return disallowed < 0 || longest(r.allow) >= disallowed
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any run of
+// characters and a trailing $ anchors the end, against the start of path
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		// The last part of an anchored pattern has to end the path
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
+// robotsPolicy answers whether URLs may be loaded for --respect-robots,
+// fetching each host's robots.txt once and keeping it for a day in the data
+// directory
+type robotsPolicy struct {
+	client *http.Client
+	dir    string
+
+	mu     sync.Mutex
+	hosts  map[string]*robotsRules
+	visits map[string]time.Time
+}
+
+func newRobotsPolicy() *robotsPolicy {
+	policy := &robotsPolicy{
+		client: &http.Client{Timeout: 10 * time.Second},
+		hosts:  map[string]*robotsRules{},
+		visits: map[string]time.Time{},
+	}
+	if dir, err := engineDir("firefox"); err == nil {
+		policy.dir = filepath.Join(dir, "robots")
+	}
+	return policy
+}
+
+// rules returns the robots.txt rules for the origin of u
+func (p *robotsPolicy) rules(u *url.URL) *robotsRules {
+	origin := u.Scheme + "://" + u.Host
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rules, ok := p.hosts[origin]; ok {
+		return rules
+	}
+	body, err := p.fetch(origin)
+	rules := &robotsRules{disallowAll: true}
+	if err != nil {
+		logf("Warning: Could not read %s/robots.txt (%v), so nothing there is loaded\n", origin, err)
+	} else {
+		rules = parseRobots(body, robotsAgent)
+	}
+	p.hosts[origin] = rules
+	return rules
+}
+
+// fetch reads an origin's robots.txt, from the cache while it is fresh. A
+// missing one (any 4xx) is an empty file, allowing everything; a server
+// error leaves the site's wishes unknown and is an error.
+func (p *robotsPolicy) fetch(origin string) (string, error) {
+	var cached string
+	if p.dir != "" {
+		sum := sha256.Sum256([]byte(origin))
+		cached = filepath.Join(p.dir, hex.EncodeToString(sum[:12])+".txt")
+		if info, err := os.Stat(cached); err == nil && time.Since(info.ModTime()) < robotsMaxAge {
+			if body, err := os.ReadFile(cached); err == nil {
+				return string(body), nil
+			}
+		}
+	}
+
+	request, err := http.NewRequest("GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("User-Agent", httpUserAgent)
+	reply, err := p.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer reply.Body.Close()
+	var body string
+	switch {
+	case reply.StatusCode >= 500:
+		return "", fmt.Errorf("HTTP %d %s", reply.StatusCode, http.StatusText(reply.StatusCode))
+	case reply.StatusCode < 300:
+		data, err := io.ReadAll(io.LimitReader(reply.Body, robotsMaxSize))
+		if err != nil {
+			return "", err
+		}
+		body = string(data)
+	}
+
+	if cached != "" {
+		if err := os.MkdirAll(p.dir, 0755); err == nil {
+			os.WriteFile(cached, []byte(body), 0644)
+		}
+	}
+	return body, nil
+}
+
+// check returns an error when robots.txt disallows loading target
+func (p *robotsPolicy) check(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("bad URL %q", target)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !p.rules(u).allows(path) {
+		return fmt.Errorf("robots.txt of %s disallows %s", u.Host, target)
+	}
+	return nil
+}
+
+// wait holds off until the host's Crawl-delay has passed since the last
+// load from it, then counts this one
+func (p *robotsPolicy) wait(target string) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return
+	}
+	delay := p.rules(u).crawlDelay
+	p.mu.Lock()
+	last, visited := p.visits[u.Host]
+	p.mu.Unlock()
+	if visited {
+		if remaining := delay - time.Since(last); remaining > 0 {
+			logf("Waiting %s for the Crawl-delay of %s\n", remaining.Round(100*time.Millisecond), u.Host)
+			time.Sleep(remaining)
+		}
+	}
+	p.mu.Lock()
+	p.visits[u.Host] = time.Now()
+	p.mu.Unlock()
+}
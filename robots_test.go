@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := `# Everyone
+User-agent: *
+Disallow: /private/
+Allow: /private/press/
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 2
+
+User-agent: badbot
+User-agent: otherbot
+Disallow: /
+`
+	rules := parseRobots(body, robotsAgent)
+	if rules.crawlDelay != 2*time.Second {
+		t.Errorf("Expected a 2s crawl delay, got %s", rules.crawlDelay)
+	}
+	cases := map[string]bool{
+		"/":                   true,
+		"/private/":           false,
+		"/private/report":     false,
+		"/private/press/2024": true,
+		"/docs/guide.pdf":     false,
+		"/docs/guide.pdf?v=2": true,
+		"/search?q=elixir":    false,
+		"/search":             true,
+		"/robots.txt":         true,
+	}
+	for path, expected := range cases {
+		if got := rules.allows(path); got != expected {
+			t.Errorf("allows(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+
+	// A group naming web replaces the * group
+	rules = parseRobots(body+"\nUser-agent: Web\nDisallow: /drafts\n", robotsAgent)
+	if rules.allows("/drafts/1") || !rules.allows("/private/") {
+		t.Errorf("Expected web's own group to apply, got %+v", rules)
+	}
+	// Both of the consecutive User-agent lines get the group
+	if parseRobots(body, "otherbot").allows("/") {
+		t.Error("Expected otherbot to be disallowed everything")
+	}
+	if !parseRobots("", robotsAgent).allows("/anything") {
+		t.Error("Expected an empty robots.txt to allow everything")
+	}
+}
+
+func TestRobotsPolicy(t *testing.T) {
+	t.Setenv("WEB_DATA_DIR", t.TempDir())
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetches++
+			w.Write([]byte("User-agent: *\nDisallow: /admin\n"))
+		}
+	}))
+	defer server.Close()
+
+	policy := newRobotsPolicy()
+	if err := policy.check(server.URL + "/docs"); err != nil {
+		t.Errorf("Expected /docs to be allowed, got %v", err)
+	}
+	if err := policy.check(server.URL + "/admin/users"); err == nil || !strings.Contains(err.Error(), "disallows") {
+		t.Errorf("Expected /admin/users to be disallowed, got %v", err)
+	}
+	// Later runs read it from the cache
+	if err := newRobotsPolicy().check(server.URL + "/admin"); err == nil {
+		t.Error("Expected /admin to be disallowed")
+	}
+	if fetches != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", fetches)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := policy.check(failing.URL + "/"); err == nil {
+		t.Error("Expected a site whose robots.txt fails to be disallowed")
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if err := policy.check(missing.URL + "/anything"); err != nil {
+		t.Errorf("Expected a site without robots.txt to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tebeka/selenium"
+)
+
+// Runner owns a browser and its driver across many requests, so each one
+// doesn't pay for starting them. The browser opens on first use and is lent
+// to one request at a time; closing it is up to the owner, which may reopen
+// it, e.g. to keep memory in check over long runs.
+type Runner struct {
+	config Config
+	wd     selenium.WebDriver
+	stop   func()
+	// pages maps each --session name to the window left open for it
+	pages map[string]string
+}
+
+func newRunner(config Config) *Runner {
+	return &Runner{config: config}
+}
+
+// start opens the browser, unless it is open already
+func (r *Runner) start() error {
+	if r.wd != nil {
+		return nil
+	}
+	stopBrowser, wd, err := startBrowser(r.config)
+	if err != nil {
+		return err
+	}
+	// Stopped either by the owner or by a request aborting, whichever is first
+	var once sync.Once
+	r.wd, r.stop, r.pages = wd, func() { once.Do(stopBrowser) }, map[string]string{}
+	return nil
+}
+
+// close stops the browser, if one is open
+func (r *Runner) close() {
+	if r.wd != nil {
+		r.stop()
+		r.wd, r.pages = nil, nil
+	}
+}
+
+// alive reports whether the browser is open and still answering
+func (r *Runner) alive() bool {
+	if r.wd == nil {
+		return false
+	}
+	_, err := r.wd.CurrentURL()
+	return err == nil
+}
+
+// usePage switches to the window kept for a --session, opening one when the
+// session is new. Resuming without a URL needs the page to be there.
+func (r *Runner) usePage(name string, resume bool) error {
+	if handle, ok := r.pages[name]; ok {
+		if err := r.wd.SwitchWindow(handle); err == nil {
+			return nil
+		}
+		// The page closed itself
+		delete(r.pages, name)
+	}
+	if resume {
+		return fmt.Errorf("session %q has no open page, start it with a URL", name)
+	}
+
+	handle, err := newWindow(r.wd)
+	if err != nil {
+		return fmt.Errorf("could not open a window for session %q: %v", name, err)
+	}
+	if err := r.wd.SwitchWindow(handle); err != nil {
+		return fmt.Errorf("could not open a window for session %q: %v", name, err)
+	}
+	r.pages[name] = handle
+	return nil
+}
+
+// newWindow opens a blank tab and returns its handle
+func newWindow(wd selenium.WebDriver) (string, error) {
+	reply, err := webDriverRequest(wd, "POST", "/window/new", map[string]interface{}{"type": "tab"})
+	if err != nil {
+		return "", err
+	}
+	var window struct {
+		Handle string `json:"handle"`
+	}
+	if err := json.Unmarshal(reply, &window); err != nil || window.Handle == "" {
+		return "", fmt.Errorf("bad new window response: %s", reply)
+	}
+	return window.Handle, nil
+}
+
+// borrow lends the browser to a request. The returned release function leaves
+// it on a blank page with a single window besides the --session pages for the
+// next request, and abort closes the browser when the request overruns
+// --max-runtime.
+func (r *Runner) borrow(config Config) (selenium.WebDriver, func(), func()) {
+	wd := r.wd
+	if config.NavTimeout > 0 {
+		if err := wd.SetPageLoadTimeout(config.NavTimeout); err != nil {
+			logf("Warning: Could not set navigation timeout: %v\n", err)
+		}
+	}
+
+	release := func() {
+		handles, err := wd.WindowHandles()
+		if err != nil {
+			return
+		}
+		kept := map[string]bool{}
+		for name, handle := range r.pages {
+			if slices.Contains(handles, handle) {
+				kept[handle] = true
+			} else {
+				delete(r.pages, name)
+			}
+		}
+
+		blank := ""
+		for _, handle := range handles {
+			if kept[handle] {
+				continue
+			}
+			if blank == "" {
+				blank = handle
+			} else if wd.SwitchWindow(handle) == nil {
+				wd.CloseWindow(handle)
+			}
+		}
+		// Only --session pages are left, the next request needs a window of its own
+		if blank == "" {
+			if blank, err = newWindow(wd); err != nil {
+				return
+			}
+		}
+		wd.SwitchWindow(blank)
+		wd.Get("about:blank")
+	}
+	abort := func() {
+		// Quitting makes the request's next command fail, ending its run
+		r.stop()
+	}
+	return wd, release, abort
+}
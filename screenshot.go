@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// Clip is a region of a screenshot in CSS pixels, for --clip
+type Clip struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// parseClip reads a --clip value: x,y,width,height
+func parseClip(value string) (*Clip, error) {
+	parts := strings.Split(value, ",")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			numbers = nil
+			break
+		}
+		numbers[i] = n
+	}
+	if len(numbers) != 4 || numbers[2] == 0 || numbers[3] == 0 {
+		return nil, fmt.Errorf("--clip expects x,y,width,height in pixels, e.g. 0,0,800,600, got %q", value)
+	}
+	return &Clip{X: numbers[0], Y: numbers[1], Width: numbers[2], Height: numbers[3]}, nil
+}
+
+// screenshotType is the image type to save a screenshot as, from its extension
+func screenshotType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// encodeScreenshotJS crops a PNG screenshot and re-encodes it with the
+// browser's own canvas encoders, which cover JPEG and WebP. The image is
+// decoded from a blob rather than a data: URL so page CSP doesn't apply.
+const encodeScreenshotJS = `
+	var done = arguments[arguments.length - 1];
+	var png = atob(arguments[0]), clip = arguments[1], type = arguments[2], quality = arguments[3];
+	var bytes = new Uint8Array(png.length);
+	for (var i = 0; i < png.length; i++) { bytes[i] = png.charCodeAt(i); }
+
+	createImageBitmap(new Blob([bytes], { type: 'image/png' })).then(function(bitmap) {
+		// Screenshots are in device pixels, clips in CSS pixels
+		var ratio = window.devicePixelRatio || 1;
+		var x = 0, y = 0, width = bitmap.width, height = bitmap.height;
+		if (clip) {
+			x = Math.round(clip.x * ratio);
+			y = Math.round(clip.y * ratio);
+			width = Math.min(Math.round(clip.width * ratio), bitmap.width - x);
+			height = Math.min(Math.round(clip.height * ratio), bitmap.height - y);
+		}
+		if (width <= 0 || height <= 0) {
+			done({ error: 'the clip region is outside the screenshot (' + Math.round(bitmap.width / ratio) + 'x' + Math.round(bitmap.height / ratio) + ')' });
+			return;
+		}
+
+		var canvas = document.createElement('canvas');
+		canvas.width = width;
+		canvas.height = height;
+		var context = canvas.getContext('2d');
+		if (type === 'image/jpeg') {
+			// JPEG has no transparency, which would otherwise turn black
+			context.fillStyle = '#fff';
+			context.fillRect(0, 0, width, height);
+		}
+		context.drawImage(bitmap, x, y, width, height, 0, 0, width, height);
+		var url = quality > 0 ? canvas.toDataURL(type, quality / 100) : canvas.toDataURL(type);
+		if (url.indexOf('data:' + type) !== 0) {
+			done({ error: type + ' encoding is not supported by this browser' });
+			return;
+		}
+		done({ data: url.slice(url.indexOf(',') + 1) });
+	}, function(e) {
+		done({ error: 'could not decode screenshot: ' + e });
+	});
+`
+
+// takeScreenshot captures the page as --screenshot and its options ask:
+// the whole page unless --screenshot-viewport, cropped to --clip, and in
+// the format of the path's extension. elements, when given, are outlined and
+// numbered on it.
+func takeScreenshot(wd selenium.WebDriver, config Config, elements []Element) ([]byte, error) {
+	if elements != nil {
+		if _, err := wd.ExecuteScript(annotateElementsJS, []interface{}{elements}); err != nil {
+			return nil, fmt.Errorf("could not annotate elements: %v", err)
+		}
+		defer wd.ExecuteScript(removeAnnotationsJS, nil)
+	}
+
+	var screenshot []byte
+	if config.Engine == "webkit" && !config.ScreenshotViewport {
+		logf("Warning: WebKit can only capture the viewport, the screenshot won't include the whole page\n")
+	}
+	if config.ScreenshotViewport || config.Engine == "webkit" {
+		var err error
+		if screenshot, err = wd.Screenshot(); err != nil {
+			return nil, err
+		}
+	} else if config.Engine == "chromium" {
+		var err error
+		if screenshot, err = chromiumFullScreenshot(wd); err != nil {
+			return nil, err
+		}
+	} else {
+		raw, err := webDriverRequest(wd, "GET", "/moz/screenshot/full", nil)
+		if err != nil {
+			return nil, err
+		}
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return nil, fmt.Errorf("bad screenshot response: %v", err)
+		}
+		if screenshot, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("bad screenshot response: %v", err)
+		}
+	}
+
+	imageType := screenshotType(config.ScreenshotPath)
+	if config.Clip == nil && imageType == "image/png" {
+		return screenshot, nil
+	}
+	raw, err := wd.ExecuteScriptAsync(encodeScreenshotJS, []interface{}{
+		base64.StdEncoding.EncodeToString(screenshot), config.Clip, imageType, config.ScreenshotQuality,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode screenshot: %v", err)
+	}
+	result, _ := raw.(map[string]interface{})
+	if message, ok := result["error"].(string); ok {
+		return nil, fmt.Errorf("%s", message)
+	}
+	data, _ := result["data"].(string)
+	return base64.StdEncoding.DecodeString(data)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseClip(t *testing.T) {
+	clip, err := parseClip("10, 20,800,600")
+	if err != nil || *clip != (Clip{X: 10, Y: 20, Width: 800, Height: 600}) {
+		t.Errorf("parseClip = %+v, %v", clip, err)
+	}
+	for _, value := range []string{"10,20,800", "0,0,0,600", "-1,0,10,10", "a,b,c,d", "1,2,3,4,5"} {
+		if _, err := parseClip(value); err == nil {
+			t.Errorf("parseClip(%q) should fail", value)
+		}
+	}
+}
+
+func TestScreenshotType(t *testing.T) {
+	for path, expected := range map[string]string{
+		"shot.png":      "image/png",
+		"shot.JPG":      "image/jpeg",
+		"out/shot.jpeg": "image/jpeg",
+		"shot.webp":     "image/webp",
+		"shot":          "image/png",
+		"shot.png.webp": "image/webp",
+	} {
+		if got := screenshotType(path); got != expected {
+			t.Errorf("screenshotType(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"gopkg.in/yaml.v3"
+)
+
+// ScriptStep is one entry of a --script file. Exactly one of the action keys
+// (goto, fill, click, wait, screenshot, extract, store, assert, pause) must be set per step.
+type ScriptStep struct {
+	Goto       string `yaml:"goto,omitempty"`
+	Fill       string `yaml:"fill,omitempty"`
+	Value      string `yaml:"value,omitempty"`
+	Click      string `yaml:"click,omitempty"`
+	Wait       string `yaml:"wait,omitempty"`
+	Screenshot string `yaml:"screenshot,omitempty"`
+	Extract    string `yaml:"extract,omitempty"`
+	Store      string `yaml:"store,omitempty"`
+	As         string `yaml:"as,omitempty"`
+	Assert     string `yaml:"assert,omitempty"`
+	Contains   string `yaml:"contains,omitempty"`
+	Pause      string `yaml:"pause,omitempty"`
+}
+
+// StepResult records the outcome of a script step for the output report
+type StepResult struct {
+	Step   ScriptStep
+	Err    error
+	Output string
+}
+
+var scriptVariable = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// loadScript reads a YAML or JSON step list (JSON is valid YAML), substituting
+// ${NAME} references with --var values or environment variables
+func loadScript(path string, vars map[string]string) ([]ScriptStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read script: %v", err)
+	}
+
+	var steps []ScriptStep
+	if err := yaml.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("could not parse script %s: %v", path, err)
+	}
+
+	stored := map[string]bool{}
+	for i := range steps {
+		if err := steps[i].substituteVars(vars); err != nil {
+			return nil, fmt.Errorf("script step %d: %v", i+1, err)
+		}
+		step := steps[i]
+		kind, err := step.kind()
+		if err != nil {
+			return nil, fmt.Errorf("script step %d: %v", i+1, err)
+		}
+		if kind == "store" {
+			if step.As == "" {
+				return nil, fmt.Errorf("script step %d: store requires an 'as' name", i+1)
+			}
+			stored[step.As] = true
+		}
+		// Catch typos in {{name}} references before anything runs
+		for _, value := range []string{step.Goto, step.Value, step.Click, step.Assert, step.Contains} {
+			for _, match := range scriptVariable.FindAllStringSubmatch(value, -1) {
+				if !stored[match[1]] {
+					return nil, fmt.Errorf("script step %d: {{%s}} is used before it is stored", i+1, match[1])
+				}
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// kind returns the action name of the step, validating that exactly one is set
+func (step ScriptStep) kind() (string, error) {
+	var kinds []string
+	for name, value := range map[string]string{
+		"goto":       step.Goto,
+		"fill":       step.Fill,
+		"click":      step.Click,
+		"wait":       step.Wait,
+		"screenshot": step.Screenshot,
+		"extract":    step.Extract,
+		"store":      step.Store,
+		"assert":     step.Assert,
+		"pause":      step.Pause,
+	} {
+		if value != "" {
+			kinds = append(kinds, name)
+		}
+	}
+
+	switch len(kinds) {
+	case 0:
+		return "", fmt.Errorf("step has no action (expected one of goto, fill, click, wait, screenshot, extract, store, assert, pause)")
+	case 1:
+		return kinds[0], nil
+	default:
+		return "", fmt.Errorf("step has multiple actions: %s", strings.Join(kinds, ", "))
+	}
+}
+
+func (step ScriptStep) String() string {
+	kind, _ := step.kind()
+	switch kind {
+	case "goto":
+		return "goto " + step.Goto
+	case "fill":
+		return fmt.Sprintf("fill %s", step.Fill)
+	case "click":
+		return "click " + step.Click
+	case "wait":
+		return "wait " + step.Wait
+	case "screenshot":
+		return "screenshot " + step.Screenshot
+	case "extract":
+		return "extract " + step.Extract
+	case "store":
+		return fmt.Sprintf("store %s as %s", step.Store, step.As)
+	case "assert":
+		if step.Contains != "" {
+			return fmt.Sprintf("assert %s contains %q", step.Assert, step.Contains)
+		}
+		return "assert " + step.Assert
+	case "pause":
+		return "pause " + step.Pause
+	}
+	return "invalid step"
+}
+
+// expand substitutes {{name}} references with values stored by earlier steps
+func (step ScriptStep) expand(vars map[string]string) ScriptStep {
+	replace := func(value string) string {
+		return scriptVariable.ReplaceAllStringFunc(value, func(ref string) string {
+			return vars[scriptVariable.FindStringSubmatch(ref)[1]]
+		})
+	}
+	step.Goto = replace(step.Goto)
+	step.Value = replace(step.Value)
+	step.Click = replace(step.Click)
+	step.Assert = replace(step.Assert)
+	step.Contains = replace(step.Contains)
+	return step
+}
+
+// substituteVars expands ${NAME} references in every field of the step
+func (step *ScriptStep) substituteVars(vars map[string]string) error {
+	for _, field := range []*string{
+		&step.Goto, &step.Fill, &step.Value, &step.Click, &step.Wait, &step.Screenshot,
+		&step.Extract, &step.Store, &step.As, &step.Assert, &step.Contains,
+	} {
+		expanded, err := expandVars(*field, vars, false)
+		if err != nil {
+			return err
+		}
+		*field = expanded
+	}
+	return nil
+}
+
+// runScript executes the steps in order in the current browser session. It stops
+// at the first failing action and returns the results gathered so far. Failed
+// assertions are recorded but don't stop the run, so one pass reports every
+// check across all the pages the script visits.
+func runScript(wd selenium.WebDriver, config Config, steps []ScriptStep, isLiveView bool) ([]StepResult, bool, error) {
+	var results []StepResult
+	vars := map[string]string{}
+	failedAssertions := 0
+
+	for i, step := range steps {
+		step = step.expand(vars)
+		slowDown(config)
+		output, err := runScriptStep(wd, config, step, &isLiveView)
+		results = append(results, StepResult{Step: step, Err: err, Output: output})
+
+		kind, _ := step.kind()
+		switch {
+		case err != nil && kind == "assert":
+			failedAssertions++
+		case err != nil:
+			return results, isLiveView, fmt.Errorf("script step %d (%s) failed: %v", i+1, step, err)
+		case kind == "store":
+			vars[step.As] = output
+		}
+	}
+
+	if failedAssertions > 0 {
+		return results, isLiveView, fmt.Errorf("%d script assertion(s) failed", failedAssertions)
+	}
+	return results, isLiveView, nil
+}
+
+func runScriptStep(wd selenium.WebDriver, config Config, step ScriptStep, isLiveView *bool) (string, error) {
+	kind, _ := step.kind()
+
+	switch kind {
+	case "goto":
+		url := ensureProtocol(step.Goto)
+		if err := navigate(wd, config, url); err != nil {
+			return "", err
+		}
+		*isLiveView = preparePage(wd, config)
+
+	case "fill":
+		if err := fillField(wd, config, step.Fill, step.Value); err != nil {
+			return "", err
+		}
+
+	case "click":
+		if err := clickAndWait(wd, config, step.Click, isLiveView); err != nil {
+			return "", err
+		}
+
+	case "wait":
+		// Durations sleep, anything else is treated as a selector to wait for
+		if duration, err := time.ParseDuration(step.Wait); err == nil {
+			time.Sleep(duration)
+		} else if err := waitForSelector(wd, step.Wait, config.ActionTimeout); err != nil {
+			return "", fmt.Errorf("timed out after %s waiting for %s (raise with --action-timeout)", config.ActionTimeout, step.Wait)
+		}
+
+	case "screenshot":
+		screenshot, err := wd.Screenshot()
+		if err != nil {
+			return "", fmt.Errorf("error taking screenshot: %v", err)
+		}
+		if err := os.WriteFile(step.Screenshot, screenshot, 0644); err != nil {
+			return "", fmt.Errorf("error saving screenshot: %v", err)
+		}
+		config.Manifest.addFile("screenshot", step.Screenshot)
+		return fmt.Sprintf("saved to %s", step.Screenshot), nil
+
+	case "extract":
+		return extractMarkdown(wd, config, step.Extract)
+
+	case "store":
+		elem, err := waitForElement(wd, config, step.Store)
+		if err != nil {
+			return "", fmt.Errorf("no elements match %s", step.Store)
+		}
+		text, err := elem.Text()
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %v", step.Store, err)
+		}
+		return strings.TrimSpace(text), nil
+
+	case "assert":
+		// Waiting keeps checks made right after a LiveView update from racing the patch
+		return "", assertText(wd, config, step.Assert, step.Contains, config.ActionTimeout)
+
+	case "pause":
+		pauseRun(config, step.Pause)
+	}
+
+	return "", nil
+}
+
+// assertText waits until an element matching selector exists and, when text is
+// given, until one of the matches contains it
+func assertText(wd selenium.WebDriver, config Config, selector, text string, timeout time.Duration) error {
+	err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		elems, err := findElements(wd, config, selector)
+		if err != nil {
+			return false, nil
+		}
+		for _, elem := range elems {
+			if text == "" {
+				return true, nil
+			}
+			if content, err := elem.Text(); err == nil && strings.Contains(content, text) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, timeout)
+	if err == nil {
+		return nil
+	}
+
+	if text == "" {
+		return fmt.Errorf("no elements match %s", selector)
+	}
+	return fmt.Errorf("no element matching %s contains %q", selector, text)
+}
+
+// extractMarkdown converts every element matching selector to markdown
+func extractMarkdown(wd selenium.WebDriver, config Config, selector string) (string, error) {
+	elems, err := findElements(wd, config, selector)
+	if err != nil || len(elems) == 0 {
+		return "", fmt.Errorf("no elements match %s", selector)
+	}
+	var parts []string
+	for _, elem := range elems {
+		html, err := outerHTML(wd, config, elem)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %v", selector, err)
+		}
+		text, err := htmlToMarkdown(html)
+		if err != nil {
+			return "", fmt.Errorf("could not convert %s: %v", selector, err)
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+func formatScriptResults(results []StepResult) string {
+	var b strings.Builder
+	assertions, passed := 0, 0
+	for i, result := range results {
+		status := "ok"
+		if result.Err != nil {
+			status = "FAILED: " + result.Err.Error()
+		}
+		fmt.Fprintf(&b, "[%d] %s ... %s\n", i+1, result.Step, status)
+
+		kind, _ := result.Step.kind()
+		if kind == "assert" {
+			assertions++
+			if result.Err == nil {
+				passed++
+			}
+		}
+		if kind == "extract" && result.Output != "" {
+			fmt.Fprintf(&b, "%s\n", result.Output)
+		} else if result.Output != "" && result.Err == nil {
+			fmt.Fprintf(&b, "    %s\n", result.Output)
+		}
+	}
+
+	// Consolidated verdict for end-to-end checks
+	if assertions > 0 {
+		verdict := "PASS"
+		if passed < assertions {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(&b, "\n%s: %d of %d assertions passed\n", verdict, passed, assertions)
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadScript(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "steps.yaml")
+	os.WriteFile(yamlPath, []byte(`
+- goto: localhost:4000/login
+- fill: "#email"
+  value: foo@bar.com
+- click: "button[type=submit]"
+- wait: 500ms
+- pause: after login
+`), 0644)
+
+	steps, err := loadScript(yamlPath, nil)
+	if err != nil {
+		t.Fatalf("Failed to load YAML script: %v", err)
+	}
+	if len(steps) != 5 {
+		t.Fatalf("Expected 5 steps, got %d", len(steps))
+	}
+	if steps[1].Fill != "#email" || steps[1].Value != "foo@bar.com" {
+		t.Errorf("Fill step parsed incorrectly: %+v", steps[1])
+	}
+	if steps[4].String() != "pause after login" {
+		t.Errorf("Pause step parsed incorrectly: %+v", steps[4])
+	}
+
+	jsonPath := filepath.Join(dir, "steps.json")
+	os.WriteFile(jsonPath, []byte(`[{"goto": "example.com"}, {"extract": "#main"}]`), 0644)
+
+	steps, err = loadScript(jsonPath, nil)
+	if err != nil {
+		t.Fatalf("Failed to load JSON script: %v", err)
+	}
+	if len(steps) != 2 || steps[1].Extract != "#main" {
+		t.Errorf("JSON script parsed incorrectly: %+v", steps)
+	}
+}
+
+func TestLoadScriptRejectsAmbiguousSteps(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(path, []byte(`
+- goto: example.com
+- click: "#a"
+  extract: "#b"
+`), 0644)
+
+	_, err := loadScript(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "script step 2: step has multiple actions") {
+		t.Errorf("Expected multiple actions error for step 2, got: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`[{"value": "orphan"}]`), 0644)
+	_, err = loadScript(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "step has no action") {
+		t.Errorf("Expected no action error, got: %v", err)
+	}
+}
+
+func TestLoadScriptVariables(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "steps.yaml")
+	os.WriteFile(path, []byte(`
+- goto: localhost:4000/posts/new
+- store: "#post-title"
+- assert: "#posts"
+`), 0644)
+	_, err := loadScript(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "script step 2: store requires an 'as' name") {
+		t.Errorf("Expected missing 'as' error, got: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`
+- assert: "#posts"
+  contains: "{{title}}"
+- store: "#post-title"
+  as: title
+`), 0644)
+	_, err = loadScript(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "script step 1: {{title}} is used before it is stored") {
+		t.Errorf("Expected undefined variable error, got: %v", err)
+	}
+}
+
+func TestLoadScriptSubstitutesVars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.yaml")
+	os.WriteFile(path, []byte(`
+- goto: ${BASE_URL}/users/log-in
+- fill: "#user_password"
+  value: ${WEB_TEST_PASSWORD}
+`), 0644)
+
+	t.Setenv("WEB_TEST_PASSWORD", "from-env")
+	steps, err := loadScript(path, map[string]string{"BASE_URL": "localhost:4000"})
+	if err != nil {
+		t.Fatalf("loadScript returned error: %v", err)
+	}
+	if steps[0].Goto != "localhost:4000/users/log-in" || steps[1].Value != "from-env" {
+		t.Errorf("Variables substituted incorrectly: %+v", steps)
+	}
+
+	_, err = loadScript(path, nil)
+	if err == nil || !strings.Contains(err.Error(), "script step 1: undefined variable ${BASE_URL}") {
+		t.Errorf("Expected undefined variable error, got: %v", err)
+	}
+}
+
+func TestScriptStepExpand(t *testing.T) {
+	step := ScriptStep{Assert: "#posts", Contains: "Title: {{ title }}"}
+	expanded := step.expand(map[string]string{"title": "Hello"})
+	if expanded.Contains != "Title: Hello" {
+		t.Errorf("expand() contains = %q, expected %q", expanded.Contains, "Title: Hello")
+	}
+	if step.Contains != "Title: {{ title }}" {
+		t.Errorf("expand() modified the original step")
+	}
+}
+
+func TestFormatScriptResultsVerdict(t *testing.T) {
+	results := []StepResult{
+		{Step: ScriptStep{Goto: "localhost:4000/posts"}},
+		{Step: ScriptStep{Assert: "#posts", Contains: "Hello"}},
+		{Step: ScriptStep{Assert: "#flash"}, Err: errors.New("no elements match #flash")},
+	}
+
+	output := formatScriptResults(results)
+	if !strings.Contains(output, "[3] assert #flash ... FAILED: no elements match #flash") {
+		t.Errorf("Expected failed assertion in report. Got:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "FAIL: 1 of 2 assertions passed\n") {
+		t.Errorf("Expected consolidated verdict. Got:\n%s", output)
+	}
+}
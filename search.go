@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const DEFAULT_SEARCH_RESULTS = 10
+
+// SearchEngine describes how to query a search engine and where its results live in the page
+type SearchEngine struct {
+	URL             string
+	ResultSelector  string
+	TitleSelector   string
+	SnippetSelector string
+}
+
+var searchEngines = map[string]SearchEngine{
+	"duckduckgo": {
+		URL:             "https://html.duckduckgo.com/html/?q=%s",
+		ResultSelector:  ".result:not(.result--ad)",
+		TitleSelector:   "a.result__a",
+		SnippetSelector: ".result__snippet",
+	},
+	"bing": {
+		URL:             "https://www.bing.com/search?q=%s",
+		ResultSelector:  "li.b_algo",
+		TitleSelector:   "h2 a",
+		SnippetSelector: ".b_caption p",
+	},
+	"google": {
+		URL:             "https://www.google.com/search?q=%s&hl=en",
+		ResultSelector:  "div.g",
+		TitleSelector:   "a:has(h3)",
+		SnippetSelector: "[data-sncf], .VwiC3b",
+	},
+}
+
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+	// Content is the page scraped with --fetch
+	Content string `json:"content,omitempty"`
+}
+
+type SearchConfig struct {
+	Query         string
+	Engine        string
+	Results       int
+	Fetch         int
+	JSONFlag      bool
+	Profile       string
+	TruncateAfter int
+}
+
+// runSearch implements `web search <query>` and returns the process exit code
+func runSearch(args []string) int {
+	config := SearchConfig{
+		Engine:        "duckduckgo",
+		Results:       DEFAULT_SEARCH_RESULTS,
+		Profile:       "default",
+		TruncateAfter: DEFAULT_TRUNCATE_AFTER,
+	}
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printSearchHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--json", kind: flagBool, target: &config.JSONFlag},
+		{name: "--engine", kind: flagString, target: &config.Engine},
+		{name: "--results", kind: flagInt, target: &config.Results},
+		{name: "--fetch", kind: flagInt, target: &config.Fetch},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		dataDirFlag,
+		{name: "--truncate-after", kind: flagInt, target: &config.TruncateAfter},
+	}
+
+	err := parseFlags(args, defs, func(arg string) error {
+		if config.Query != "" {
+			return fmt.Errorf("unexpected argument %q (quote multi-word queries)", arg)
+		}
+		config.Query = arg
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web search --help' for usage.\n", err)
+		return 1
+	}
+
+	if config.Query == "" {
+		printSearchHelp()
+		return 1
+	}
+
+	if _, ok := searchEngines[config.Engine]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown search engine %q (available: duckduckgo, bing, google)\n", config.Engine)
+		return 1
+	}
+
+	ensureBrowser(Config{})
+
+	// One browser serves the search and every fetched result
+	browserConfig := newConfig()
+	browserConfig.Profile = config.Profile
+	runner := newRunner(browserConfig)
+	if err := runner.start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+	defer runner.close()
+
+	results, err := search(config, runner)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching: %v\n", err)
+		return 1
+	}
+
+	// Optionally scrape the top results with the regular pipeline
+	for i := 0; i < config.Fetch && i < len(results); i++ {
+		fetchConfig := newConfig()
+		fetchConfig.URL = results[i].URL
+		fetchConfig.Profile = config.Profile
+		fetchConfig.TruncateAfter = config.TruncateAfter
+		fetchConfig.Runner = runner
+		content, err := processRequest(fetchConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not fetch %s: %v\n", results[i].URL, err)
+			continue
+		}
+		results[i].Content = content
+	}
+
+	if config.JSONFlag {
+		encoded, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding results: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(encoded))
+		return 0
+	}
+
+	fmt.Println(formatSearchResults(config, results))
+	for _, result := range results {
+		if result.Content != "" {
+			fmt.Println()
+			fmt.Println(result.Content)
+		}
+	}
+
+	return 0
+}
+
+// search runs the query in the runner's browser and extracts the result list
+func search(config SearchConfig, runner *Runner) ([]SearchResult, error) {
+	engine := searchEngines[config.Engine]
+
+	wd, release, _ := runner.borrow(runner.config)
+	defer release()
+
+	searchURL := fmt.Sprintf(engine.URL, url.QueryEscape(config.Query))
+	if err := wd.Get(searchURL); err != nil {
+		return nil, fmt.Errorf("could not navigate to %s: %v", searchURL, err)
+	}
+
+	if err := waitForSelector(wd, engine.ResultSelector, 10*time.Second); err != nil {
+		return nil, fmt.Errorf("no results found for %q on %s", config.Query, config.Engine)
+	}
+
+	raw, err := wd.ExecuteScript(`
+		var engine = arguments[0];
+		return Array.prototype.map.call(document.querySelectorAll(engine.result), function(el) {
+			var link = el.querySelector(engine.title);
+			var snippet = el.querySelector(engine.snippet);
+			return {
+				title: link ? link.innerText.trim() : '',
+				url: link ? link.href : '',
+				snippet: snippet ? snippet.innerText.trim() : ''
+			};
+		});
+	`, []interface{}{map[string]string{
+		"result":  engine.ResultSelector,
+		"title":   engine.TitleSelector,
+		"snippet": engine.SnippetSelector,
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("could not extract results: %v", err)
+	}
+
+	var results []SearchResult
+	seen := map[string]bool{}
+	if items, ok := raw.([]interface{}); ok {
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			title, _ := entry["title"].(string)
+			href, _ := entry["url"].(string)
+			snippet, _ := entry["snippet"].(string)
+
+			href = unwrapSearchRedirect(href)
+			if title == "" || href == "" || seen[href] {
+				continue
+			}
+			seen[href] = true
+
+			results = append(results, SearchResult{Title: title, URL: href, Snippet: snippet})
+			if len(results) >= config.Results {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// unwrapSearchRedirect resolves tracking redirect links (e.g. duckduckgo.com/l/?uddg=...) to their target
+func unwrapSearchRedirect(href string) string {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	if !strings.HasSuffix(parsed.Path, "/l/") && parsed.Path != "/url" {
+		return href
+	}
+	for _, key := range []string{"uddg", "url", "q"} {
+		if target := parsed.Query().Get(key); strings.HasPrefix(target, "http") {
+			return target
+		}
+	}
+	return href
+}
+
+func formatSearchResults(config SearchConfig, results []SearchResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "==========================\nSearch: %s (%s)\n==========================\n", config.Query, config.Engine)
+
+	if len(results) == 0 {
+		b.WriteString("\nNo results found")
+		return b.String()
+	}
+
+	for i, result := range results {
+		fmt.Fprintf(&b, "\n%d. [%s](%s)\n", i+1, result.Title, result.URL)
+		if result.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", result.Snippet)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func printSearchHelp() {
+	fmt.Printf(`web search - search the web and extract results
+
+Usage: web search <query> [options]
+
+Options:
+  --help                     Show this help message
+  --engine <name>            Search engine to use: duckduckgo, bing, google (default: duckduckgo)
+  --results <number>         Maximum number of results to return (default: %d)
+  --fetch <number>           Also scrape the top <number> results and print their content (as "content"
+                             with --json)
+  --json                     Output results as JSON instead of a markdown list
+  --profile <name>           Use or create named session profile (default: "default")
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --truncate-after <number>  Truncate fetched pages after <number> characters (default: %d)
+
+Examples:
+  web search "phoenix liveview streams"
+  web search "elixir genstage" --engine bing --results 5 --json
+  web search "go context cancellation" --fetch 2 --truncate-after 5000
+`, DEFAULT_SEARCH_RESULTS, DEFAULT_TRUNCATE_AFTER)
+}
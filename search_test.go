@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnwrapSearchRedirect(t *testing.T) {
+	cases := map[string]string{
+		"https://duckduckgo.com/l/?uddg=https%3A%2F%2Fhexdocs.pm%2Fphoenix&rut=abc": "https://hexdocs.pm/phoenix",
+		"https://www.google.com/url?q=https://elixir-lang.org/&sa=U":                "https://elixir-lang.org/",
+		"https://example.com/l/":                       "https://example.com/l/",
+		"https://example.com/page?q=https://other.com": "https://example.com/page?q=https://other.com",
+	}
+
+	for input, expected := range cases {
+		if got := unwrapSearchRedirect(input); got != expected {
+			t.Errorf("unwrapSearchRedirect(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestFormatSearchResults(t *testing.T) {
+	config := SearchConfig{Query: "phoenix", Engine: "duckduckgo"}
+	output := formatSearchResults(config, []SearchResult{
+		{Title: "Phoenix Framework", URL: "https://phoenixframework.org", Snippet: "Peace of mind from prototype to production"},
+		{Title: "Phoenix on Hexdocs", URL: "https://hexdocs.pm/phoenix"},
+	})
+
+	expected := []string{
+		"Search: phoenix (duckduckgo)",
+		"1. [Phoenix Framework](https://phoenixframework.org)",
+		"   Peace of mind from prototype to production",
+		"2. [Phoenix on Hexdocs](https://hexdocs.pm/phoenix)",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("Search output missing %q. Got: %s", want, output)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// waitUntilStates are the values accepted by --wait-until
+var waitUntilStates = []string{"load", "domcontentloaded", "networkidle"}
+
+// networkIdleQuiet is how long no requests may be in flight before the network counts as idle
+const networkIdleQuiet = 500 * time.Millisecond
+
+// trackNetworkActivity counts in-flight fetch/XHR requests and records when the
+// last request of any kind (including images, scripts and styles reported by
+// Resource Timing) finished
+func trackNetworkActivity(wd selenium.WebDriver) error {
+	_, err := wd.ExecuteScript(`
+		if (!window.__networkState) {
+			var state = window.__networkState = { inflight: 0, lastActivity: performance.now() };
+			function start() { state.inflight++; state.lastActivity = performance.now(); }
+			function finish() { state.inflight = Math.max(0, state.inflight - 1); state.lastActivity = performance.now(); }
+
+			var originalFetch = window.fetch;
+			if (originalFetch) {
+				window.fetch = function() {
+					start();
+					return originalFetch.apply(this, arguments).then(function(response) {
+						finish();
+						return response;
+					}, function(error) {
+						finish();
+						throw error;
+					});
+				};
+			}
+
+			var originalSend = XMLHttpRequest.prototype.send;
+			XMLHttpRequest.prototype.send = function() {
+				start();
+				this.addEventListener('loadend', finish);
+				return originalSend.apply(this, arguments);
+			};
+
+			performance.getEntriesByType('resource').forEach(function(entry) {
+				state.lastActivity = Math.max(state.lastActivity, entry.responseEnd);
+			});
+			new PerformanceObserver(function(list) {
+				list.getEntries().forEach(function(entry) {
+					state.lastActivity = Math.max(state.lastActivity, entry.responseEnd);
+				});
+			}).observe({ type: 'resource' });
+		}
+	`, nil)
+	return err
+}
+
+// settlePage waits until the page reaches the --wait-until state. It is used
+// after the initial navigation and after each interaction.
+func settlePage(wd selenium.WebDriver, config Config) {
+	var err error
+	switch config.WaitUntil {
+	case "domcontentloaded":
+		err = waitForFunction(wd, "return document.readyState !== 'loading'", config.NavTimeout)
+	case "load":
+		err = waitForFunction(wd, "return document.readyState === 'complete'", config.NavTimeout)
+	case "networkidle":
+		if err = waitForFunction(wd, "return document.readyState === 'complete'", config.NavTimeout); err == nil {
+			err = waitForNetworkIdle(wd, config.NavTimeout)
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		logf("Warning: Page did not reach %s: %v\n", config.WaitUntil, err)
+	}
+}
+
+// waitForNetworkIdle waits until no fetch/XHR is in flight and no request has
+// finished for networkIdleQuiet
+func waitForNetworkIdle(wd selenium.WebDriver, timeout time.Duration) error {
+	// Pages prepared before tracking was installed (e.g. after a click loaded a new document)
+	if err := trackNetworkActivity(wd); err != nil {
+		return err
+	}
+	return waitForFunction(wd, fmt.Sprintf(`
+		var state = window.__networkState;
+		return !!state && state.inflight === 0 && performance.now() - state.lastActivity >= %d;
+	`, networkIdleQuiet.Milliseconds()), timeout)
+}
+
+// slowDown pauses for --slow-mo before an interaction, so a --headed run or
+// a recording of it can be followed
+func slowDown(config Config) {
+	if config.SlowMo > 0 {
+		time.Sleep(time.Duration(config.SlowMo) * time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tebeka/selenium"
+)
+
+// deepQueryJS finds elements matching arguments[0] in the document and every
+// open shadow root below it. If nothing matches as a whole, the selector is
+// resolved one descendant part at a time, so "#signup input[name=email]"
+// also finds an input inside the shadow root of a component within #signup.
+const deepQueryJS = `
+	var selector = arguments[0];
+
+	function deepAll(scope, sel) {
+		var found = [];
+		var stack = [scope];
+		if (scope.shadowRoot) {
+			stack.push(scope.shadowRoot);
+		}
+		while (stack.length) {
+			var root = stack.pop();
+			root.querySelectorAll(sel).forEach(function(el) {
+				if (found.indexOf(el) < 0) {
+					found.push(el);
+				}
+			});
+			root.querySelectorAll('*').forEach(function(el) {
+				if (el.shadowRoot) {
+					stack.push(el.shadowRoot);
+				}
+			});
+		}
+		return found;
+	}
+
+	// Split on descendant combinators, keeping "a > b" style parts together
+	function descendantParts(sel) {
+		var tokens = [], current = '', depth = 0, quote = null;
+		for (var i = 0; i < sel.length; i++) {
+			var c = sel[i];
+			if (quote) {
+				if (c === quote) quote = null;
+			} else if (c === '"' || c === "'") {
+				quote = c;
+			} else if (c === '[' || c === '(') {
+				depth++;
+			} else if (c === ']' || c === ')') {
+				depth--;
+			} else if (/\s/.test(c) && depth === 0) {
+				if (current) tokens.push(current);
+				current = '';
+				continue;
+			}
+			current += c;
+		}
+		if (current) tokens.push(current);
+
+		var parts = [];
+		tokens.forEach(function(token) {
+			var last = parts.length - 1;
+			if (last >= 0 && (/^[>+~]/.test(token) || /[>+~]$/.test(parts[last]))) {
+				parts[last] += ' ' + token;
+			} else {
+				parts.push(token);
+			}
+		});
+		return parts;
+	}
+
+	var found = deepAll(document, selector);
+	var parts = descendantParts(selector);
+	if (found.length === 0 && parts.length > 1) {
+		var scopes = [document];
+		parts.forEach(function(part) {
+			var next = [];
+			scopes.forEach(function(scope) {
+				deepAll(scope, part).forEach(function(el) {
+					if (next.indexOf(el) < 0) {
+						next.push(el);
+					}
+				});
+			});
+			scopes = next;
+		});
+		found = scopes;
+	}
+	return found;
+`
+
+// deepHTMLJS serializes arguments[0] (or the whole document) with open shadow
+// roots inlined in place of their hosts' children, filling <slot>s with the
+// light DOM nodes assigned to them, so converted output includes web component content
+const deepHTMLJS = `
+	function serialize(node) {
+		if (node.nodeType === Node.TEXT_NODE) {
+			var div = document.createElement('div');
+			div.textContent = node.textContent;
+			return div.innerHTML;
+		}
+		if (node.nodeType !== Node.ELEMENT_NODE) {
+			return '';
+		}
+		if (node.localName === 'slot') {
+			var assigned = node.assignedNodes();
+			return children(assigned.length ? assigned : node.childNodes);
+		}
+
+		var shallow = node.cloneNode(false).outerHTML;
+		var close = shallow.lastIndexOf('</');
+		var open = close >= 0 ? shallow.slice(0, close) : shallow;
+		var end = close >= 0 ? shallow.slice(close) : '';
+		var content = node.shadowRoot ? children(node.shadowRoot.childNodes) : children(node.childNodes);
+		return open + content + end;
+	}
+
+	function children(nodes) {
+		return Array.prototype.map.call(nodes, serialize).join('');
+	}
+
+	return serialize(arguments[0] || document.documentElement);
+`
+
+// findElement finds the first element matching selector, piercing open shadow
+// roots when config.Deep is set
+func findElement(wd selenium.WebDriver, config Config, selector string) (selenium.WebElement, error) {
+	if !config.Deep {
+		return wd.FindElement(selenium.ByCSSSelector, selector)
+	}
+	elems, err := findElements(wd, config, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("no such element: %s (including shadow roots)", selector)
+	}
+	return elems[0], nil
+}
+
+// waitForElement is findElement for interactions: it gives the element up to
+// config.ActionTimeout to appear before failing
+func waitForElement(wd selenium.WebDriver, config Config, selector string) (selenium.WebElement, error) {
+	var elem selenium.WebElement
+	err := wd.WaitWithTimeout(func(wd selenium.WebDriver) (bool, error) {
+		var err error
+		elem, err = findElement(wd, config, selector)
+		return err == nil, nil
+	}, config.ActionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no element matched %s within %s (raise with --action-timeout)", selector, config.ActionTimeout)
+	}
+	return elem, nil
+}
+
+// findElements finds all elements matching selector, piercing open shadow
+// roots when config.Deep is set
+func findElements(wd selenium.WebDriver, config Config, selector string) ([]selenium.WebElement, error) {
+	if !config.Deep {
+		return wd.FindElements(selenium.ByCSSSelector, selector)
+	}
+	raw, err := wd.ExecuteScriptRaw(deepQueryJS, []interface{}{selector})
+	if err != nil {
+		return nil, err
+	}
+	return wd.DecodeElements(raw)
+}
+
+// outerHTML returns the HTML of elem, with shadow root content inlined when config.Deep is set
+func outerHTML(wd selenium.WebDriver, config Config, elem selenium.WebElement) (string, error) {
+	script := "return arguments[0].outerHTML"
+	if config.Deep {
+		script = deepHTMLJS
+	}
+	html, err := wd.ExecuteScript(script, []interface{}{elem})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(html), nil
+}
+
+// pageSource returns the page HTML, with shadow root content inlined when config.Deep is set
+func pageSource(wd selenium.WebDriver, config Config) (string, error) {
+	if !config.Deep {
+		return wd.PageSource()
+	}
+	html, err := wd.ExecuteScript(deepHTMLJS, nil)
+	if err != nil {
+		return "", err
+	}
+	return "<!DOCTYPE html>\n" + fmt.Sprint(html), nil
+}
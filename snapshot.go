@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotPath is where --diff-prev keeps the last content of url for profile
+func snapshotPath(profile, url string) (string, error) {
+	dir, err := engineDir("firefox")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "snapshots", profile, hex.EncodeToString(sum[:12])+".md"), nil
+}
+
+// diffPrevious compares content with the snapshot saved by the previous run
+// for the same URL and profile, then saves content as the new snapshot. It
+// returns a report of what changed for the output.
+func diffPrevious(path, content string) (string, error) {
+	var report string
+	previous, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		report = "No previous run of this URL, saved a snapshot to compare the next run with\n"
+	case err != nil:
+		return "", fmt.Errorf("could not read snapshot: %v", err)
+	default:
+		since := "the previous run"
+		if info, err := os.Stat(path); err == nil {
+			since = info.ModTime().Format(time.RFC3339)
+		}
+		diff := unifiedDiff(string(previous), content, "previous", "current", 3)
+		if diff == "" {
+			report = fmt.Sprintf("No changes since %s\n", since)
+		} else {
+			report = fmt.Sprintf("Changes since %s:\n\n%s", since, diff)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("could not create snapshot directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("could not save snapshot: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffPrevious(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots", "default", "page.md")
+
+	report, err := diffPrevious(path, "# Changelog\n\n- v1.0")
+	if err != nil || !strings.HasPrefix(report, "No previous run") {
+		t.Errorf("First run report = %q, %v", report, err)
+	}
+
+	report, err = diffPrevious(path, "# Changelog\n\n- v1.0")
+	if err != nil || !strings.HasPrefix(report, "No changes since ") {
+		t.Errorf("Unchanged report = %q, %v", report, err)
+	}
+
+	report, err = diffPrevious(path, "# Changelog\n\n- v1.1\n- v1.0")
+	if err != nil || !strings.Contains(report, "+++ current\n@@ -1,3 +1,4 @@\n # Changelog\n\n+- v1.1\n - v1.0\n") {
+		t.Errorf("Changed report = %q, %v", report, err)
+	}
+}
+
+func TestSnapshotPathSeparatesProfiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a, _ := snapshotPath("default", "https://example.com")
+	b, _ := snapshotPath("work", "https://example.com")
+	c, _ := snapshotPath("default", "https://example.org")
+	if a == b || a == c {
+		t.Errorf("Expected distinct snapshot paths, got %s, %s, %s", a, b, c)
+	}
+}
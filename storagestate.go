@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// StorageState is Playwright's storage state file: cookies and the
+// localStorage of each origin, so a login captured by one tool is reused
+// by the other
+type StorageState struct {
+	Cookies []StateCookie `json:"cookies"`
+	Origins []StateOrigin `json:"origins"`
+}
+
+// StateCookie is a cookie as Playwright stores it, with an Expires of -1
+// for session cookies
+type StateCookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite"`
+}
+
+// StateOrigin is the localStorage of one origin, e.g. https://example.com
+type StateOrigin struct {
+	Origin       string      `json:"origin"`
+	LocalStorage []NameValue `json:"localStorage"`
+}
+
+// NameValue is a localStorage item
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// readStorageState loads a --load-state file, decrypting one --save-state
+// wrote with --encrypt-profile
+func readStorageState(config Config, path string) (StorageState, error) {
+	var state StorageState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	if isSealed(data) {
+		if !config.EncryptProfile {
+			return state, fmt.Errorf("%s is encrypted, run with --encrypt-profile", path)
+		}
+		passphrase, err := profilePassphrase(config.Profile)
+		if err != nil {
+			return state, err
+		}
+		if data, err = unseal(data, passphrase); err != nil {
+			return state, fmt.Errorf("could not decrypt %s: %v", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("could not decode %s: %v", path, err)
+	}
+	for _, origin := range state.Origins {
+		if u, err := url.Parse(origin.Origin); err != nil || u.Host == "" {
+			return state, fmt.Errorf("%s: bad origin %q", path, origin.Origin)
+		}
+	}
+	return state, nil
+}
+
+// cookies converts the state's cookies, leaving out expired ones
+func (s StorageState) cookies() []Cookie {
+	now := time.Now().Unix()
+	var cookies []Cookie
+	for _, c := range s.Cookies {
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: c.SameSite,
+		}
+		if c.Expires > 0 {
+			cookie.Expiry = int64(c.Expires)
+			if cookie.Expiry <= now {
+				continue
+			}
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
+}
+
+// toStateCookie converts a cookie the way Playwright stores it. Playwright
+// rejects sameSite values other than Strict, Lax and None.
+func toStateCookie(cookie Cookie) StateCookie {
+	c := StateCookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   cookie.Domain,
+		Path:     cookie.Path,
+		Expires:  -1,
+		HTTPOnly: cookie.HTTPOnly,
+		Secure:   cookie.Secure,
+		SameSite: "Lax",
+	}
+	if cookie.Expiry > 0 {
+		c.Expires = float64(cookie.Expiry)
+	}
+	if slices.Contains([]string{"Strict", "Lax", "None"}, cookie.SameSite) {
+		c.SameSite = cookie.SameSite
+	}
+	return c
+}
+
+// setLocalStorage fills in the localStorage of the --load-state origins
+// before the page loads. Like cookies, localStorage can only be written from
+// its own origin, so each origin's robots.txt is loaded first unless the
+// browser is on that origin already.
+func setLocalStorage(wd selenium.WebDriver, config Config) error {
+	for _, origin := range config.StorageOrigins {
+		if len(origin.LocalStorage) == 0 {
+			continue
+		}
+		current, _ := wd.CurrentURL()
+		if u, err := url.Parse(current); err != nil || u.Scheme+"://"+u.Host != origin.Origin {
+			if err := wd.Get(origin.Origin + "/robots.txt"); err != nil {
+				return fmt.Errorf("could not open %s to set its localStorage: %v", origin.Origin, err)
+			}
+		}
+		items := make(map[string]string, len(origin.LocalStorage))
+		for _, item := range origin.LocalStorage {
+			items[item.Name] = item.Value
+		}
+		_, err := wd.ExecuteScript(`for (const [name, value] of Object.entries(arguments[0])) localStorage.setItem(name, value)`, []interface{}{items})
+		if err != nil {
+			return fmt.Errorf("could not set localStorage of %s: %v", origin.Origin, err)
+		}
+	}
+	return nil
+}
+
+// saveStorageState writes the cookies and the localStorage of the current
+// page's origin as a Playwright storage state file. Chromium hands over all
+// of its cookies, the other engines only the ones of the current page.
+func saveStorageState(wd selenium.WebDriver, config Config, path string) error {
+	var cookies []Cookie
+	if config.Engine == "chromium" {
+		reply, err := webDriverRequest(wd, "POST", "/goog/cdp/execute", map[string]interface{}{"cmd": "Network.getAllCookies", "params": map[string]interface{}{}})
+		if err != nil {
+			return fmt.Errorf("could not read cookies: %v", err)
+		}
+		var result struct {
+			Cookies []struct {
+				Cookie
+				Expires float64 `json:"expires"`
+			} `json:"cookies"`
+		}
+		if err := json.Unmarshal(reply, &result); err != nil {
+			return fmt.Errorf("could not decode cookies: %v", err)
+		}
+		for _, c := range result.Cookies {
+			if c.Expires > 0 {
+				c.Cookie.Expiry = int64(c.Expires)
+			}
+			cookies = append(cookies, c.Cookie)
+		}
+	} else {
+		var err error
+		if cookies, err = collectCookies(wd); err != nil {
+			return err
+		}
+	}
+
+	state := StorageState{Cookies: []StateCookie{}, Origins: []StateOrigin{}}
+	for _, cookie := range cookies {
+		state.Cookies = append(state.Cookies, toStateCookie(cookie))
+	}
+
+	raw, err := wd.ExecuteScript(`
+		const items = [];
+		for (let i = 0; i < localStorage.length; i++) {
+			const name = localStorage.key(i);
+			items.push({name, value: localStorage.getItem(name)});
+		}
+		return {origin: location.origin, items};
+	`, nil)
+	if err != nil {
+		return fmt.Errorf("could not read localStorage: %v", err)
+	}
+	data, _ := json.Marshal(raw)
+	var storage struct {
+		Origin string      `json:"origin"`
+		Items  []NameValue `json:"items"`
+	}
+	json.Unmarshal(data, &storage)
+	// Opaque origins such as about:blank have no storage to carry over
+	if storage.Origin != "" && storage.Origin != "null" && len(storage.Items) > 0 {
+		state.Origins = append(state.Origins, StateOrigin{Origin: storage.Origin, LocalStorage: storage.Items})
+	}
+
+	return writeStorageState(config, path, state)
+}
+
+// writeStorageState writes a storage state file, encrypted like the profile
+// with --encrypt-profile
+func writeStorageState(config Config, path string, state StorageState) error {
+	encoded, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode storage state: %v", err)
+	}
+	encoded = append(encoded, '\n')
+	if config.EncryptProfile {
+		passphrase, err := profilePassphrase(config.Profile)
+		if err != nil {
+			return err
+		}
+		if encoded, err = seal(encoded, passphrase); err != nil {
+			return fmt.Errorf("could not encrypt storage state: %v", err)
+		}
+	}
+	// Cookies are credentials, keep them to the user
+	if err := os.WriteFile(path, encoded, 0600); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return nil
+}
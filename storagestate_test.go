@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStorageState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(path, []byte(`{
+  "cookies": [
+    {"name": "session", "value": "abc", "domain": "example.com", "path": "/", "expires": -1, "httpOnly": true, "secure": true, "sameSite": "Lax"},
+    {"name": "theme", "value": "dark", "domain": ".example.com", "path": "", "expires": 4102444800, "httpOnly": false, "secure": false, "sameSite": "None"},
+    {"name": "old", "value": "gone", "domain": "example.com", "path": "/", "expires": 1, "httpOnly": false, "secure": false, "sameSite": "Lax"}
+  ],
+  "origins": [
+    {"origin": "https://example.com", "localStorage": [{"name": "token", "value": "xyz"}]}
+  ]
+}`), 0600)
+
+	state, err := readStorageState(newConfig(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Cookie{
+		{Name: "session", Value: "abc", Domain: "example.com", Path: "/", Secure: true, HTTPOnly: true, SameSite: "Lax"},
+		{Name: "theme", Value: "dark", Domain: ".example.com", Path: "/", Expiry: 4102444800, SameSite: "None"},
+	}
+	cookies := state.cookies()
+	if !reflect.DeepEqual(cookies, expected) {
+		t.Errorf("cookies() = %+v, expected %+v", cookies, expected)
+	}
+	if len(state.Origins) != 1 || state.Origins[0].LocalStorage[0] != (NameValue{"token", "xyz"}) {
+		t.Errorf("Unexpected origins %+v", state.Origins)
+	}
+
+	// Cookies go back out the way they came in
+	for i, cookie := range cookies {
+		want := state.Cookies[i]
+		want.Path = "/"
+		if got := toStateCookie(cookie); got != want {
+			t.Errorf("toStateCookie(%s) = %+v, expected %+v", cookie.Name, got, want)
+		}
+	}
+	if got := toStateCookie(Cookie{Name: "bare"}).SameSite; got != "Lax" {
+		t.Errorf("Expected a cookie without sameSite to default to Lax, got %q", got)
+	}
+
+	os.WriteFile(path, []byte(`{"cookies": [], "origins": [{"origin": "example.com", "localStorage": []}]}`), 0600)
+	if _, err := readStorageState(newConfig(), path); err == nil {
+		t.Errorf("Expected an origin without a scheme to be rejected")
+	}
+}
+
+func TestStorageStateEncrypted(t *testing.T) {
+	t.Setenv("WEB_PROFILE_PASSPHRASE", "correct horse")
+	path := filepath.Join(t.TempDir(), "state.json")
+	config := newConfig()
+	config.EncryptProfile = true
+	state := StorageState{
+		Cookies: []StateCookie{{Name: "session", Value: "secret-session", Domain: "example.com", Path: "/", Expires: -1, SameSite: "Lax"}},
+		Origins: []StateOrigin{{Origin: "https://example.com", LocalStorage: []NameValue{{"token", "secret-token"}}}},
+	}
+	if err := writeStorageState(config, path, state); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected the state encrypted, got:\n%s", data)
+	}
+	loaded, err := readStorageState(config, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("readStorageState() = %+v, expected %+v", loaded, state)
+	}
+
+	if _, err := readStorageState(newConfig(), path); err == nil || !strings.Contains(err.Error(), "run with --encrypt-profile") {
+		t.Errorf("Expected an encrypted state to need --encrypt-profile, got %v", err)
+	}
+	t.Setenv("WEB_PROFILE_PASSPHRASE", "wrong")
+	if _, err := readStorageState(config, path); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Expected a wrong passphrase to be rejected, got %v", err)
+	}
+
+	// Through the flags, --load-state decrypts whichever order they come in
+	t.Setenv("WEB_PROFILE_PASSPHRASE", "correct horse")
+	parsed, err := parseArgs([]string{"example.com", "--load-state", path, "--encrypt-profile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Cookies) != 1 || parsed.Cookies[0].Value != "secret-session" {
+		t.Errorf("Expected the state's cookie, got %+v", parsed.Cookies)
+	}
+}
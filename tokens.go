@@ -0,0 +1,116 @@
+package main
+
+import (
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+const DEFAULT_TOKENIZER = "cl100k_base"
+
+// tokenizers are the --tokenizer values. The BPE vocabularies are built in;
+// "estimate" needs none and counts four characters per token.
+var tokenizers = []string{"cl100k_base", "o200k_base", "p50k_base", "r50k_base", "estimate"}
+
+// vocabularies are the BPE vocabularies of the tokenizers, checked in so
+// counting tokens never needs the network. go generate fetches them again.
+//
+//go:generate sh -c "for name in cl100k_base o200k_base p50k_base r50k_base; do curl -fsSL -o tokenizers/$name.tiktoken https://openaipublic.blob.core.windows.net/encodings/$name.tiktoken || exit 1; done"
+//go:embed tokenizers
+var vocabularies embed.FS
+
+// vocabularyLoader hands tiktoken the vocabularies it asks for by URL from
+// files instead of downloading them
+type vocabularyLoader struct {
+	files fs.FS
+}
+
+func (l vocabularyLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	data, err := fs.ReadFile(l.files, path.Base(url))
+	if err != nil {
+		return nil, fmt.Errorf("no %s vocabulary built in: %v", path.Base(url), err)
+	}
+	ranks := map[string]int{}
+	for _, line := range strings.Split(string(data), "\n") {
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("bad token in %s: %v", path.Base(url), err)
+		}
+		if ranks[string(decoded)], err = strconv.Atoi(rank); err != nil {
+			return nil, fmt.Errorf("bad rank in %s: %v", path.Base(url), err)
+		}
+	}
+	return ranks, nil
+}
+
+// tokenCounter counts and truncates text in the tokens of an LLM tokenizer
+type tokenCounter struct {
+	name     string
+	encoding *tiktoken.Tiktoken
+}
+
+// newTokenCounter loads the named tokenizer, falling back to an estimate
+// with a warning when its vocabulary can't be read
+func newTokenCounter(name string) *tokenCounter {
+	if name == "estimate" {
+		return &tokenCounter{name: name}
+	}
+
+	files, _ := fs.Sub(vocabularies, "tokenizers")
+	tiktoken.SetBpeLoader(vocabularyLoader{files: files})
+	encoding, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		logf("Warning: Could not load the %s tokenizer, estimating token counts instead: %v\n", name, err)
+		return &tokenCounter{name: "estimate"}
+	}
+	return &tokenCounter{name: name, encoding: encoding}
+}
+
+func (c *tokenCounter) count(text string) int {
+	if c.encoding == nil {
+		return estimateTokens(text)
+	}
+	return len(c.encoding.EncodeOrdinary(text))
+}
+
+// truncate cuts text to at most n tokens, reporting whether it was cut
+func (c *tokenCounter) truncate(text string, n int) (string, bool) {
+	if c.encoding == nil {
+		runes := []rune(text)
+		if len(runes) <= n*4 {
+			return text, false
+		}
+		return string(runes[:n*4]), true
+	}
+	tokens := c.encoding.EncodeOrdinary(text)
+	if len(tokens) <= n {
+		return text, false
+	}
+	// Tokens can end partway through a multi-byte character
+	return strings.ToValidUTF8(c.encoding.Decode(tokens[:n]), ""), true
+}
+
+// describe labels a token count for the output header, e.g. "1234 (cl100k_base)"
+func (c *tokenCounter) describe(count int) string {
+	if c.encoding == nil {
+		return fmt.Sprintf("~%d (estimated)", count)
+	}
+	return fmt.Sprintf("%d (%s)", count, c.name)
+}
+
+// estimateTokens approximates the token count of text at four characters
+// per token, which is close for English prose
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
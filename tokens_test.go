@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTokenCounterEstimate(t *testing.T) {
+	counter := newTokenCounter("estimate")
+
+	if count := counter.count("twelve chars"); count != 3 {
+		t.Errorf("count() = %d, expected 3", count)
+	}
+	if described := counter.describe(3); described != "~3 (estimated)" {
+		t.Errorf("describe() = %q", described)
+	}
+
+	truncated, cut := counter.truncate("abcdefghijkl", 2)
+	if !cut || truncated != "abcdefgh" {
+		t.Errorf("truncate() = %q, %v; expected \"abcdefgh\", true", truncated, cut)
+	}
+	if _, cut := counter.truncate("short", 2); cut {
+		t.Errorf("truncate() cut text that fits")
+	}
+}
+
+func TestVocabularyLoader(t *testing.T) {
+	var vocabulary []byte
+	for i, token := range []string{"a", "b", "ab"} {
+		vocabulary = append(vocabulary, base64.StdEncoding.EncodeToString([]byte(token))+" "+string(rune('0'+i))+"\n"...)
+	}
+	loader := vocabularyLoader{files: fstest.MapFS{"r50k_base.tiktoken": {Data: vocabulary}}}
+
+	ranks, err := loader.LoadTiktokenBpe("https://openaipublic.blob.core.windows.net/encodings/r50k_base.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 3 || ranks["a"] != 0 || ranks["ab"] != 2 {
+		t.Errorf("Unexpected ranks %v", ranks)
+	}
+	if _, err := loader.LoadTiktokenBpe("https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken"); err == nil {
+		t.Error("Expected a vocabulary that isn't there to fail rather than be downloaded")
+	}
+}
+
+func TestTokenCounterOffline(t *testing.T) {
+	if _, err := fs.Stat(vocabularies, "tokenizers/cl100k_base.tiktoken"); err != nil {
+		t.Skip("The vocabularies are not checked in, run go generate")
+	}
+	// Nothing can be downloaded through this proxy
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+
+	counter := newTokenCounter("cl100k_base")
+	if counter.encoding == nil {
+		t.Fatal("Expected the built-in vocabulary to be used")
+	}
+	if count := counter.count("hello world"); count != 2 {
+		t.Errorf("count() = %d, expected 2", count)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestUpstreamProxy(t *testing.T) {
+	// Stands in for a corporate proxy that wants a login
+	corporate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("me:secret")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		io.WriteString(w, "via proxy: "+r.URL.String())
+	}))
+	defer corporate.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer direct.Close()
+
+	proxyURL, err := parseProxyURL("me:secret@" + corporate.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := startProxy()
+	if err != nil {
+		t.Fatalf("startProxy returned error: %v", err)
+	}
+	defer local.Close()
+	local.useUpstream(&upstreamProxy{url: proxyURL, bypass: []string{".intranet.test"}})
+
+	localURL, _ := url.Parse("http://" + local.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(localURL)}}
+	for target, expected := range map[string]string{
+		"http://example.test/page": "via proxy: http://example.test/page",
+		// The proxy can't reach this machine, so it is never asked to
+		direct.URL: "direct",
+	} {
+		resp, err := client.Get(target)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", target, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != expected {
+			t.Errorf("GET %s = %q, expected %q", target, body, expected)
+		}
+	}
+
+	upstream := &upstreamProxy{url: proxyURL, bypass: []string{"*.intranet.test", ".corp.test", "wiki.test"}}
+	for host, expected := range map[string]bool{
+		"localhost:4000":      true,
+		"[::1]:80":            true,
+		"intranet.test":       true,
+		"hr.intranet.test":    true,
+		"git.corp.test:443":   true,
+		"wiki.test":           true,
+		"docs.wiki.test":      false,
+		"example.com":         false,
+		"notintranet.test:80": false,
+	} {
+		if got := upstream.bypasses(host); got != expected {
+			t.Errorf("bypasses(%q) = %v, expected %v", host, got, expected)
+		}
+	}
+
+	for _, value := range []string{"ftp://proxy:21", "http://"} {
+		if _, err := parseProxyURL(value); err == nil {
+			t.Errorf("Expected --proxy %s to be rejected", value)
+		}
+	}
+}
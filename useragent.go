@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// uaPresetNames lists the --ua-preset names in the order help shows them
+var uaPresetNames = []string{"googlebot", "bingbot", "iphone", "android", "desktop-chrome", "desktop-firefox", "desktop-safari"}
+
+// uaPresets are user agents sites commonly serve different markup to
+var uaPresets = map[string]string{
+	"googlebot":       "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"bingbot":         "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+	"iphone":          "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+	"android":         "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36",
+	"desktop-chrome":  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+	"desktop-firefox": "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0",
+	"desktop-safari":  "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+}
+
+// presetUserAgent returns the user agent of a --ua-preset
+func presetUserAgent(name string) (string, error) {
+	userAgent, ok := uaPresets[name]
+	if !ok {
+		return "", fmt.Errorf("--ua-preset must be one of %s, got %q", strings.Join(uaPresetNames, ", "), name)
+	}
+	return userAgent, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	varReference = regexp.MustCompile(`\$\{(\w+)\}`)
+	varName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// codeFlags take JavaScript, where ${name} is template literal syntax, so
+// only --var names are expanded in them and everything else is left alone:
+// the environment isn't consulted, keeping it from leaking into page scripts
+var codeFlags = map[string]bool{"--js": true, "--wait-for": true}
+
+// parseVarAssignment splits a --var NAME=value assignment
+func parseVarAssignment(assignment string) (string, string, error) {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok || !varName.MatchString(name) {
+		return "", "", fmt.Errorf("--var expects NAME=value, got %q", assignment)
+	}
+	return name, value, nil
+}
+
+// expandVars replaces ${NAME} with the --var value of that name, falling back
+// to the environment so secrets don't need to appear on the command line.
+// Undefined variables are an error. In code, only --var values are expanded
+// and other references are kept as written.
+func expandVars(s string, vars map[string]string, code bool) (string, error) {
+	var missing string
+	expanded := varReference.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varReference.FindStringSubmatch(ref)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if code {
+			return ref
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		if missing == "" {
+			missing = name
+		}
+		return ref
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable ${%s} (set it with --var %s=... or in the environment)", missing, missing)
+	}
+	return expanded, nil
+}
+
+// expandArgs collects the --var assignments in args and expands ${NAME}
+// references in every other argument, so variables work in any flag value
+// regardless of where --var appears
+func expandArgs(args []string) ([]string, map[string]string, error) {
+	vars := map[string]string{}
+	isVarValue := make([]bool, len(args))
+	for i, arg := range args {
+		assignment, inline := strings.CutPrefix(arg, "--var=")
+		if !inline {
+			if arg != "--var" || i+1 >= len(args) {
+				continue
+			}
+			assignment = args[i+1]
+			isVarValue[i+1] = true
+		}
+		name, value, err := parseVarAssignment(assignment)
+		if err != nil {
+			return nil, nil, err
+		}
+		vars[name] = value
+	}
+
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		if isVarValue[i] || strings.HasPrefix(arg, "--var=") {
+			expanded[i] = arg
+			continue
+		}
+		flag, _, _ := strings.Cut(arg, "=")
+		code := codeFlags[flag] || (i > 0 && codeFlags[args[i-1]])
+		value, err := expandVars(arg, vars, code)
+		if err != nil {
+			return nil, nil, err
+		}
+		expanded[i] = value
+	}
+	return expanded, vars, nil
+}
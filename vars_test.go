@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandArgs(t *testing.T) {
+	t.Setenv("WEB_TEST_PASSWORD", "secret")
+
+	args := []string{
+		"${HOST}/login",
+		"--form", "login",
+		"--input", "email", "--value", "${EMAIL}",
+		"--input", "password", "--value", "${WEB_TEST_PASSWORD}",
+		"--js", "console.log(`${count}`)",
+		"--var", "EMAIL=foo@bar.com",
+		"--var=HOST=localhost:4000",
+	}
+	expanded, vars, err := expandArgs(args)
+	if err != nil {
+		t.Fatalf("expandArgs returned error: %v", err)
+	}
+
+	expected := []string{
+		"localhost:4000/login",
+		"--form", "login",
+		"--input", "email", "--value", "foo@bar.com",
+		"--input", "password", "--value", "secret",
+		"--js", "console.log(`${count}`)",
+		"--var", "EMAIL=foo@bar.com",
+		"--var=HOST=localhost:4000",
+	}
+	if !reflect.DeepEqual(expanded, expected) {
+		t.Errorf("expandArgs() =\n%q\nexpected\n%q", expanded, expected)
+	}
+	if vars["EMAIL"] != "foo@bar.com" || vars["HOST"] != "localhost:4000" {
+		t.Errorf("Variables collected incorrectly: %v", vars)
+	}
+}
+
+func TestExpandArgsCode(t *testing.T) {
+	t.Setenv("WEB_TEST_TOKEN", "secret")
+
+	args := []string{
+		"example.com",
+		"--js", "console.log(`${WEB_TEST_TOKEN} ${COUNT}`)",
+		"--wait-for=`${WEB_TEST_TOKEN}` === document.title",
+		"--var", "COUNT=3",
+	}
+	expanded, _, err := expandArgs(args)
+	if err != nil {
+		t.Fatalf("expandArgs returned error: %v", err)
+	}
+	if expanded[2] != "console.log(`${WEB_TEST_TOKEN} 3`)" {
+		t.Errorf("Expected only --var names expanded in --js, got %q", expanded[2])
+	}
+	if expanded[3] != "--wait-for=`${WEB_TEST_TOKEN}` === document.title" {
+		t.Errorf("Expected the environment left out of --wait-for, got %q", expanded[3])
+	}
+}
+
+func TestExpandArgsErrors(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"example.com", "--var", "EMAIL"}, `--var expects NAME=value, got "EMAIL"`},
+		{[]string{"example.com", "--var", "1X=y"}, `--var expects NAME=value, got "1X=y"`},
+		{[]string{"example.com", "--wait-for-text", "${WEB_TEST_UNDEFINED}"}, "undefined variable ${WEB_TEST_UNDEFINED}"},
+	}
+
+	for _, tc := range cases {
+		_, _, err := expandArgs(tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expandArgs(%q) error = %v, expected %q", tc.args, err, tc.expected)
+		}
+	}
+}
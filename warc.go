@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// warcRecord is one record of a WARC 1.1 file
+type warcRecord struct {
+	Type         string
+	TargetURI    string
+	Date         time.Time
+	IPAddress    string
+	ID           string
+	ConcurrentTo string
+	ContentType  string
+	Block        []byte
+	// Payload is the HTTP body of a response, digested as WARC-Payload-Digest
+	Payload []byte
+}
+
+// writeWARC saves the exchanges as request and response records, gzipped
+// record by record when path ends in .gz as most replay tools expect
+func writeWARC(path string, exchanges []*Exchange) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	records := []warcRecord{{
+		Type:        "warcinfo",
+		Date:        time.Now(),
+		ID:          warcRecordID(),
+		ContentType: "application/warc-fields",
+		Block:       []byte("software: web\r\nformat: WARC File Format 1.1\r\n"),
+	}}
+	for _, exchange := range exchanges {
+		if exchange.Err != nil || exchange.StatusCode == 0 {
+			continue
+		}
+		records = append(records, exchangeRecords(exchange)...)
+	}
+
+	compress := strings.HasSuffix(path, ".gz")
+	for _, record := range records {
+		var w io.Writer = file
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(file)
+			w = gz
+		}
+		if err := record.write(w); err != nil {
+			return fmt.Errorf("could not write %s: %v", path, err)
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				return fmt.Errorf("could not write %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// exchangeRecords is the request record and the response record for an exchange
+func exchangeRecords(exchange *Exchange) []warcRecord {
+	target, err := url.Parse(exchange.URL)
+	if err != nil {
+		return nil
+	}
+	ip, _, _ := net.SplitHostPort(exchange.RemoteAddr)
+
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "%s %s HTTP/1.1\r\nHost: %s\r\n", exchange.Method, target.RequestURI(), target.Host)
+	exchange.RequestHeader.Write(&request)
+	request.WriteString("\r\n")
+	request.Write(exchange.RequestBody)
+
+	var response bytes.Buffer
+	fmt.Fprintf(&response, "HTTP/1.1 %s\r\n", exchange.Status)
+	exchange.ResponseHeader.Write(&response)
+	response.WriteString("\r\n")
+	response.Write(exchange.ResponseBody)
+
+	responseRecord := warcRecord{
+		Type:        "response",
+		TargetURI:   exchange.URL,
+		Date:        exchange.Started,
+		IPAddress:   ip,
+		ID:          warcRecordID(),
+		ContentType: "application/http;msgtype=response",
+		Block:       response.Bytes(),
+		Payload:     exchange.ResponseBody,
+	}
+	requestRecord := warcRecord{
+		Type:         "request",
+		TargetURI:    exchange.URL,
+		Date:         exchange.Started,
+		ID:           warcRecordID(),
+		ConcurrentTo: responseRecord.ID,
+		ContentType:  "application/http;msgtype=request",
+		Block:        request.Bytes(),
+	}
+	return []warcRecord{requestRecord, responseRecord}
+}
+
+func (record warcRecord) write(w io.Writer) error {
+	var header bytes.Buffer
+	header.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&header, "WARC-Type: %s\r\n", record.Type)
+	fmt.Fprintf(&header, "WARC-Record-ID: %s\r\n", record.ID)
+	fmt.Fprintf(&header, "WARC-Date: %s\r\n", record.Date.UTC().Format(time.RFC3339))
+	if record.TargetURI != "" {
+		fmt.Fprintf(&header, "WARC-Target-URI: %s\r\n", record.TargetURI)
+	}
+	if record.IPAddress != "" {
+		fmt.Fprintf(&header, "WARC-IP-Address: %s\r\n", record.IPAddress)
+	}
+	if record.ConcurrentTo != "" {
+		fmt.Fprintf(&header, "WARC-Concurrent-To: %s\r\n", record.ConcurrentTo)
+	}
+	if record.Type == "response" {
+		fmt.Fprintf(&header, "WARC-Payload-Digest: %s\r\n", warcDigest(record.Payload))
+	}
+	fmt.Fprintf(&header, "WARC-Block-Digest: %s\r\n", warcDigest(record.Block))
+	fmt.Fprintf(&header, "Content-Type: %s\r\n", record.ContentType)
+	fmt.Fprintf(&header, "Content-Length: %d\r\n\r\n", len(record.Block))
+
+	for _, part := range [][]byte{header.Bytes(), record.Block, []byte("\r\n\r\n")} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warcDigest is the labelled base32 SHA-1 digest WARC tools verify
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func warcRecordID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteWARC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.warc.gz")
+	exchanges := []*Exchange{{
+		Method:         "GET",
+		URL:            "https://example.com/docs?page=2",
+		RequestHeader:  http.Header{"Accept": {"text/html"}},
+		Status:         "200 OK",
+		StatusCode:     200,
+		ResponseHeader: http.Header{"Content-Type": {"text/html"}},
+		ResponseBody:   []byte("<h1>Docs</h1>"),
+		RemoteAddr:     "93.184.216.34:443",
+		Started:        time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:       time.Millisecond,
+	}}
+
+	if err := writeWARC(path, exchanges); err != nil {
+		t.Fatalf("writeWARC returned error: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		t.Fatalf("Expected gzipped WARC: %v", err)
+	}
+	data, _ := io.ReadAll(gz)
+	warc := string(data)
+
+	if count := strings.Count(warc, "WARC/1.1\r\n"); count != 3 {
+		t.Errorf("Expected warcinfo, request and response records, got %d records", count)
+	}
+	for _, expected := range []string{
+		"WARC-Type: request\r\n",
+		"GET /docs?page=2 HTTP/1.1\r\nHost: example.com\r\nAccept: text/html\r\n\r\n",
+		"WARC-Type: response\r\n",
+		"WARC-Target-URI: https://example.com/docs?page=2\r\n",
+		"WARC-Date: 2026-01-02T03:04:05Z\r\n",
+		"WARC-IP-Address: 93.184.216.34\r\n",
+		"WARC-Payload-Digest: " + warcDigest([]byte("<h1>Docs</h1>")) + "\r\n",
+		"HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<h1>Docs</h1>\r\n\r\n",
+	} {
+		if !strings.Contains(warc, expected) {
+			t.Errorf("Expected %q in WARC. Got:\n%s", expected, warc)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tebeka/selenium"
+)
+
+// CacheStats summarizes how much of a page load was served from the browser cache
+type CacheStats struct {
+	URL            string
+	LoadMS         int64
+	Resources      int
+	CacheHits      int
+	ServiceWorkers int
+}
+
+// HitRate is the percentage of resources served from cache
+func (stats CacheStats) HitRate() int {
+	if stats.Resources == 0 {
+		return 0
+	}
+	return stats.CacheHits * 100 / stats.Resources
+}
+
+type WarmConfig struct {
+	URLs           []string
+	File           string
+	Profile        string
+	EncryptProfile bool
+	RecycleAfter   int
+	MaxMemory      string
+	MaxCPU         int
+	RespectRobots  bool
+}
+
+// runWarm implements `web warm <url>...`, loading each URL in the profile so
+// later runs hit warm DNS, TLS, HTTP and service worker caches. It returns the
+// process exit code.
+func runWarm(args []string) int {
+	config := WarmConfig{Profile: "default"}
+
+	defs := []flagDef{
+		{name: "--help", kind: flagBool, apply: func(string) error {
+			printWarmHelp()
+			os.Exit(0)
+			return nil
+		}},
+		{name: "--file", kind: flagFile, target: &config.File},
+		{name: "--profile", kind: flagString, target: &config.Profile},
+		dataDirFlag,
+		{name: "--encrypt-profile", kind: flagBool, target: &config.EncryptProfile},
+		{name: "--recycle-after", kind: flagInt, target: &config.RecycleAfter},
+		{name: "--max-memory", kind: flagString, apply: func(value string) (err error) {
+			config.MaxMemory, err = parseMemorySize(value)
+			return err
+		}},
+		{name: "--max-cpu", kind: flagInt, target: &config.MaxCPU},
+		{name: "--respect-robots", kind: flagBool, target: &config.RespectRobots},
+	}
+
+	err := parseFlags(args, defs, func(arg string) error {
+		config.URLs = append(config.URLs, arg)
+		return nil
+	})
+	if err == nil && config.File != "" {
+		var urls []string
+		urls, err = readURLList(config.File)
+		config.URLs = append(config.URLs, urls...)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'web warm --help' for usage.\n", err)
+		return 1
+	}
+
+	if len(config.URLs) == 0 {
+		printWarmHelp()
+		return 1
+	}
+
+	ensureBrowser(Config{})
+
+	browserConfig := Config{Profile: config.Profile, EncryptProfile: config.EncryptProfile, MaxMemory: config.MaxMemory, MaxCPU: config.MaxCPU}
+	runner := newRunner(browserConfig)
+	if err := runner.start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+		return 1
+	}
+	defer runner.close()
+
+	fmt.Printf("==========================\nWarm: %d URL(s) (profile %q)\n==========================\n\n", len(config.URLs), config.Profile)
+
+	var robots *robotsPolicy
+	if config.RespectRobots {
+		robots = newRobotsPolicy()
+	}
+	failed, skipped := 0, 0
+	var all []CacheStats
+	for i, target := range config.URLs {
+		// A fresh browser keeps long lists from building up memory
+		if config.RecycleAfter > 0 && i > 0 && i%config.RecycleAfter == 0 {
+			logf("Restarting the browser after %d URL(s)\n", config.RecycleAfter)
+			runner.close()
+			if err := runner.start(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error starting browser: %v\n", err)
+				return 1
+			}
+		}
+		wd := runner.wd
+
+		target = ensureProtocol(target)
+		if robots != nil {
+			if err := robots.check(target); err != nil {
+				fmt.Printf("%s  SKIPPED: %v\n", target, err)
+				skipped++
+				continue
+			}
+			robots.wait(target)
+		}
+		if err := wd.Get(target); err != nil {
+			fmt.Printf("%s  FAILED: %v\n", target, err)
+			failed++
+			continue
+		}
+		stats, err := collectCacheStats(wd)
+		if err != nil {
+			fmt.Printf("%s  FAILED: %v\n", target, err)
+			failed++
+			continue
+		}
+		stats.URL = target
+		all = append(all, stats)
+		fmt.Println(formatCacheStats(stats))
+	}
+
+	if len(all) > 0 {
+		total := CacheStats{}
+		for _, stats := range all {
+			total.Resources += stats.Resources
+			total.CacheHits += stats.CacheHits
+		}
+		fmt.Printf("\nOverall: %d/%d resources from cache (%d%%)\n", total.CacheHits, total.Resources, total.HitRate())
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d URL(s) disallowed by robots.txt\n", skipped)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readURLList reads one URL per line, skipping blank lines and # comments
+func readURLList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read URL list: %v", err)
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// collectCacheStats reads the Resource Timing entries of the current page. A
+// resource counts as cached when it has a body but nothing was transferred.
+// Cross-origin resources without Timing-Allow-Origin report no sizes and are
+// left out. It also waits briefly for service workers registered by the page
+// to activate, so they are installed for the next run.
+func collectCacheStats(wd selenium.WebDriver) (CacheStats, error) {
+	raw, err := wd.ExecuteScript(`
+		var entries = performance.getEntriesByType('navigation').concat(performance.getEntriesByType('resource'));
+		var nav = performance.getEntriesByType('navigation')[0];
+		var stats = { load: nav ? Math.round(nav.loadEventEnd - nav.startTime) : 0, total: 0, hits: 0 };
+		entries.forEach(function(entry) {
+			if (entry.transferSize === 0 && entry.decodedBodySize === 0) {
+				return;
+			}
+			stats.total++;
+			if (entry.transferSize === 0) {
+				stats.hits++;
+			}
+		});
+		return stats;
+	`, nil)
+	if err != nil {
+		return CacheStats{}, fmt.Errorf("could not read resource timings: %v", err)
+	}
+
+	entry, _ := raw.(map[string]interface{})
+	stats := CacheStats{
+		LoadMS:    int64(toFloat(entry["load"])),
+		Resources: int(toFloat(entry["total"])),
+		CacheHits: int(toFloat(entry["hits"])),
+	}
+
+	workers, err := wd.ExecuteScriptAsync(`
+		var done = arguments[arguments.length - 1];
+		if (!navigator.serviceWorker) {
+			return done(0);
+		}
+		setTimeout(function() { done(0); }, 5000);
+		navigator.serviceWorker.getRegistrations().then(function(registrations) {
+			if (registrations.length === 0) {
+				return done(0);
+			}
+			navigator.serviceWorker.ready.then(function() { done(registrations.length); });
+		}, function() { done(0); });
+	`, nil)
+	if err == nil {
+		stats.ServiceWorkers = int(toFloat(workers))
+	}
+
+	return stats, nil
+}
+
+func toFloat(value interface{}) float64 {
+	if f, ok := value.(float64); ok {
+		return f
+	}
+	return 0
+}
+
+func formatCacheStats(stats CacheStats) string {
+	line := fmt.Sprintf("%s  %dms  %d/%d resources from cache (%d%%)", stats.URL, stats.LoadMS, stats.CacheHits, stats.Resources, stats.HitRate())
+	if stats.ServiceWorkers > 0 {
+		line += fmt.Sprintf("  %d service worker(s)", stats.ServiceWorkers)
+	}
+	return line
+}
+
+func printWarmHelp() {
+	fmt.Print(`web warm - prime browser caches for a set of sites
+
+Usage: web warm <url>... [options]
+
+Loads each URL in the profile so later runs reuse its DNS, TLS, HTTP cache and
+service workers, and reports how much of each page was already cached. Run it
+again to see the hit rate a warm profile gets.
+
+Options:
+  --help                     Show this help message
+  --file <path>              Read URLs from a file, one per line (# starts a comment)
+  --profile <name>           Use or create named session profile (default: "default")
+  --data-dir <dir>           Keep browsers, profiles and caches in <dir> (default: WEB_DATA_DIR, otherwise
+                             ~/.web-<engine>, or $XDG_DATA_HOME/web when set)
+  --encrypt-profile          Keep the profile encrypted at rest (passphrase from WEB_PROFILE_PASSPHRASE or the OS keychain)
+  --recycle-after <n>        Restart the browser every n URLs, so long lists don't build up memory
+  --max-memory <size>        Cap the memory of the browser and its driver, e.g. 2G (Linux; without systemd
+                             each process's address space is capped)
+  --max-cpu <percent>        Cap the CPU use of the browser and its driver, 100 being one core (Linux with
+                             systemd, the run fails elsewhere)
+  --respect-robots           Skip URLs their site's robots.txt disallows, and wait out its Crawl-delay between
+                             URLs of the same host
+
+Examples:
+  web warm https://hexdocs.pm https://elixirforum.com
+  web warm --file sites.txt --profile agent
+  web warm --file sites.txt --respect-robots
+`)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadURLList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sites.txt")
+	os.WriteFile(path, []byte("# docs\nhexdocs.pm\n\n  https://elixirforum.com  \n"), 0644)
+
+	urls, err := readURLList(path)
+	if err != nil {
+		t.Fatalf("Failed to read URL list: %v", err)
+	}
+	expected := []string{"hexdocs.pm", "https://elixirforum.com"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("readURLList() = %q, expected %q", urls, expected)
+	}
+}
+
+func TestFormatCacheStats(t *testing.T) {
+	stats := CacheStats{URL: "https://hexdocs.pm", LoadMS: 120, Resources: 8, CacheHits: 6, ServiceWorkers: 1}
+	expected := "https://hexdocs.pm  120ms  6/8 resources from cache (75%)  1 service worker(s)"
+	if got := formatCacheStats(stats); got != expected {
+		t.Errorf("formatCacheStats() = %q, expected %q", got, expected)
+	}
+}
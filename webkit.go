@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/tebeka/selenium"
+)
+
+// webkitPaths finds the system's WebKit WebDriver and, on Linux, the browser
+// it drives (browserPath when given). There is no WebKit build with a
+// WebDriver to download: on macOS safaridriver ships with Safari, on Linux
+// WebKitWebDriver and MiniBrowser come with WebKitGTK.
+func webkitPaths(browserPath string) (string, string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if browserPath != "" {
+			return "", "", fmt.Errorf("safaridriver only drives the installed Safari, --browser-path is not supported")
+		}
+		if _, err := os.Stat("/usr/bin/safaridriver"); err != nil {
+			return "", "", fmt.Errorf("safaridriver not found, it ships with Safari 10 and later")
+		}
+		return "/usr/bin/safaridriver", "", nil
+	case "linux":
+		driver, err := exec.LookPath("WebKitWebDriver")
+		if err != nil {
+			return "", "", fmt.Errorf("WebKitWebDriver not found, install WebKitGTK's WebDriver (e.g. apt install webkit2gtk-driver)")
+		}
+		if browserPath != "" {
+			return driver, browserPath, nil
+		}
+		browsers, _ := filepath.Glob("/usr/lib/*/webkit2gtk-4.*/MiniBrowser")
+		more, _ := filepath.Glob("/usr/libexec/webkit2gtk-4.*/MiniBrowser")
+		browsers = append(browsers, more...)
+		if path, err := exec.LookPath("MiniBrowser"); err == nil {
+			browsers = append([]string{path}, browsers...)
+		}
+		if len(browsers) == 0 {
+			return "", "", fmt.Errorf("MiniBrowser not found, install WebKitGTK's MiniBrowser (e.g. apt install webkit2gtk-driver libwebkit2gtk-4.1-0)")
+		}
+		return driver, browsers[0], nil
+	default:
+		return "", "", fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// webkitSession starts the WebKit WebDriver and returns the capabilities for
+// a session. WebKit sessions are ephemeral, they don't take a profile.
+func webkitSession(config Config, port int) (webDriverService, selenium.Capabilities, error) {
+	driver, browser, err := webkitPaths(config.BrowserPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var caps selenium.Capabilities
+	var service webDriverService
+	if runtime.GOOS == "darwin" {
+		service, err = startDriver(config, port, driver, "--port", fmt.Sprint(port))
+		if !config.Headed {
+			logf("Warning: Safari has no headless mode, its window stays open for the run\n")
+		}
+		caps = selenium.Capabilities{"browserName": "safari"}
+	} else {
+		service, err = startDriver(config, port, driver, fmt.Sprintf("--port=%d", port))
+		args := []string{}
+		if !config.Headed {
+			args = append(args, "--headless")
+		}
+		caps = selenium.Capabilities{
+			"browserName": "MiniBrowser",
+			"webkitgtk:browserOptions": map[string]interface{}{
+				"binary": browser,
+				"args":   args,
+			},
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start %s service: %v", filepath.Base(driver), err)
+	}
+
+	// WebKit has no proxy preferences or switches, only the standard capability
+	if config.Proxy != nil {
+		address := fmt.Sprintf("127.0.0.1:%d", config.Proxy.Port())
+		caps["proxy"] = map[string]interface{}{
+			"proxyType": "manual",
+			"httpProxy": address,
+			"sslProxy":  address,
+		}
+	}
+	return service, caps, nil
+}